@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/heroku/x/cmdutil/metrics/otel"
+	"github.com/heroku/x/cmdutil/metrics/statsd"
 
 	xmetrics "github.com/heroku/x/go-kit/metrics"
 )
@@ -15,10 +16,11 @@ type Config struct {
 	Source         string        `env:"METRICS_SOURCE"`
 	Prefix         string        `env:"METRICS_PREFIX"`
 	DefaultTags    []string      `env:"METRICS_DEFAULT_TAGS"`
-	// If OTEL is enabled, l2met is disabled, by default.
+	// If OTEL or StatsD is enabled, l2met is disabled, by default.
 	// Setting this value to `true` overrides that default.
 	L2MetOverrideEnabled bool `env:"METRICS_ENABLE_L2MET_OVERRIDE"`
 	OTEL                 otel.Config
+	StatsD               statsd.Config
 }
 
 // ReportPanic attempts to report a panic via the metrics provider.
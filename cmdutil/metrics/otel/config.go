@@ -15,6 +15,20 @@ type Config struct {
 	MetricsDestinations  []string `env:"OTEL_METRICS_DESTINATIONS,default=honeycomb;argus"`
 	Honeycomb            honeycomb.Config
 
+	// CardinalityLimit, if set, limits the number of series of every metric.
+	// See otel.WithCardinalityLimit.
+	CardinalityLimit int `env:"OTEL_METRICS_CARDINALITY_LIMIT"`
+
+	// TracingEnabled enables exporting traces to the same endpoint as metrics.
+	TracingEnabled bool `env:"ENABLE_OTEL_TRACING"`
+
+	// ServiceNamespace, if set, is the `service.namespace` resource attribute
+	// of traces. It defaults to the deploy of the service.
+	ServiceNamespace string `env:"OTEL_SERVICE_NAMESPACE"`
+
+	// Cloud, if set, is added as the `cloud` resource attribute of metrics and traces.
+	Cloud string `env:"OTEL_CLOUD"`
+
 	// EndpointURL maps to the official opentelemetry environment variable for configuring the endpoint
 	EndpointURL *url.URL `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
 }
@@ -2,9 +2,14 @@ package otel
 
 import (
 	"context"
+	"net/url"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	"github.com/heroku/x/go-kit/metrics"
 	"github.com/heroku/x/go-kit/metrics/provider/otel"
@@ -16,21 +21,16 @@ func MustProvider(ctx context.Context, logger logrus.FieldLogger, cfg Config, se
 	// This provider is used for metrics reporting to the  collector.
 	logger.WithField("metrics_destinations", strings.Join(cfg.MetricsDestinations, ",")).Info("setting up  provider")
 
-	// to allow transitioning between endpoints first check if the newer env is present
-	endpoint := cfg.EndpointURL
-	if endpoint == nil {
-		endpoint = cfg.CollectorURL
-	}
-
-	if endpoint == nil {
-		logger.Fatal("provider collectorURL cannot be nil")
-	}
+	endpoint := mustEndpoint(logger, cfg)
 
 	// configure some optional resource attributes
 	attrs := otel.MetricsDestinations(cfg.MetricsDestinations)
 	if cfg.Honeycomb.MetricsDataset != "" {
 		attrs = append(attrs, otel.HoneycombDataset(cfg.Honeycomb.MetricsDataset))
 	}
+	if cfg.Cloud != "" {
+		attrs = append(attrs, otel.Cloud(cfg.Cloud))
+	}
 
 	allOpts := []otel.Option{ //nolint:prealloc // composite literal clarity over prealloc
 		// ensure we have service.id, service.namespace, and service.instance.id attributes
@@ -57,6 +57,9 @@ func MustProvider(ctx context.Context, logger logrus.FieldLogger, cfg Config, se
 		// optionally enable Golang runtime metrics collection
 		otel.WithRuntimeInstrumentation(cfg.EnableRuntimeMetrics),
 	}
+	if cfg.CardinalityLimit > 0 {
+		allOpts = append(allOpts, otel.WithCardinalityLimit(cfg.CardinalityLimit))
+	}
 	allOpts = append(allOpts, opts...)
 
 	otelProvider, err := otel.New(ctx, service, allOpts...)
@@ -70,3 +73,54 @@ func MustProvider(ctx context.Context, logger logrus.FieldLogger, cfg Config, se
 
 	return otelProvider
 }
+
+// MustTracerProvider ensures setting up an otel TracerProvider succeeds. The
+// TracerProvider exports to the same endpoint as MustProvider, with the same
+// service resource attributes, and is registered as the global
+// TracerProvider along with the W3C trace context propagator.
+//
+// Callers must Shutdown the returned TracerProvider to flush pending spans.
+// nolint: lll
+func MustTracerProvider(ctx context.Context, logger logrus.FieldLogger, cfg Config, service, serviceNamespace, stage, serviceInstanceID string, opts ...otel.Option) *sdktrace.TracerProvider {
+	logger.Info("setting up tracer provider")
+
+	endpoint := mustEndpoint(logger, cfg)
+
+	var attrs []attribute.KeyValue
+	if cfg.Cloud != "" {
+		attrs = append(attrs, otel.Cloud(cfg.Cloud))
+	}
+
+	allOpts := []otel.Option{ //nolint:prealloc // composite literal clarity over prealloc
+		otel.WithOpenTelemetryStandardService(service, serviceNamespace, serviceInstanceID),
+		otel.WithServiceStandard(service),
+		otel.WithEnvironmentStandard(stage),
+		otel.WithAttributes(attrs...),
+		otel.WithHTTPEndpointTraceExporter(endpoint.String()),
+	}
+	allOpts = append(allOpts, opts...)
+
+	tp, err := otel.NewTracerProvider(ctx, service, allOpts...)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	otelapi.SetTracerProvider(tp)
+	otelapi.SetTextMapPropagator(propagation.TraceContext{})
+
+	return tp
+}
+
+func mustEndpoint(logger logrus.FieldLogger, cfg Config) *url.URL {
+	// to allow transitioning between endpoints first check if the newer env is present
+	endpoint := cfg.EndpointURL
+	if endpoint == nil {
+		endpoint = cfg.CollectorURL
+	}
+
+	if endpoint == nil {
+		logger.Fatal("provider collectorURL cannot be nil")
+	}
+
+	return endpoint
+}
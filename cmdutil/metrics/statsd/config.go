@@ -0,0 +1,12 @@
+package statsd
+
+import "time"
+
+// Config is a reusable configuration struct that contains the necessary
+// environment variables to setup a StatsD metrics.Provider.
+type Config struct {
+	Enabled       bool          `env:"ENABLE_STATSD"`
+	Addr          string        `env:"STATSD_ADDR,default=127.0.0.1:8125"`
+	FlushInterval time.Duration `env:"STATSD_FLUSH_INTERVAL,default=10s"`
+	MaxPacketSize int           `env:"STATSD_MAX_PACKET_SIZE,default=1432"`
+}
@@ -0,0 +1,30 @@
+// Package statsd provides helpers for setting up StatsD metrics reporting.
+package statsd
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/heroku/x/go-kit/metrics/provider/statsd"
+)
+
+// MustProvider ensures setting up a statsd.Provider succeeds. Metrics are
+// prefixed with prefix, if set, and tagged with tags. Start sending metrics
+// with the provider's Run method.
+func MustProvider(logger logrus.FieldLogger, cfg Config, prefix string, tags []string, opts ...statsd.Option) *statsd.Provider {
+	logger.WithField("addr", cfg.Addr).Info("setting up statsd provider")
+
+	allOpts := []statsd.Option{ //nolint:prealloc // composite literal clarity over prealloc
+		statsd.WithPrefix(prefix),
+		statsd.WithTags(tags...),
+		statsd.WithFlushInterval(cfg.FlushInterval),
+		statsd.WithMaxPacketSize(cfg.MaxPacketSize),
+	}
+	allOpts = append(allOpts, opts...)
+
+	p, err := statsd.New(cfg.Addr, allOpts...)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	return p
+}
@@ -1,21 +1,28 @@
 package service
 
 import (
+	"context"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/joeshaw/envdecode"
 	"github.com/oklog/run"
 	"github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/heroku/x/cmdutil"
 	"github.com/heroku/x/cmdutil/debug"
 	"github.com/heroku/x/cmdutil/metrics"
+	"github.com/heroku/x/cmdutil/metrics/otel"
+	"github.com/heroku/x/cmdutil/metrics/statsd"
 	"github.com/heroku/x/cmdutil/rollbar"
 	"github.com/heroku/x/cmdutil/signals"
 	"github.com/heroku/x/cmdutil/svclog"
 	xmetrics "github.com/heroku/x/go-kit/metrics"
 	"github.com/heroku/x/go-kit/metrics/l2met"
+	"github.com/heroku/x/go-kit/metrics/multiprovider"
 )
 
 // Standard is a standard service.
@@ -26,6 +33,13 @@ type Standard struct {
 	Deploy          string
 	Logger          logrus.FieldLogger
 	MetricsProvider xmetrics.Provider
+
+	// TracerProvider is set when tracing is enabled, either with
+	// EnableTracing or $ENABLE_OTEL_TRACING. Pass it to hmiddleware.Tracing
+	// and grpcserver.Tracing to create spans for incoming requests.
+	TracerProvider trace.TracerProvider
+
+	tracerProvider *sdktrace.TracerProvider
 }
 
 // New Standard Service with logging, rollbar, metrics, debugging, common signal
@@ -66,12 +80,44 @@ func New(appConfig interface{}, ofs ...OptionFunc) *Standard {
 		Logger: logger,
 	}
 
-	if !sc.Metrics.OTEL.Enabled || sc.Metrics.L2MetOverrideEnabled {
+	var providers []xmetrics.Provider
+
+	if sc.Metrics.StatsD.Enabled {
+		sp := statsd.MustProvider(logger, sc.Metrics.StatsD, sc.Metrics.Prefix, sc.Metrics.DefaultTags)
+		providers = append(providers, sp)
+		s.Add(cmdutil.NewContextServer(sp.Run))
+	}
+
+	if !(sc.Metrics.OTEL.Enabled || sc.Metrics.StatsD.Enabled) || sc.Metrics.L2MetOverrideEnabled {
 		l2met := l2met.New(logger)
-		s.MetricsProvider = l2met
+		providers = append(providers, l2met)
 		s.Add(cmdutil.NewContextServer(l2met.Run))
 	}
 
+	switch {
+	case len(providers) == 1:
+		s.MetricsProvider = providers[0]
+	case len(providers) > 1:
+		s.MetricsProvider = multiprovider.New(providers...)
+	}
+
+	if o.enableTracing || sc.Metrics.OTEL.TracingEnabled {
+		namespace := sc.Metrics.OTEL.ServiceNamespace
+		if namespace == "" {
+			namespace = sc.Logger.Deploy
+		}
+		s.tracerProvider = otel.MustTracerProvider(
+			context.Background(),
+			logger,
+			sc.Metrics.OTEL,
+			sc.Logger.AppName,
+			namespace,
+			sc.Logger.Deploy,
+			sc.Logger.Dyno,
+		)
+		s.TracerProvider = s.tracerProvider
+	}
+
 	s.Add(debug.New(logger, sc.Debug))
 	s.Add(signals.NewServer(logger, syscall.SIGINT, syscall.SIGTERM))
 
@@ -103,15 +149,23 @@ func (s *Standard) Run() {
 		s.MetricsProvider.Stop()
 	}
 
+	if s.tracerProvider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.tracerProvider.Shutdown(ctx); err != nil {
+			s.Logger.WithError(err).Warn("shutting down tracer provider")
+		}
+		cancel()
+	}
+
 	if err != nil {
 		s.Logger.WithError(err).Fatal()
 	}
 }
 
 type options struct {
-	customMetricsSuffix     string
-	skipMetricsSuffix       bool
-	enableOpenCensusTracing bool
+	customMetricsSuffix string
+	skipMetricsSuffix   bool
+	enableTracing       bool
 }
 
 // OptionFunc is a function that modifies internal service options.
@@ -134,14 +188,22 @@ func CustomMetricsSuffix(s string) OptionFunc {
 	}
 }
 
-// EnableOpenCensusTracing on the Service by registering and starting an open
-// census agent exporter.
-func EnableOpenCensusTracing() OptionFunc {
+// EnableTracing on the Service by registering an OTLP TracerProvider exporting
+// to the otel collector configured in the environment.
+func EnableTracing() OptionFunc {
 	return func(o *options) {
-		o.enableOpenCensusTracing = true
+		o.enableTracing = true
 	}
 }
 
+// EnableOpenCensusTracing on the Service.
+//
+// Deprecated: OpenCensus is no longer supported, this is an alias of
+// EnableTracing.
+func EnableOpenCensusTracing() OptionFunc {
+	return EnableTracing()
+}
+
 // metricsSuffixFromDyno determines a metrics suffix from the process part of
 // $DYNO. If $DYNO indicates a "web" process, the suffix is "server". If $DYNO
 // is empty, so is the suffix.
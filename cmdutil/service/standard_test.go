@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/heroku/x/cmdutil/service"
+	"github.com/heroku/x/go-kit/metrics/provider/statsd"
 )
 
 func TestNewNoConfig(t *testing.T) {
@@ -46,6 +47,26 @@ func TestNewCustomConfig(t *testing.T) {
 	}
 }
 
+func TestNewStatsD(t *testing.T) {
+	setupStandardConfig(t)
+	t.Setenv("ENABLE_STATSD", "true")
+	t.Setenv("STATSD_ADDR", "127.0.0.1:8125")
+
+	s := service.New(nil)
+
+	if _, ok := s.MetricsProvider.(*statsd.Provider); !ok {
+		t.Fatalf("want statsd metrics provider, got %T", s.MetricsProvider)
+	}
+
+	t.Setenv("METRICS_ENABLE_L2MET_OVERRIDE", "true")
+
+	s = service.New(nil)
+
+	if _, ok := s.MetricsProvider.(*statsd.Provider); ok {
+		t.Fatal("want statsd and l2met metrics providers, got statsd only")
+	}
+}
+
 func setupStandardConfig(t *testing.T) {
 	os.Setenv("APP_NAME", "test-app")
 	os.Setenv("DEPLOY", "test")
@@ -3,6 +3,7 @@ package svclog
 
 import (
 	"io"
+	"net/url"
 	"strings"
 	"time"
 
@@ -17,10 +18,15 @@ type Config struct {
 	SpaceID  string `env:"SPACE_ID"`
 	Dyno     string `env:"DYNO"`
 	LogLevel string `env:"LOG_LEVEL,default=info"`
+
+	// SyslogURL, if set, additionally sends log lines to a syslog drain,
+	// e.g. syslog+tls://logs.example.com:6514.
+	SyslogURL *url.URL `env:"LOG_SYSLOG_URL"`
 }
 
 // NewLogger returns a new logger that includes app and deploy key/value pairs
-// in each log line.
+// in each log line. If cfg.SyslogURL is set, log lines are also sent to it,
+// until CloseSyslog is called. The syslog drain is only set up once.
 func NewLogger(cfg Config) logrus.FieldLogger {
 	logger := logrus.WithFields(logrus.Fields{
 		"app":    cfg.AppName,
@@ -36,6 +42,8 @@ func NewLogger(cfg Config) logrus.FieldLogger {
 	if l, err := logrus.ParseLevel(cfg.LogLevel); err == nil {
 		logrus.SetLevel(l)
 	}
+
+	setupSyslog(logger, cfg)
 	return logger
 }
 
@@ -0,0 +1,252 @@
+package svclog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/heroku/x/logplex/encoding"
+	"github.com/heroku/x/logplex/syslog"
+	"github.com/heroku/x/tlsconfig"
+)
+
+// facilityLocal7 is the facility used by logplex for application logs.
+const facilityLocal7 = 23
+
+// Syslog severities, see RFC 5424 section 6.2.1.
+const (
+	severityEmergency = 0
+	severityCritical  = 2
+	severityError     = 3
+	severityWarning   = 4
+	severityInfo      = 6
+	severityDebug     = 7
+)
+
+// DefaultSyslogBufferSize is the default number of entries a SyslogHook
+// buffers before dropping them.
+const DefaultSyslogBufferSize = 1000
+
+// MessageWriter writes syslog messages. *syslog.Writer implements it.
+type MessageWriter interface {
+	Write(msg encoding.Message) error
+}
+
+// SyslogHook is a logrus.Hook sending log entries to a MessageWriter as
+// syslog messages.
+//
+// The entry's "app" and "dyno" fields are used as the message's Application
+// and Process, and its level is mapped to the message's severity.
+//
+// Messages are written in the background, so that a slow drain doesn't slow
+// down logging. Entries are dropped while the buffer is full.
+type SyslogHook struct {
+	w         MessageWriter
+	hostname  string
+	formatter logrus.Formatter
+
+	mu      sync.RWMutex
+	msgs    chan encoding.Message
+	closed  bool
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+// NewSyslogHook returns a SyslogHook writing to w, buffering up to
+// DefaultSyslogBufferSize entries. Entries are formatted with a
+// logrus.TextFormatter without timestamps, which the messages carry anyway.
+//
+// Close must be called to stop writing to w.
+func NewSyslogHook(w MessageWriter, hostname string) *SyslogHook {
+	h := &SyslogHook{
+		w:         w,
+		hostname:  hostname,
+		formatter: &logrus.TextFormatter{DisableTimestamp: true, DisableColors: true},
+		msgs:      make(chan encoding.Message, DefaultSyslogBufferSize),
+		done:      make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+// Levels implements logrus.Hook. All levels are sent.
+func (h *SyslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook. The entry is dropped if the buffer is full or
+// the hook is closed.
+func (h *SyslogHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	msg := encoding.Message{
+		Timestamp:    entry.Time,
+		Hostname:     h.hostname,
+		Application:  stringField(entry, "app"),
+		Process:      stringField(entry, "dyno"),
+		Message:      strings.TrimSuffix(string(line), "\n"),
+		Version:      1,
+		Priority:     facilityLocal7*8 + syslogSeverity(entry.Level),
+		RFCCompliant: true,
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		h.dropped.Add(1)
+		return nil
+	}
+	select {
+	case h.msgs <- msg:
+	default:
+		h.dropped.Add(1)
+	}
+	return nil
+}
+
+// Dropped returns the number of entries which were dropped, or failed to be
+// written.
+func (h *SyslogHook) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// Close writes the buffered entries, then closes the MessageWriter if it is
+// an io.Closer.
+func (h *SyslogHook) Close() error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.msgs)
+	}
+	h.mu.Unlock()
+
+	<-h.done
+	if c, ok := h.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (h *SyslogHook) run() {
+	defer close(h.done)
+
+	for msg := range h.msgs {
+		if err := h.w.Write(msg); err != nil {
+			h.dropped.Add(1)
+		}
+	}
+}
+
+func stringField(entry *logrus.Entry, key string) string {
+	v, ok := entry.Data[key]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func syslogSeverity(level logrus.Level) uint8 {
+	switch level {
+	case logrus.PanicLevel:
+		return severityEmergency
+	case logrus.FatalLevel:
+		return severityCritical
+	case logrus.ErrorLevel:
+		return severityError
+	case logrus.WarnLevel:
+		return severityWarning
+	case logrus.InfoLevel:
+		return severityInfo
+	default:
+		return severityDebug
+	}
+}
+
+// newSyslogWriter returns a syslog.Writer for a syslog:// or syslog+tls://
+// drain URL.
+func newSyslogWriter(u *url.URL) (*syslog.Writer, error) {
+	cfg := syslog.WriterConfig{Addr: u.Host}
+
+	switch u.Scheme {
+	case "syslog":
+	case "syslog+tls":
+		cfg.TLS = tlsconfig.New()
+		cfg.TLS.ServerName = u.Hostname()
+		cfg.TLSPresets = []func(*tls.Config){tlsconfig.Default}
+	default:
+		return nil, fmt.Errorf("unsupported syslog url scheme %q", u.Scheme)
+	}
+
+	return syslog.NewWriter(cfg), nil
+}
+
+var (
+	syslogMu   sync.Mutex
+	syslogHook *SyslogHook
+)
+
+// setupSyslog adds a SyslogHook for cfg.SyslogURL to the default logrus
+// logger, unless one was already added. Setup is skipped if SyslogURL isn't
+// set.
+func setupSyslog(logger logrus.FieldLogger, cfg Config) {
+	if cfg.SyslogURL == nil {
+		return
+	}
+
+	syslogMu.Lock()
+	defer syslogMu.Unlock()
+
+	if syslogHook != nil {
+		if hasHook(logrus.StandardLogger(), syslogHook) {
+			return
+		}
+		// the hook was removed from the logger
+		syslogHook.Close() //nolint:errcheck
+		syslogHook = nil
+	}
+
+	w, err := newSyslogWriter(cfg.SyslogURL)
+	if err != nil {
+		logger.WithError(err).WithField("at", "skipping-syslog").Warn()
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	syslogHook = NewSyslogHook(w, hostname)
+	logrus.AddHook(syslogHook)
+}
+
+// CloseSyslog sends the buffered log entries to the syslog drain set up by
+// NewLogger, if any, and closes the connection to it. Entries logged
+// afterwards aren't sent to the drain anymore.
+func CloseSyslog() error {
+	syslogMu.Lock()
+	defer syslogMu.Unlock()
+
+	if syslogHook == nil {
+		return nil
+	}
+	return syslogHook.Close()
+}
+
+func hasHook(logger *logrus.Logger, hook logrus.Hook) bool {
+	for _, hooks := range logger.Hooks {
+		for _, h := range hooks {
+			if h == hook {
+				return true
+			}
+		}
+	}
+	return false
+}
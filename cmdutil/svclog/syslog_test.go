@@ -0,0 +1,192 @@
+package svclog
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/heroku/x/logplex/encoding"
+	"github.com/heroku/x/logplex/syslog"
+)
+
+type messageRecorder struct {
+	msgs []encoding.Message
+}
+
+func (r *messageRecorder) Write(msg encoding.Message) error {
+	r.msgs = append(r.msgs, msg)
+	return nil
+}
+
+func TestSyslogHook(t *testing.T) {
+	tests := map[string]struct {
+		level        log.Level
+		wantPriority uint8
+	}{
+		"debug": {level: log.DebugLevel, wantPriority: 191},
+		"info":  {level: log.InfoLevel, wantPriority: 190},
+		"warn":  {level: log.WarnLevel, wantPriority: 188},
+		"error": {level: log.ErrorLevel, wantPriority: 187},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &messageRecorder{}
+			logger := log.New()
+			logger.SetLevel(log.DebugLevel)
+			hook := NewSyslogHook(r, "host")
+			logger.AddHook(hook)
+
+			logger.WithFields(log.Fields{"app": "sushi", "dyno": "web.1"}).Log(test.level, "message")
+			if err := hook.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(r.msgs) != 1 {
+				t.Fatalf("want 1 message, got %d", len(r.msgs))
+			}
+			msg := r.msgs[0]
+			if msg.Priority != test.wantPriority {
+				t.Errorf("want priority %d, got %d", test.wantPriority, msg.Priority)
+			}
+			if msg.Hostname != "host" || msg.Application != "sushi" || msg.Process != "web.1" {
+				t.Errorf("want host/sushi/web.1, got %s/%s/%s", msg.Hostname, msg.Application, msg.Process)
+			}
+			if !strings.Contains(msg.Message, `msg=message`) || strings.Contains(msg.Message, "time=") {
+				t.Errorf("want formatted entry without time, got %q", msg.Message)
+			}
+			if strings.HasSuffix(msg.Message, "\n") {
+				t.Errorf("want message without trailing newline, got %q", msg.Message)
+			}
+			if _, err := encoding.Encode(msg); err != nil {
+				t.Errorf("want encodable message, got %v", err)
+			}
+		})
+	}
+}
+
+// blockingWriter blocks writes until unblock is closed.
+type blockingWriter struct {
+	unblock chan struct{}
+	closed  bool
+}
+
+func (w *blockingWriter) Write(encoding.Message) error {
+	<-w.unblock
+	return nil
+}
+
+func (w *blockingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestSyslogHookDropsWhenFull(t *testing.T) {
+	w := &blockingWriter{unblock: make(chan struct{})}
+	hook := NewSyslogHook(w, "host")
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(hook)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < DefaultSyslogBufferSize+10; i++ {
+			logger.Info("message")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want logging not to block on a slow writer")
+	}
+	if got := hook.Dropped(); got < 9 {
+		t.Fatalf("want at least 9 dropped entries, got %d", got)
+	}
+
+	close(w.unblock)
+	if err := hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.closed {
+		t.Fatal("want writer closed")
+	}
+
+	dropped := hook.Dropped()
+	logger.Info("message")
+	if got := hook.Dropped(); got != dropped+1 {
+		t.Fatalf("want entries dropped after closing, got %d dropped, want %d", got, dropped+1)
+	}
+}
+
+func TestLoggerSendsToSyslogURL(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := make(chan encoding.Message, 10)
+	srv := syslog.NewServer(syslog.ServerConfig{}, func(_ net.Addr, msg encoding.Message) {
+		msgs <- msg
+	})
+	go srv.Serve(ln) //nolint:errcheck
+	defer srv.Stop(nil)
+
+	std := log.StandardLogger()
+	hooks := std.ReplaceHooks(make(log.LevelHooks))
+	defer std.ReplaceHooks(hooks)
+
+	defer CloseSyslog() //nolint:errcheck
+
+	cfg := Config{
+		AppName:   "sushi",
+		Deploy:    "production",
+		Dyno:      "web.1",
+		SyslogURL: &url.URL{Scheme: "syslog", Host: ln.Addr().String()},
+	}
+	NewLogger(cfg)
+	logger := NewLogger(cfg)
+	if got := len(std.Hooks[log.InfoLevel]); got != 1 {
+		t.Fatalf("want syslog hook added once, got %d hooks", got)
+	}
+	logger.Info("message")
+
+	select {
+	case msg := <-msgs:
+		if msg.Application != "sushi" || msg.Process != "web.1" {
+			t.Errorf("want sushi/web.1, got %s/%s", msg.Application, msg.Process)
+		}
+		if !strings.Contains(msg.Message, "msg=message") {
+			t.Errorf("want message to contain msg=message, got %q", msg.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for syslog message")
+	}
+}
+
+func TestNewSyslogWriter(t *testing.T) {
+	tests := map[string]bool{
+		"syslog://logs.example.com:514":      true,
+		"syslog+tls://logs.example.com:6514": true,
+		"https://logs.example.com":           false,
+	}
+
+	for raw, wantOK := range tests {
+		t.Run(raw, func(t *testing.T) {
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := newSyslogWriter(u); (err == nil) != wantOK {
+				t.Fatalf("want ok %v, got error %v", wantOK, err)
+			}
+		})
+	}
+}
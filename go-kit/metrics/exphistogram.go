@@ -0,0 +1,350 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// DefaultExponentialHistogramMaxSize is the default maximum number of
+	// buckets of positive, and of negative, observations of an
+	// ExponentialHistogram. It is the OpenTelemetry SDK's default.
+	DefaultExponentialHistogramMaxSize = 160
+
+	// MaxExponentialHistogramScale is the scale at which an
+	// ExponentialHistogram starts, and its highest precision. It is the
+	// OpenTelemetry SDK's default.
+	MaxExponentialHistogramScale = 20
+
+	minExponentialHistogramScale = -10
+)
+
+// ExponentialHistogramProvider is implemented by Providers natively supporting
+// exponential histograms. See NewExponentialHistogramFrom.
+type ExponentialHistogramProvider interface {
+	NewExponentialHistogram(name string, maxSize int) metrics.Histogram
+}
+
+// NewExponentialHistogramFrom returns a histogram from p with exponential
+// buckets, so its quantiles don't depend on hand tuned bucket boundaries. If p
+// doesn't implement ExponentialHistogramProvider, a regular histogram with
+// maxSize buckets is returned instead.
+func NewExponentialHistogramFrom(p Provider, name string, maxSize int) metrics.Histogram {
+	if ep, ok := p.(ExponentialHistogramProvider); ok {
+		return ep.NewExponentialHistogram(name, maxSize)
+	}
+	return p.NewHistogram(name, maxSize)
+}
+
+// ExponentialHistogram is a sparse base-2 exponential histogram, with the same
+// bucket layout as OpenTelemetry's exponential histograms.
+//
+// At scale s, bucket i holds the observations in (2^(i/2^s), 2^((i+1)/2^s)].
+// The histogram starts at the highest scale, and halves its resolution
+// whenever it would otherwise need more than its maximum size of buckets to
+// cover the observations. Quantiles are therefore accurate to a relative
+// error of at most 2^(1/2^s)-1, whatever the range of the observations. The
+// count, sum, min and max are exact.
+//
+// It is safe for concurrent use.
+type ExponentialHistogram struct {
+	maxSize int
+
+	mu sync.Mutex
+	s  ExponentialHistogramSnapshot
+}
+
+// NewExponentialHistogram returns an empty ExponentialHistogram with at most
+// maxSize buckets of positive, and of negative, observations. If maxSize is
+// not positive, DefaultExponentialHistogramMaxSize is used.
+func NewExponentialHistogram(maxSize int) *ExponentialHistogram {
+	if maxSize <= 0 {
+		maxSize = DefaultExponentialHistogramMaxSize
+	}
+	return &ExponentialHistogram{maxSize: maxSize, s: emptySnapshot()}
+}
+
+// Observe records v. NaN and infinite values are ignored.
+func (h *ExponentialHistogram) Observe(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.s.observe(v) {
+		h.s.fit(h.maxSize)
+	}
+}
+
+// Merge adds the observations of s to h.
+func (h *ExponentialHistogram) Merge(s ExponentialHistogramSnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.s = h.s.Merge(s)
+	h.s.fit(h.maxSize)
+}
+
+// Snapshot returns a copy of the histogram's current state.
+func (h *ExponentialHistogram) Snapshot() ExponentialHistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.s.clone()
+}
+
+// SnapshotReset returns the histogram's current state, and resets it.
+func (h *ExponentialHistogram) SnapshotReset() ExponentialHistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.s
+	h.s = emptySnapshot()
+	return s
+}
+
+// ExponentialHistogramSnapshot is the state of an ExponentialHistogram.
+// Snapshots from histograms with different scales can be merged.
+type ExponentialHistogramSnapshot struct {
+	Scale     int32
+	Count     uint64
+	Sum       float64
+	Min       float64
+	Max       float64
+	ZeroCount uint64
+
+	// Positive and Negative map bucket indexes to the number of
+	// observations in the bucket. Negative observations are bucketed by
+	// their absolute value.
+	Positive map[int32]uint64
+	Negative map[int32]uint64
+}
+
+func emptySnapshot() ExponentialHistogramSnapshot {
+	return ExponentialHistogramSnapshot{
+		Scale:    MaxExponentialHistogramScale,
+		Positive: make(map[int32]uint64),
+		Negative: make(map[int32]uint64),
+	}
+}
+
+// Mean of the observations, or 0 if there were none.
+func (s ExponentialHistogramSnapshot) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Quantile returns an estimate of the q quantile of the observations, with
+// 0 <= q <= 1, or 0 if there were none. The estimate is interpolated within
+// its bucket and bounded by the exact min and max.
+func (s ExponentialHistogramSnapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return s.Min
+	}
+	if q >= 1 {
+		return s.Max
+	}
+
+	rank := q * float64(s.Count)
+	var seen float64
+
+	// negative buckets in ascending order of values are in descending
+	// order of indexes
+	for _, i := range sortedIndexes(s.Negative, true) {
+		n := float64(s.Negative[i])
+		if seen+n >= rank {
+			lower, upper := bucketBounds(i, s.Scale)
+			return s.clamp(-(upper - (upper-lower)*(rank-seen)/n))
+		}
+		seen += n
+	}
+
+	if seen += float64(s.ZeroCount); seen >= rank {
+		return s.clamp(0)
+	}
+
+	for _, i := range sortedIndexes(s.Positive, false) {
+		n := float64(s.Positive[i])
+		if seen+n >= rank {
+			lower, upper := bucketBounds(i, s.Scale)
+			return s.clamp(lower + (upper-lower)*(rank-seen)/n)
+		}
+		seen += n
+	}
+
+	return s.Max
+}
+
+// Merge returns the combined observations of s and o, at the lowest scale of
+// the two. Neither s nor o are modified.
+func (s ExponentialHistogramSnapshot) Merge(o ExponentialHistogramSnapshot) ExponentialHistogramSnapshot {
+	m := s.clone()
+	if o.Count == 0 {
+		return m
+	}
+	if m.Count == 0 {
+		return o.clone()
+	}
+
+	if o.Scale < m.Scale {
+		m.downscale(m.Scale - o.Scale)
+	}
+	change := o.Scale - m.Scale
+
+	for i, n := range o.Positive {
+		m.Positive[i>>change] += n
+	}
+	for i, n := range o.Negative {
+		m.Negative[i>>change] += n
+	}
+
+	m.Min = math.Min(m.Min, o.Min)
+	m.Max = math.Max(m.Max, o.Max)
+	m.Count += o.Count
+	m.Sum += o.Sum
+	m.ZeroCount += o.ZeroCount
+
+	return m
+}
+
+// observe records v, and reports whether a new bucket was needed for it.
+func (s *ExponentialHistogramSnapshot) observe(v float64) bool {
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.Count++
+	s.Sum += v
+
+	buckets := s.Positive
+	switch {
+	case v < 0:
+		buckets, v = s.Negative, -v
+	case v == 0:
+		s.ZeroCount++
+		return false
+	}
+
+	i := mapToIndex(v, s.Scale)
+	n, ok := buckets[i]
+	buckets[i] = n + 1
+	return !ok
+}
+
+// fit downscales s until its positive and negative buckets each span at
+// most maxSize indexes.
+func (s *ExponentialHistogramSnapshot) fit(maxSize int) {
+	for s.Scale > minExponentialHistogramScale &&
+		(indexSpan(s.Positive) > maxSize || indexSpan(s.Negative) > maxSize) {
+		s.downscale(1)
+	}
+}
+
+// downscale lowers the scale of s by change, merging its buckets.
+func (s *ExponentialHistogramSnapshot) downscale(change int32) {
+	if change <= 0 {
+		return
+	}
+	s.Positive = downscaleBuckets(s.Positive, change)
+	s.Negative = downscaleBuckets(s.Negative, change)
+	s.Scale -= change
+}
+
+func (s ExponentialHistogramSnapshot) clone() ExponentialHistogramSnapshot {
+	c := s
+	c.Positive = make(map[int32]uint64, len(s.Positive))
+	for i, n := range s.Positive {
+		c.Positive[i] = n
+	}
+	c.Negative = make(map[int32]uint64, len(s.Negative))
+	for i, n := range s.Negative {
+		c.Negative[i] = n
+	}
+	return c
+}
+
+func (s ExponentialHistogramSnapshot) clamp(v float64) float64 {
+	return math.Max(s.Min, math.Min(s.Max, v))
+}
+
+func downscaleBuckets(buckets map[int32]uint64, change int32) map[int32]uint64 {
+	d := make(map[int32]uint64, len(buckets))
+	for i, n := range buckets {
+		d[i>>change] += n
+	}
+	return d
+}
+
+// indexSpan returns the number of indexes between the lowest and highest
+// bucket indexes, inclusive.
+func indexSpan(buckets map[int32]uint64) int {
+	if len(buckets) == 0 {
+		return 0
+	}
+
+	first := true
+	var lo, hi int32
+	for i := range buckets {
+		if first || i < lo {
+			lo = i
+		}
+		if first || i > hi {
+			hi = i
+		}
+		first = false
+	}
+	return int(hi-lo) + 1
+}
+
+func sortedIndexes(buckets map[int32]uint64, desc bool) []int32 {
+	idx := make([]int32, 0, len(buckets))
+	for i := range buckets {
+		idx = append(idx, i)
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		if desc {
+			return idx[a] > idx[b]
+		}
+		return idx[a] < idx[b]
+	})
+	return idx
+}
+
+// mapToIndex returns the index of the bucket holding v > 0 at scale.
+func mapToIndex(v float64, scale int32) int32 {
+	frac, exp := math.Frexp(v) // v = frac * 2^exp, with frac in [0.5, 1)
+
+	// exact powers of two are the inclusive upper bound of a bucket
+	pow2 := frac == 0.5
+
+	if scale <= 0 {
+		e := exp - 1 // floor(log2(v))
+		if pow2 {
+			e--
+		}
+		return int32(e >> -scale) //nolint:gosec // exponents of float64 fit in an int32
+	}
+
+	if pow2 {
+		return int32((exp-1)<<scale) - 1 //nolint:gosec // exponents of float64 fit in an int32
+	}
+	return int32(math.Ceil(math.Log2(v)*math.Exp2(float64(scale)))) - 1
+}
+
+// bucketBounds returns the lower and upper bounds of bucket i at scale.
+func bucketBounds(i, scale int32) (lower, upper float64) {
+	base := math.Exp2(-float64(scale))
+	return math.Exp2(float64(i) * base), math.Exp2(float64(i+1) * base)
+}
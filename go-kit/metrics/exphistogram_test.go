@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+func TestMapToIndex(t *testing.T) {
+	tests := map[string]struct {
+		v     float64
+		scale int32
+		want  int32
+	}{
+		"one at scale 0":            {v: 1, scale: 0, want: -1},
+		"just above one at scale 0": {v: 1.0001, scale: 0, want: 0},
+		"two at scale 0":            {v: 2, scale: 0, want: 0},
+		"three at scale 0":          {v: 3, scale: 0, want: 1},
+		"four at scale -1":          {v: 4, scale: -1, want: 0},
+		"five at scale -1":          {v: 5, scale: -1, want: 1},
+		"half at scale -1":          {v: 0.5, scale: -1, want: -1},
+		"two at scale 1":            {v: 2, scale: 1, want: 1},
+		"sqrt two at scale 1":       {v: math.Sqrt2 + 1e-9, scale: 1, want: 1},
+		"just below sqrt2 scale 1":  {v: 1.4, scale: 1, want: 0},
+		"power of two at scale 20":  {v: 8, scale: 20, want: 3<<20 - 1},
+		"quarter at scale 3":        {v: 0.25, scale: 3, want: -17},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := mapToIndex(test.v, test.scale); got != test.want {
+				t.Fatalf("want %d, got %d", test.want, got)
+			}
+
+			lower, upper := bucketBounds(test.want, test.scale)
+			if test.v <= lower || test.v > upper {
+				t.Fatalf("want %v in (%v, %v]", test.v, lower, upper)
+			}
+		})
+	}
+}
+
+func TestExponentialHistogramQuantiles(t *testing.T) {
+	tests := map[string]struct {
+		values func(i int) float64
+		n      int
+	}{
+		"uniform":          {n: 10000, values: func(i int) float64 { return float64(i + 1) }},
+		"wide range":       {n: 10000, values: func(i int) float64 { return math.Pow(10, float64(i%9)) * float64(i+1) }},
+		"negative":         {n: 1000, values: func(i int) float64 { return float64(i - 500) }},
+		"small":            {n: 1000, values: func(i int) float64 { return float64(i+1) / 1e6 }},
+		"random lognormal": {n: 10000, values: func(int) float64 { return math.Exp(rand.NormFloat64() * 3) }},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := NewExponentialHistogram(0)
+			values := make([]float64, test.n)
+			for i := range values {
+				values[i] = test.values(i)
+				h.Observe(values[i])
+			}
+			sort.Float64s(values)
+
+			s := h.Snapshot()
+			if s.Count != uint64(test.n) || s.Min != values[0] || s.Max != values[len(values)-1] {
+				t.Fatalf("want exact count, min and max, got %d, %v, %v", s.Count, s.Min, s.Max)
+			}
+			if got := indexSpan(s.Positive); got > DefaultExponentialHistogramMaxSize {
+				t.Fatalf("want at most %d buckets, got %d", DefaultExponentialHistogramMaxSize, got)
+			}
+
+			// relative error at the final scale, with some slack for the
+			// nearest rank of the exact quantile
+			maxErr := math.Exp2(math.Exp2(-float64(s.Scale))) - 1 + 0.01
+			for _, q := range []float64{0.01, 0.25, 0.5, 0.95, 0.99} {
+				want := values[int(math.Ceil(q*float64(test.n)))-1]
+				got := s.Quantile(q)
+				if math.Abs(got-want) > math.Abs(want)*maxErr+1e-9 {
+					t.Errorf("p%v: want %v within %.2f%%, got %v", q*100, want, maxErr*100, got)
+				}
+			}
+		})
+	}
+}
+
+func TestExponentialHistogramMerge(t *testing.T) {
+	narrow := NewExponentialHistogram(0)
+	wide := NewExponentialHistogram(0)
+	all := NewExponentialHistogram(0)
+
+	for i := 1; i <= 1000; i++ {
+		narrow.Observe(float64(i%10 + 1))
+		wide.Observe(float64(i * i))
+		all.Observe(float64(i%10 + 1))
+		all.Observe(float64(i * i))
+	}
+
+	a, b := narrow.Snapshot(), wide.Snapshot()
+	if a.Scale <= b.Scale {
+		t.Fatalf("want narrow histogram at a higher scale, got %d and %d", a.Scale, b.Scale)
+	}
+
+	for name, m := range map[string]ExponentialHistogramSnapshot{"ab": a.Merge(b), "ba": b.Merge(a)} {
+		want := all.Snapshot()
+		if m.Count != want.Count || m.Sum != want.Sum || m.Min != want.Min || m.Max != want.Max {
+			t.Errorf("%s: want %d %v %v %v, got %d %v %v %v", name, want.Count, want.Sum, want.Min, want.Max, m.Count, m.Sum, m.Min, m.Max)
+		}
+		if m.Scale != b.Scale {
+			t.Errorf("%s: want scale %d, got %d", name, b.Scale, m.Scale)
+		}
+		for _, q := range []float64{0.25, 0.5, 0.99} {
+			if got, want := m.Quantile(q), want.Quantile(q); math.Abs(got-want) > want*0.1 {
+				t.Errorf("%s: p%v: want about %v, got %v", name, q*100, want, got)
+			}
+		}
+	}
+
+	// merging doesn't modify the snapshots
+	if got := a.Merge(b); got.Count != 2000 || a.Count != 1000 || b.Count != 1000 {
+		t.Fatal("want snapshots to be unmodified")
+	}
+
+	// histograms merge into themselves, and stay within their max size
+	small := NewExponentialHistogram(4)
+	small.Merge(b)
+	if s := small.Snapshot(); s.Count != b.Count || indexSpan(s.Positive) > 4 {
+		t.Fatalf("want all observations within 4 buckets, got %d in %d", s.Count, indexSpan(s.Positive))
+	}
+}
+
+func TestExponentialHistogramSnapshotReset(t *testing.T) {
+	h := NewExponentialHistogram(0)
+	h.Observe(1)
+	h.Observe(0)
+	h.Observe(math.NaN())
+	h.Observe(math.Inf(1))
+
+	s := h.SnapshotReset()
+	if s.Count != 2 || s.ZeroCount != 1 || s.Mean() != 0.5 {
+		t.Fatalf("want 2 observations, one of them zero, got %+v", s)
+	}
+	if s := h.Snapshot(); s.Count != 0 || len(s.Positive) != 0 || s.Scale != MaxExponentialHistogramScale || s.Quantile(0.5) != 0 {
+		t.Fatalf("want an empty histogram, got %+v", s)
+	}
+}
@@ -0,0 +1,64 @@
+package l2met
+
+import (
+	"github.com/go-kit/kit/metrics"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+)
+
+// histogram summarizes the observations made since it was last reset.
+// Percentiles are estimated from an exponential histogram of the
+// observations. Count, min, max and mean are exact.
+type histogram struct {
+	name string
+	lvs  []string
+	h    *xmetrics.ExponentialHistogram
+}
+
+func newHistogram(name string, maxSize int) *histogram {
+	return &histogram{name: name, h: xmetrics.NewExponentialHistogram(maxSize)}
+}
+
+// With implements metrics.Histogram. The returned histogram starts out empty
+// and isn't reported.
+func (h *histogram) With(labelValues ...string) metrics.Histogram {
+	lvs := make([]string, 0, len(h.lvs)+len(labelValues))
+	lvs = append(lvs, h.lvs...)
+	lvs = append(lvs, labelValues...)
+	return &histogram{name: h.name, lvs: lvs, h: xmetrics.NewExponentialHistogram(0)}
+}
+
+// Observe implements metrics.Histogram.
+func (h *histogram) Observe(value float64) {
+	h.h.Observe(value)
+}
+
+// summary of a histogram's observations.
+type summary struct {
+	count         int64
+	min, max, sum float64
+	p50, p95, p99 float64
+}
+
+func (s summary) mean() float64 {
+	return s.sum / float64(s.count)
+}
+
+// summaryReset returns a summary of the observations made since the last
+// reset, and resets the histogram. ok is false if there were none.
+func (h *histogram) summaryReset() (s summary, ok bool) {
+	snap := h.h.SnapshotReset()
+	if snap.Count == 0 {
+		return s, false
+	}
+
+	return summary{
+		count: int64(snap.Count), //nolint:gosec // counts fit in an int64
+		min:   snap.Min,
+		max:   snap.Max,
+		sum:   snap.Sum,
+		p50:   snap.Quantile(0.50),
+		p95:   snap.Quantile(0.95),
+		p99:   snap.Quantile(0.99),
+	}, true
+}
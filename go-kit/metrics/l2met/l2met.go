@@ -13,23 +13,33 @@ import (
 	xmetrics "github.com/heroku/x/go-kit/metrics"
 )
 
+var _ xmetrics.ExponentialHistogramProvider = (*Provider)(nil)
+
 // Provider provides constructors for creating, tracking, and logging metrics.
+//
+// Each time metrics are logged, counters are reported as count#, gauges as
+// measure# and cardinality counters as unique# estimates. Histograms are
+// summarized as sample# count, min, max and mean, along with measure# p50,
+// p95 and p99 estimated from exponential histograms. Counters, histograms
+// and cardinality counters are reset after being logged.
 type Provider struct {
-	logger     logrus.FieldLogger
-	mu         sync.Mutex
-	counters   map[string]*generic.Counter
-	gauges     map[string]*generic.Gauge
-	histograms map[string]*generic.Histogram
+	logger              logrus.FieldLogger
+	mu                  sync.Mutex
+	counters            map[string]*generic.Counter
+	gauges              map[string]*generic.Gauge
+	histograms          map[string]*histogram
+	cardinalityCounters map[string]*xmetrics.HLLCounter
 }
 
 // New returns a metrics provider for constructing and tracing metrics to
 // report.
 func New(l logrus.FieldLogger) *Provider {
 	return &Provider{
-		logger:     l,
-		counters:   map[string]*generic.Counter{},
-		gauges:     map[string]*generic.Gauge{},
-		histograms: map[string]*generic.Histogram{},
+		logger:              l,
+		counters:            map[string]*generic.Counter{},
+		gauges:              map[string]*generic.Gauge{},
+		histograms:          map[string]*histogram{},
+		cardinalityCounters: map[string]*xmetrics.HLLCounter{},
 	}
 }
 
@@ -59,8 +69,27 @@ func (p *Provider) NewGauge(name string) metrics.Gauge {
 	return p.gauges[name]
 }
 
-// NewHistogram implements Provider.
-func (p *Provider) NewHistogram(name string, buckets int) metrics.Histogram {
+// NewHistogram implements Provider. Histograms are exponential histograms
+// with xmetrics.DefaultExponentialHistogramMaxSize buckets; buckets is
+// ignored, as go-kit's bucket counts are too small for accurate percentiles.
+func (p *Provider) NewHistogram(name string, _ int) metrics.Histogram {
+	return p.histogram(name, 0)
+}
+
+// NewExplicitHistogram implements Provider. The distribution is ignored, as
+// histograms are exponential histograms with the default number of buckets.
+func (p *Provider) NewExplicitHistogram(name string, _ xmetrics.DistributionFunc) metrics.Histogram {
+	return p.histogram(name, 0)
+}
+
+// NewExponentialHistogram implements xmetrics.ExponentialHistogramProvider.
+func (p *Provider) NewExponentialHistogram(name string, maxSize int) metrics.Histogram {
+	return p.histogram(name, maxSize)
+}
+
+// histogram returns the histogram named name, creating it with at most
+// maxSize buckets if needed.
+func (p *Provider) histogram(name string, maxSize int) *histogram {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -68,27 +97,21 @@ func (p *Provider) NewHistogram(name string, buckets int) metrics.Histogram {
 		return h
 	}
 
-	p.histograms[name] = generic.NewHistogram(name, buckets)
+	p.histograms[name] = newHistogram(name, maxSize)
 	return p.histograms[name]
 }
 
-func (p *Provider) NewExplicitHistogram(name string, fn xmetrics.DistributionFunc) metrics.Histogram {
-	boundaries := fn()
+// NewCardinalityCounter implements the heroku/x metrics Provider interface.
+func (p *Provider) NewCardinalityCounter(name string) xmetrics.CardinalityCounter {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if h, ok := p.histograms[name]; ok {
-		return h
+	if c, ok := p.cardinalityCounters[name]; ok {
+		return c
 	}
 
-	p.histograms[name] = generic.NewHistogram(name, len(boundaries)-1)
-	return p.histograms[name]
-}
-
-// NewCardinalityCounter implements the heroku/x metrics Provider interface. It
-// is not implemented for the log-based provider.
-func (*Provider) NewCardinalityCounter(string) xmetrics.CardinalityCounter {
-	panic("unimplemented")
+	p.cardinalityCounters[name] = xmetrics.NewHLLCounter(name)
+	return p.cardinalityCounters[name]
 }
 
 // Run starts the provider, logging metrics once per minute until the context
@@ -122,13 +145,23 @@ func (p *Provider) log() {
 	}
 
 	for name, h := range p.histograms {
-		v := h.Quantile(0.99)
+		s, ok := h.summaryReset()
 		// no measurement to report
-		if v < 0 {
+		if !ok {
 			continue
 		}
 
-		data["measure#"+name+".p99"] = v
+		data["sample#"+name+".count"] = s.count
+		data["sample#"+name+".min"] = s.min
+		data["sample#"+name+".max"] = s.max
+		data["sample#"+name+".mean"] = s.mean()
+		data["measure#"+name+".p50"] = s.p50
+		data["measure#"+name+".p95"] = s.p95
+		data["measure#"+name+".p99"] = s.p99
+	}
+
+	for name, c := range p.cardinalityCounters {
+		data["unique#"+name] = c.EstimateReset()
 	}
 
 	p.logger.WithFields(data).Info()
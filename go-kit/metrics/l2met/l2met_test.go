@@ -0,0 +1,143 @@
+package l2met
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/heroku/x/testing/testlog"
+)
+
+func TestProviderLog(t *testing.T) {
+	logger, hook := testlog.New()
+	p := New(logger)
+
+	p.NewCounter("requests").Add(3)
+	p.NewGauge("connections").Set(7)
+
+	h := p.NewHistogram("duration", 50)
+	for i := 1; i <= 100; i++ {
+		h.Observe(float64(i))
+	}
+
+	u := p.NewCardinalityCounter("users")
+	for _, user := range []string{"a", "b", "a", "c"} {
+		u.Insert([]byte(user))
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := logrus.Fields{
+		"at":                    "metrics",
+		"count#requests":        float64(3),
+		"measure#connections":   float64(7),
+		"sample#duration.count": int64(100),
+		"sample#duration.min":   float64(1),
+		"sample#duration.max":   float64(100),
+		"sample#duration.mean":  50.5,
+		"measure#duration.p50":  float64(50),
+		"measure#duration.p95":  float64(95),
+		"measure#duration.p99":  float64(99),
+		"unique#users":          uint64(3),
+	}
+	checkFields(t, hook.LastEntry().Data, want, "measure#duration.p50", "measure#duration.p95", "measure#duration.p99")
+
+	// histograms and cardinality counters are reset once logged
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	data := hook.LastEntry().Data
+	if _, ok := data["sample#duration.count"]; ok {
+		t.Errorf("want no histogram summary without observations, got %v", data)
+	}
+	if got := data["unique#users"]; got != uint64(0) {
+		t.Errorf("want unique#users reset to 0, got %v", got)
+	}
+}
+
+func TestHistogramSummary(t *testing.T) {
+	const n = 100000
+
+	h := newHistogram("h", 0)
+	for i := 0; i < n; i++ {
+		h.Observe(float64(i))
+	}
+
+	s, ok := h.summaryReset()
+	if !ok {
+		t.Fatal("want summary")
+	}
+	if s.count != n || s.min != 0 || s.max != n-1 {
+		t.Fatalf("want exact count, min and max, got %+v", s)
+	}
+	if s.p50 > s.p95 || s.p95 > s.p99 || s.p99 > s.max {
+		t.Fatalf("want ordered percentiles, got %+v", s)
+	}
+	for got, want := range map[float64]float64{s.p50: n / 2, s.p95: n * 0.95, s.p99: n * 0.99} {
+		if !approx(got, want) {
+			t.Errorf("want percentile close to %v, got %v", want, got)
+		}
+	}
+
+	if _, ok := h.summaryReset(); ok {
+		t.Fatal("want no summary once reset")
+	}
+}
+
+func TestHistogramBucketsIgnored(t *testing.T) {
+	logger, _ := testlog.New()
+	p := New(logger)
+
+	h := p.NewHistogram("h", 10).(*histogram)
+	for i := 1; i <= 1000; i++ {
+		h.Observe(float64(i))
+	}
+
+	s, _ := h.summaryReset()
+	if !approx(s.p50, 500) {
+		t.Fatalf("want p50 close to 500 with the default number of buckets, got %v", s.p50)
+	}
+}
+
+func TestExplicitHistogramSharesName(t *testing.T) {
+	logger, _ := testlog.New()
+	p := New(logger)
+
+	a := p.NewHistogram("h", 10)
+	b := p.NewExplicitHistogram("h", func() []float64 { return []float64{1, 2} })
+	if a != b {
+		t.Fatal("want histograms of the same name to be shared")
+	}
+}
+
+// checkFields checks that got has the fields of want. The estimated fields
+// only need to be close to the wanted values.
+func checkFields(t *testing.T, got, want logrus.Fields, estimated ...string) {
+	t.Helper()
+
+	for k, v := range want {
+		if slices.Contains(estimated, k) {
+			if g, _ := got[k].(float64); !approx(g, v.(float64)) {
+				t.Errorf("want %s close to %v, got %v", k, v, got[k])
+			}
+			continue
+		}
+		if got[k] != v {
+			t.Errorf("want %s=%v (%T), got %v (%T)", k, v, v, got[k], got[k])
+		}
+	}
+	for k := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("unexpected field %s=%v", k, got[k])
+		}
+	}
+}
+
+// approx reports whether got is within 5% of want.
+func approx(got, want float64) bool {
+	return math.Abs(got-want) <= want*0.05
+}
@@ -0,0 +1,290 @@
+package lowcard
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/sharder"
+)
+
+// Rule rewrites the values of a label. It returns the value to use instead,
+// and false if the label should be dropped.
+type Rule interface {
+	Rewrite(value string) (string, bool)
+}
+
+// RuleFunc adapts a function to a Rule.
+type RuleFunc func(value string) (string, bool)
+
+// Rewrite implements Rule.
+func (f RuleFunc) Rewrite(value string) (string, bool) {
+	return f(value)
+}
+
+// Config declares how NewRewritingProvider rewrites the labels of
+// instruments.
+//
+//	lowcard.Config{
+//		Rules: map[string]lowcard.Rule{
+//			"status":   lowcard.StatusClass(),
+//			"path":     lowcard.CollapseNumericSegments(":id"),
+//			"app":      lowcard.HashBuckets(16),
+//			"customer": lowcard.CapValues(100, "other"),
+//		},
+//		Default: lowcard.Drop(),
+//	}
+type Config struct {
+	// Rules rewriting the values of labels, by label key.
+	Rules map[string]Rule
+
+	// Default rewrites the values of labels without a rule. Labels without
+	// a rule are kept as is if Default is nil.
+	Default Rule
+}
+
+// Keep returns a Rule keeping values as is.
+func Keep() Rule {
+	return RuleFunc(func(value string) (string, bool) {
+		return value, true
+	})
+}
+
+// Drop returns a Rule dropping labels.
+func Drop() Rule {
+	return RuleFunc(func(string) (string, bool) {
+		return "", false
+	})
+}
+
+// StatusClass returns a Rule rewriting HTTP status codes to their class, e.g.
+// "404" to "4xx". Values which aren't status codes are rewritten to
+// "unknown".
+func StatusClass() Rule {
+	return RuleFunc(func(value string) (string, bool) {
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 100 || code > 599 {
+			return "unknown", true
+		}
+		return strconv.Itoa(code/100) + "xx", true
+	})
+}
+
+// CollapseNumericSegments returns a Rule replacing the numeric segments of
+// paths with placeholder, e.g. "/apps/123/dynos" with "/apps/:id/dynos".
+func CollapseNumericSegments(placeholder string) Rule {
+	return RuleFunc(func(value string) (string, bool) {
+		segments := strings.Split(value, "/")
+		for i, s := range segments {
+			if isNumeric(s) {
+				segments[i] = placeholder
+			}
+		}
+		return strings.Join(segments, "/"), true
+	})
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// HashBuckets returns a Rule replacing values with the index, between 0 and
+// n exclusive, of the bucket they hash to. It panics if n < 1.
+func HashBuckets(n int) Rule {
+	s := sharder.New(n, sharder.WithLockFreeHasher())
+
+	return RuleFunc(func(value string) (string, bool) {
+		return strconv.Itoa(s.Index(value)), true
+	})
+}
+
+// CapValues returns a Rule keeping the first limit distinct values it sees
+// as is, and rewriting any other value to fallback. Used by
+// NewRewritingProvider, the limit applies to each metric and label
+// separately.
+func CapValues(limit int, fallback string) Rule {
+	return &capValues{limit: limit, fallback: fallback, seen: make(map[labelScope]map[string]struct{})}
+}
+
+// scopedRule is implemented by rules whose rewrites depend on the metric and
+// label they are applied to.
+type scopedRule interface {
+	rewriteScoped(scope labelScope, value string) (string, bool)
+}
+
+// labelScope identifies a label of a metric.
+type labelScope struct {
+	metric, key string
+}
+
+type capValues struct {
+	limit    int
+	fallback string
+
+	mu   sync.Mutex
+	seen map[labelScope]map[string]struct{}
+}
+
+func (c *capValues) Rewrite(value string) (string, bool) {
+	return c.rewriteScoped(labelScope{}, value)
+}
+
+func (c *capValues) rewriteScoped(scope labelScope, value string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen, ok := c.seen[scope]
+	if !ok {
+		seen = make(map[string]struct{})
+		c.seen[scope] = seen
+	}
+
+	if _, ok := seen[value]; ok {
+		return value, true
+	}
+	if len(seen) < c.limit {
+		seen[value] = struct{}{}
+		return value, true
+	}
+	return c.fallback, true
+}
+
+// rewrite returns the labelValues of the named metric rewritten according to
+// cfg. Like sanitizeLabels, malformed label values are dropped altogether.
+func (cfg Config) rewrite(name string, labelValues []string) []string {
+	if len(labelValues)%2 != 0 {
+		return []string{}
+	}
+
+	rewritten := make([]string, 0, len(labelValues))
+	for i := 0; i < len(labelValues); i += 2 {
+		rule, ok := cfg.Rules[labelValues[i]]
+		if !ok {
+			rule = cfg.Default
+		}
+		if rule == nil {
+			rewritten = append(rewritten, labelValues[i], labelValues[i+1])
+			continue
+		}
+
+		var (
+			v    string
+			keep bool
+		)
+		if sr, ok := rule.(scopedRule); ok {
+			v, keep = sr.rewriteScoped(labelScope{metric: name, key: labelValues[i]}, labelValues[i+1])
+		} else {
+			v, keep = rule.Rewrite(labelValues[i+1])
+		}
+		if keep {
+			rewritten = append(rewritten, labelValues[i], v)
+		}
+	}
+	return rewritten
+}
+
+// NewRewritingProvider wraps p so that the label values passed to the With
+// methods of its instruments, including cardinality counters, are rewritten
+// according to cfg.
+func NewRewritingProvider(p xmetrics.Provider, cfg Config) xmetrics.Provider {
+	return rewritingProvider{Provider: p, cfg: cfg}
+}
+
+var (
+	_ xmetrics.Provider                     = rewritingProvider{}
+	_ xmetrics.ExponentialHistogramProvider = rewritingProvider{}
+)
+
+type rewritingProvider struct {
+	xmetrics.Provider
+	cfg Config
+}
+
+// NewCounter implements xmetrics.Provider.
+func (p rewritingProvider) NewCounter(name string) metrics.Counter {
+	return rewritingCounter{Counter: p.Provider.NewCounter(name), name: name, cfg: p.cfg}
+}
+
+// NewGauge implements xmetrics.Provider.
+func (p rewritingProvider) NewGauge(name string) metrics.Gauge {
+	return rewritingGauge{Gauge: p.Provider.NewGauge(name), name: name, cfg: p.cfg}
+}
+
+// NewHistogram implements xmetrics.Provider.
+func (p rewritingProvider) NewHistogram(name string, buckets int) metrics.Histogram {
+	return rewritingHistogram{Histogram: p.Provider.NewHistogram(name, buckets), name: name, cfg: p.cfg}
+}
+
+// NewExplicitHistogram implements xmetrics.Provider.
+func (p rewritingProvider) NewExplicitHistogram(name string, fn xmetrics.DistributionFunc) metrics.Histogram {
+	return rewritingHistogram{Histogram: p.Provider.NewExplicitHistogram(name, fn), name: name, cfg: p.cfg}
+}
+
+// NewExponentialHistogram implements xmetrics.ExponentialHistogramProvider.
+func (p rewritingProvider) NewExponentialHistogram(name string, maxSize int) metrics.Histogram {
+	return rewritingHistogram{Histogram: xmetrics.NewExponentialHistogramFrom(p.Provider, name, maxSize), name: name, cfg: p.cfg}
+}
+
+// NewCardinalityCounter implements xmetrics.Provider.
+func (p rewritingProvider) NewCardinalityCounter(name string) xmetrics.CardinalityCounter {
+	return rewritingCardinalityCounter{CardinalityCounter: p.Provider.NewCardinalityCounter(name), name: name, cfg: p.cfg}
+}
+
+type rewritingCounter struct {
+	metrics.Counter
+	name string
+	cfg  Config
+}
+
+// With implements metrics.Counter.
+func (c rewritingCounter) With(labelValues ...string) metrics.Counter {
+	c.Counter = c.Counter.With(c.cfg.rewrite(c.name, labelValues)...)
+	return c
+}
+
+type rewritingGauge struct {
+	metrics.Gauge
+	name string
+	cfg  Config
+}
+
+// With implements metrics.Gauge.
+func (g rewritingGauge) With(labelValues ...string) metrics.Gauge {
+	g.Gauge = g.Gauge.With(g.cfg.rewrite(g.name, labelValues)...)
+	return g
+}
+
+type rewritingHistogram struct {
+	metrics.Histogram
+	name string
+	cfg  Config
+}
+
+// With implements metrics.Histogram.
+func (h rewritingHistogram) With(labelValues ...string) metrics.Histogram {
+	h.Histogram = h.Histogram.With(h.cfg.rewrite(h.name, labelValues)...)
+	return h
+}
+
+type rewritingCardinalityCounter struct {
+	xmetrics.CardinalityCounter
+	name string
+	cfg  Config
+}
+
+// With implements xmetrics.CardinalityCounter.
+func (c rewritingCardinalityCounter) With(labelValues ...string) xmetrics.CardinalityCounter {
+	c.CardinalityCounter = c.CardinalityCounter.With(c.cfg.rewrite(c.name, labelValues)...)
+	return c
+}
@@ -0,0 +1,121 @@
+package lowcard
+
+import (
+	"strconv"
+	"testing"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+)
+
+func TestRules(t *testing.T) {
+	tests := map[string]struct {
+		rule    Rule
+		values  []string
+		want    []string
+		dropped bool
+	}{
+		"keep":        {rule: Keep(), values: []string{"a"}, want: []string{"a"}},
+		"drop":        {rule: Drop(), values: []string{"a"}, dropped: true},
+		"statusClass": {rule: StatusClass(), values: []string{"200", "404", "503", "42", "abc"}, want: []string{"2xx", "4xx", "5xx", "unknown", "unknown"}},
+		"collapseNumericSegments": {
+			rule:   CollapseNumericSegments(":id"),
+			values: []string{"/apps/123/dynos/web.1", "/apps/abc", "/", "/v1/42"},
+			want:   []string{"/apps/:id/dynos/web.1", "/apps/abc", "/", "/v1/:id"},
+		},
+		"capValues": {rule: CapValues(2, "other"), values: []string{"a", "b", "c", "a", "d", "b"}, want: []string{"a", "b", "other", "a", "other", "b"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for i, v := range test.values {
+				got, ok := test.rule.Rewrite(v)
+				if ok == test.dropped {
+					t.Fatalf("%q: want dropped %v, got %v", v, test.dropped, !ok)
+				}
+				if !test.dropped && got != test.want[i] {
+					t.Errorf("%q: want %q, got %q", v, test.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestHashBuckets(t *testing.T) {
+	rule := HashBuckets(4)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		v := strconv.Itoa(i)
+		got, _ := rule.Rewrite(v)
+		if again, _ := rule.Rewrite(v); again != got {
+			t.Fatalf("want %q to always hash to %q, got %q", v, got, again)
+		}
+		seen[got] = true
+	}
+
+	if len(seen) != 4 {
+		t.Fatalf("want 4 buckets, got %v", seen)
+	}
+	for b := range seen {
+		if n, err := strconv.Atoi(b); err != nil || n < 0 || n >= 4 {
+			t.Fatalf("want buckets in 0..4, got %q", b)
+		}
+	}
+}
+
+func TestRewritingProvider(t *testing.T) {
+	tp := testmetrics.NewProvider(t)
+	p := NewRewritingProvider(tp, Config{
+		Rules: map[string]Rule{
+			"status": StatusClass(),
+			"path":   CollapseNumericSegments(":id"),
+			"user":   CapValues(1, "other"),
+		},
+		Default: Drop(),
+	})
+
+	p.NewCounter("requests").With("status", "201", "path", "/apps/1", "request_id", "abc").Add(1)
+	p.NewCounter("requests").With("status", "204").With("path", "/apps/2").Add(1)
+	tp.CheckCounter("requests", 2, "status", "2xx", "path", "/apps/:id")
+
+	p.NewGauge("connections").With("status", "500").Set(3)
+	tp.CheckGauge("connections", 3, "status", "5xx")
+
+	p.NewHistogram("duration", 0).With("path", "/apps/1").Observe(1)
+	p.NewExplicitHistogram("duration", xmetrics.TenSecondDistribution).With("path", "/apps/2").Observe(2)
+	xmetrics.NewExponentialHistogramFrom(p, "duration", 0).With("path", "/apps/3").Observe(3)
+	tp.CheckObservations("duration", []float64{1, 2, 3}, "path", "/apps/:id")
+
+	cc := p.NewCardinalityCounter("users")
+	cc.With("user", "a").Insert([]byte("a"))
+	cc.With("user", "b").Insert([]byte("b"))
+	cc.With("user", "c").Insert([]byte("c"))
+	tp.CheckCardinalityCounter("users", 1, "user", "a")
+	tp.CheckCardinalityCounter("users", 2, "user", "other")
+
+	// malformed labels are dropped
+	p.NewCounter("requests").With("status").Add(1)
+	tp.CheckCounter("requests", 1)
+}
+
+func TestRewritingProviderCapsValuesPerLabel(t *testing.T) {
+	tp := testmetrics.NewProvider(t)
+	p := NewRewritingProvider(tp, Config{Default: CapValues(1, "other")})
+
+	p.NewCounter("requests").With("app", "a", "space", "s1").Add(1)
+	p.NewCounter("requests").With("app", "b", "space", "s2").Add(1)
+	p.NewCounter("errors").With("app", "b").Add(1)
+
+	tp.CheckCounter("requests", 1, "app", "a", "space", "s1")
+	tp.CheckCounter("requests", 1, "app", "other", "space", "other")
+	tp.CheckCounter("errors", 1, "app", "b")
+}
+
+func TestRewritingProviderKeepsUnlistedLabels(t *testing.T) {
+	tp := testmetrics.NewProvider(t)
+	p := NewRewritingProvider(tp, Config{Rules: map[string]Rule{"status": StatusClass()}})
+
+	p.NewCounter("requests").With("status", "404", "method", "GET").Add(1)
+	tp.CheckCounter("requests", 1, "status", "4xx", "method", "GET")
+}
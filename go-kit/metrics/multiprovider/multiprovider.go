@@ -18,7 +18,10 @@ func New(providers ...metrics.Provider) metrics.Provider {
 }
 
 // multiProvider is also a metrics.Provider
-var _ metrics.Provider = &multiProvider{}
+var (
+	_ metrics.Provider                     = &multiProvider{}
+	_ metrics.ExponentialHistogramProvider = &multiProvider{}
+)
 
 type multiProvider struct {
 	providers []metrics.Provider
@@ -64,6 +67,17 @@ func (m *multiProvider) NewExplicitHistogram(name string, fn metrics.Distributio
 	return multi.NewHistogram(histograms...)
 }
 
+// NewExponentialHistogram returns a multi.Histogram composed from all the
+// given providers, using exponential histograms where supported.
+func (m *multiProvider) NewExponentialHistogram(name string, maxSize int) kitmetrics.Histogram {
+	histograms := make([]kitmetrics.Histogram, 0, len(m.providers))
+
+	for _, p := range m.providers {
+		histograms = append(histograms, metrics.NewExponentialHistogramFrom(p, name, maxSize))
+	}
+	return multi.NewHistogram(histograms...)
+}
+
 // NewCardinalityCounter implements metrics.CardinalityCounter.
 func (m *multiProvider) NewCardinalityCounter(name string) metrics.CardinalityCounter {
 	cardCounters := make([]metrics.CardinalityCounter, 0, len(m.providers))
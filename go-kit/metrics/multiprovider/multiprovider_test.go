@@ -3,6 +3,7 @@ package multiprovider
 import (
 	"testing"
 
+	"github.com/heroku/x/go-kit/metrics"
 	"github.com/heroku/x/go-kit/metrics/testmetrics"
 )
 
@@ -39,6 +40,20 @@ func TestHistograms(t *testing.T) {
 	p2.CheckObservationCount("foo", 1)
 }
 
+func TestExponentialHistograms(t *testing.T) {
+	p1 := testmetrics.NewProvider(t)
+	p2 := testmetrics.NewProvider(t)
+
+	p := New(p1, p2)
+	h := metrics.NewExponentialHistogramFrom(p, "foo", 20)
+	for i := 1; i <= 1000; i++ {
+		h.Observe(float64(i))
+	}
+
+	p1.CheckQuantile("foo", 0.99, 990, 990*0.05)
+	p2.CheckQuantile("foo", 0.99, 990, 990*0.05)
+}
+
 func TestStop(t *testing.T) {
 	p1 := testmetrics.NewProvider(t)
 	p2 := testmetrics.NewProvider(t)
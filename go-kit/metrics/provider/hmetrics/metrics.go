@@ -0,0 +1,253 @@
+package hmetrics
+
+import (
+	"strings"
+	"sync"
+
+	hll "github.com/axiomhq/hyperloglog"
+	"github.com/go-kit/kit/metrics"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	xhmetrics "github.com/heroku/x/hmetrics"
+)
+
+var (
+	_ metrics.Counter             = (*Counter)(nil)
+	_ metrics.Gauge               = (*Gauge)(nil)
+	_ metrics.Histogram           = (*Histogram)(nil)
+	_ xmetrics.CardinalityCounter = (*CardinalityCounter)(nil)
+)
+
+// Counter is a counter, submitted as the sum of its increments since the
+// last flush.
+type Counter struct {
+	p    *Provider
+	name string
+	lvs  []string
+	s    *series
+}
+
+// With implements metrics.Counter.
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	lvs := appendLabelValues(c.lvs, labelValues)
+	return &Counter{p: c.p, name: c.name, lvs: lvs, s: c.p.lookup(kindCounter, c.name, lvs, 0)}
+}
+
+// Add implements metrics.Counter.
+func (c *Counter) Add(delta float64) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	c.s.value += delta
+	c.s.dirty = true
+}
+
+// Gauge is a gauge, submitted with its last value on every flush once set.
+type Gauge struct {
+	p    *Provider
+	name string
+	lvs  []string
+	s    *series
+}
+
+// With implements metrics.Gauge.
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	lvs := appendLabelValues(g.lvs, labelValues)
+	return &Gauge{p: g.p, name: g.name, lvs: lvs, s: g.p.lookup(kindGauge, g.name, lvs, 0)}
+}
+
+// Set implements metrics.Gauge.
+func (g *Gauge) Set(value float64) {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	g.s.value = value
+	g.s.dirty = true
+}
+
+// Add implements metrics.Gauge.
+func (g *Gauge) Add(delta float64) {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	g.s.value += delta
+	g.s.dirty = true
+}
+
+// Histogram is a histogram, submitted as the count and quantiles of its
+// observations since the last flush.
+type Histogram struct {
+	p       *Provider
+	name    string
+	lvs     []string
+	maxSize int
+	s       *series
+}
+
+// With implements metrics.Histogram.
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	lvs := appendLabelValues(h.lvs, labelValues)
+	return &Histogram{p: h.p, name: h.name, lvs: lvs, maxSize: h.maxSize, s: h.p.lookup(kindHistogram, h.name, lvs, h.maxSize)}
+}
+
+// Observe implements metrics.Histogram.
+func (h *Histogram) Observe(value float64) {
+	h.s.histogram.Observe(value)
+}
+
+// CardinalityCounter is a cardinality counter, submitted as a gauge of the
+// distinct values inserted since the last flush.
+type CardinalityCounter struct {
+	p    *Provider
+	name string
+	lvs  []string
+	s    *series
+}
+
+// With implements xmetrics.CardinalityCounter.
+func (c *CardinalityCounter) With(labelValues ...string) xmetrics.CardinalityCounter {
+	lvs := appendLabelValues(c.lvs, labelValues)
+	return &CardinalityCounter{p: c.p, name: c.name, lvs: lvs, s: c.p.lookup(kindCardinality, c.name, lvs, 0)}
+}
+
+// Insert implements xmetrics.CardinalityCounter.
+func (c *CardinalityCounter) Insert(b []byte) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	c.s.hll.Insert(b)
+	c.s.dirty = true
+}
+
+type kind int
+
+const (
+	kindCounter kind = iota
+	kindGauge
+	kindHistogram
+	kindCardinality
+)
+
+type seriesKey struct {
+	kind kind
+	name string
+}
+
+// series aggregates a metric with a given set of labels.
+type series struct {
+	kind kind
+	name string
+
+	mu    sync.Mutex
+	value float64
+	dirty bool
+
+	histogram *xmetrics.ExponentialHistogram
+	hll       *hll.Sketch
+}
+
+func newSeries(k kind, name string, maxSize int) *series {
+	s := &series{kind: k, name: name}
+	switch k {
+	case kindHistogram:
+		s.histogram = xmetrics.NewExponentialHistogram(maxSize)
+	case kindCardinality:
+		s.hll = hll.New()
+	}
+	return s
+}
+
+// drain adds s to m and resets it. The returned function merges the drained
+// data back into s, e.g. if m couldn't be submitted.
+func (s *series) drain(m *xhmetrics.Metrics) (restore func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.kind {
+	case kindCounter:
+		if s.dirty {
+			v := s.value
+			m.Counters[s.name] += v
+			s.value, s.dirty = 0, false
+			return func() { s.add(v) }
+		}
+	case kindGauge:
+		if s.dirty {
+			m.Gauges[s.name] = s.value
+		}
+	case kindHistogram:
+		snap := s.histogram.SnapshotReset()
+		if snap.Count == 0 {
+			break
+		}
+		m.Counters[s.name+".count"] += float64(snap.Count)
+		m.Gauges[s.name+".p50"] = snap.Quantile(0.50)
+		m.Gauges[s.name+".p95"] = snap.Quantile(0.95)
+		m.Gauges[s.name+".p99"] = snap.Quantile(0.99)
+		m.Gauges[s.name+".max"] = snap.Max
+		return func() { s.histogram.Merge(snap) }
+	case kindCardinality:
+		if s.dirty {
+			sk := s.hll
+			m.Gauges[s.name] = float64(sk.Estimate())
+			s.hll, s.dirty = hll.New(), false
+			return func() { s.merge(sk) }
+		}
+	}
+	return func() {}
+}
+
+// add adds delta to the value of a counter.
+func (s *series) add(delta float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value += delta
+	s.dirty = true
+}
+
+// merge merges sk into the sketch of a cardinality counter.
+func (s *series) merge(sk *hll.Sketch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.hll.Merge(sk); err == nil {
+		s.dirty = true
+	}
+}
+
+func appendLabelValues(lvs, more []string) []string {
+	return append(append(make([]string, 0, len(lvs)+len(more)), lvs...), more...)
+}
+
+// lookup returns the series for name and labelValues, creating it with
+// maxSize if needed.
+func (p *Provider) lookup(k kind, name string, labelValues []string, maxSize int) *series {
+	if p.cfg.prefix != "" {
+		name = p.cfg.prefix + "." + name
+	}
+	name = key(name, labelValues)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sk := seriesKey{kind: k, name: name}
+	if s, ok := p.series[sk]; ok {
+		return s
+	}
+	s := newSeries(k, name, maxSize)
+	p.series[sk] = s
+	return s
+}
+
+// key returns the name of the metric with name and labelValues, e.g.
+// "requests.code:200" for name "requests" and label values "code", "200". A
+// missing label value is set to "unknown".
+func key(name string, labelValues []string) string {
+	if len(labelValues) == 0 {
+		return name
+	}
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues[:len(labelValues):len(labelValues)], "unknown")
+	}
+
+	parts := make([]string, 0, len(labelValues)/2)
+	for i := 0; i < len(labelValues); i += 2 {
+		parts = append(parts, labelValues[i]+":"+labelValues[i+1])
+	}
+	return name + "." + strings.Join(parts, ".")
+}
@@ -0,0 +1,237 @@
+// Package hmetrics provides a metrics Provider publishing metrics to Heroku
+// language metrics, alongside or instead of the Go runtime metrics reported
+// by the hmetrics package.
+//
+// Metrics are aggregated in memory and submitted as hmetrics.Metrics on an
+// interval by Run: counters as the sum of their increments, gauges as their
+// last value, histograms as a count counter and p50, p95, p99 and max gauges,
+// and cardinality counters as a gauge of the distinct values inserted. Labels
+// added with With are appended to names, e.g. "requests.code:200".
+//
+//	p, err := hmetrics.New(xhmetrics.DefaultEndpoint, hmetrics.WithRuntimeMetrics())
+//	...
+//	go p.Run(ctx)
+package hmetrics
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	xhmetrics "github.com/heroku/x/hmetrics"
+)
+
+const (
+	// DefaultFlushInterval is the default interval metrics are submitted
+	// at. It is the interval the hmetrics package reports at.
+	DefaultFlushInterval = 20 * time.Second
+
+	// DefaultTimeout is the default timeout of requests submitting metrics.
+	DefaultTimeout = 20 * time.Second
+)
+
+var (
+	_ xmetrics.Provider                     = (*Provider)(nil)
+	_ xmetrics.ExponentialHistogramProvider = (*Provider)(nil)
+	_ xhmetrics.Source                      = (*Provider)(nil)
+)
+
+type config struct {
+	prefix        string
+	flushInterval time.Duration
+	client        *http.Client
+	sources       []xhmetrics.Source
+	errHandler    xhmetrics.ErrHandler
+}
+
+// Option configures a Provider.
+type Option func(*config)
+
+// WithPrefix prefixes the names of all metrics with prefix.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithFlushInterval sets the interval Run submits metrics at.
+func WithFlushInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.flushInterval = d
+	}
+}
+
+// WithClient sets the client used to submit metrics.
+func WithClient(client *http.Client) Option {
+	return func(c *config) {
+		c.client = client
+	}
+}
+
+// WithSource adds the metrics gathered from s to those submitted.
+func WithSource(s xhmetrics.Source) Option {
+	return func(c *config) {
+		c.sources = append(c.sources, s)
+	}
+}
+
+// WithRuntimeMetrics adds the Go runtime metrics reported by the hmetrics
+// package to those submitted.
+func WithRuntimeMetrics() Option {
+	return WithSource(xhmetrics.NewRuntimeSource())
+}
+
+// WithErrHandler sets the handler of errors submitting metrics in Run. Run
+// continues if the handler returns nil, and returns the handler's error
+// otherwise. Errors are ignored by default.
+func WithErrHandler(ef xhmetrics.ErrHandler) Option {
+	return func(c *config) {
+		c.errHandler = ef
+	}
+}
+
+// Provider aggregates metrics and submits them to Heroku language metrics.
+type Provider struct {
+	cfg      config
+	endpoint string
+
+	mu     sync.Mutex
+	series map[seriesKey]*series
+
+	stopOnce sync.Once
+}
+
+// New returns a Provider submitting metrics to endpoint, usually
+// hmetrics.DefaultEndpoint. Start submitting metrics with Run.
+func New(endpoint string, opts ...Option) (*Provider, error) {
+	if endpoint == "" {
+		return nil, xhmetrics.HerokuMetricsURLUnset{}
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, errors.Wrap(err, "parsing endpoint")
+	}
+
+	cfg := config{
+		flushInterval: DefaultFlushInterval,
+		client:        &http.Client{Timeout: DefaultTimeout},
+		errHandler:    func(error) error { return nil },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Provider{
+		cfg:      cfg,
+		endpoint: endpoint,
+		series:   make(map[seriesKey]*series),
+	}, nil
+}
+
+// NewCounter implements Provider.
+func (p *Provider) NewCounter(name string) metrics.Counter {
+	return &Counter{p: p, name: name, s: p.lookup(kindCounter, name, nil, 0)}
+}
+
+// NewGauge implements Provider.
+func (p *Provider) NewGauge(name string) metrics.Gauge {
+	return &Gauge{p: p, name: name, s: p.lookup(kindGauge, name, nil, 0)}
+}
+
+// NewHistogram implements Provider. Observations are aggregated in an
+// exponential histogram with at most buckets buckets.
+func (p *Provider) NewHistogram(name string, buckets int) metrics.Histogram {
+	return &Histogram{p: p, name: name, maxSize: buckets, s: p.lookup(kindHistogram, name, nil, buckets)}
+}
+
+// NewExplicitHistogram implements Provider. The distribution is ignored, as
+// observations are aggregated in an exponential histogram.
+func (p *Provider) NewExplicitHistogram(name string, _ xmetrics.DistributionFunc) metrics.Histogram {
+	return p.NewHistogram(name, 0)
+}
+
+// NewExponentialHistogram implements xmetrics.ExponentialHistogramProvider.
+func (p *Provider) NewExponentialHistogram(name string, maxSize int) metrics.Histogram {
+	return p.NewHistogram(name, maxSize)
+}
+
+// NewCardinalityCounter implements Provider.
+func (p *Provider) NewCardinalityCounter(name string) xmetrics.CardinalityCounter {
+	return &CardinalityCounter{p: p, name: name, s: p.lookup(kindCardinality, name, nil, 0)}
+}
+
+// Run submits metrics every flush interval until ctx is canceled, or the
+// error handler returns an error.
+func (p *Provider) Run(ctx context.Context) error {
+	tick := time.NewTicker(p.cfg.flushInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+			if err := p.Flush(); err != nil {
+				if err := p.cfg.errHandler(err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Stop implements Provider. Pending metrics are submitted.
+func (p *Provider) Stop() {
+	p.stopOnce.Do(func() {
+		_ = p.Flush()
+	})
+}
+
+// Flush implements Provider, submitting the metrics aggregated since the
+// last flush along with those of the other sources. If submitting them
+// fails, the Provider's metrics are kept for the next flush.
+func (p *Provider) Flush() error {
+	var restores []func()
+	self := xhmetrics.SourceFunc(func(m *xhmetrics.Metrics) error {
+		restores = p.drain(m)
+		return nil
+	})
+
+	sources := append(append([]xhmetrics.Source(nil), p.cfg.sources...), self)
+	if err := xhmetrics.Submit(context.Background(), p.cfg.client, p.endpoint, sources...); err != nil {
+		for _, restore := range restores {
+			restore()
+		}
+		return errors.Wrap(err, "submitting metrics")
+	}
+	return nil
+}
+
+// Gather implements hmetrics.Source, draining the metrics aggregated since
+// the last call into m. It allows the Provider's metrics to be reported by
+// other means than Run and Flush.
+func (p *Provider) Gather(m *xhmetrics.Metrics) error {
+	p.drain(m)
+	return nil
+}
+
+// drain drains all series into m, returning the functions restoring them.
+func (p *Provider) drain(m *xhmetrics.Metrics) []func() {
+	p.mu.Lock()
+	all := make([]*series, 0, len(p.series))
+	for _, s := range p.series {
+		all = append(all, s)
+	}
+	p.mu.Unlock()
+
+	restores := make([]func(), 0, len(all))
+	for _, s := range all {
+		restores = append(restores, s.drain(m))
+	}
+	return restores
+}
@@ -0,0 +1,274 @@
+package hmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	xhmetrics "github.com/heroku/x/hmetrics"
+)
+
+// listen returns a test server accepting metrics, and a function returning
+// the payloads it received.
+func listen(t *testing.T, status int) (*httptest.Server, func() []xhmetrics.Metrics) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		received []xhmetrics.Metrics
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m xhmetrics.Metrics
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+		mu.Lock()
+		received = append(received, m)
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []xhmetrics.Metrics {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]xhmetrics.Metrics(nil), received...)
+	}
+}
+
+func TestProviderFlush(t *testing.T) {
+	srv, received := listen(t, http.StatusOK)
+
+	p, err := New(srv.URL, WithPrefix("svc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := p.NewCounter("requests")
+	c.Add(1)
+	c.Add(2)
+	c.With("code", "200").Add(1)
+	p.NewGauge("connections").Set(5)
+	h := p.NewHistogram("duration", 0)
+	for i := 1; i <= 100; i++ {
+		h.Observe(float64(i))
+	}
+	u := p.NewCardinalityCounter("users").With("space")
+	for _, user := range []string{"a", "b", "a"} {
+		u.Insert([]byte(user))
+	}
+	p.NewGauge("unset")
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := received()
+	if len(got) != 1 {
+		t.Fatalf("want 1 payload, got %d", len(got))
+	}
+	m := got[0]
+
+	wantCounters := map[string]float64{
+		"svc.requests":          3,
+		"svc.requests.code:200": 1,
+		"svc.duration.count":    100,
+	}
+	if len(m.Counters) != len(wantCounters) {
+		t.Errorf("want counters %v, got %v", wantCounters, m.Counters)
+	}
+	for k, v := range wantCounters {
+		if m.Counters[k] != v {
+			t.Errorf("want counter %s %v, got %v", k, v, m.Counters[k])
+		}
+	}
+
+	wantGauges := map[string]float64{
+		"svc.connections":         5,
+		"svc.users.space:unknown": 2,
+		"svc.duration.p50":        50,
+		"svc.duration.p95":        95,
+		"svc.duration.p99":        99,
+		"svc.duration.max":        100,
+	}
+	if len(m.Gauges) != len(wantGauges) {
+		t.Errorf("want gauges %v, got %v", wantGauges, m.Gauges)
+	}
+	for k, v := range wantGauges {
+		if math.Abs(m.Gauges[k]-v) > 1 {
+			t.Errorf("want gauge %s close to %v, got %v", k, v, m.Gauges[k])
+		}
+	}
+
+	// counters, histograms and cardinality counters reset; gauges don't
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	m = received()[1]
+	if len(m.Counters) != 0 {
+		t.Errorf("want no counters, got %v", m.Counters)
+	}
+	if len(m.Gauges) != 1 || m.Gauges["svc.connections"] != 5 {
+		t.Errorf("want only the connections gauge, got %v", m.Gauges)
+	}
+}
+
+func TestProviderSources(t *testing.T) {
+	srv, received := listen(t, http.StatusOK)
+
+	custom := xhmetrics.SourceFunc(func(m *xhmetrics.Metrics) error {
+		m.Gauges["custom"] = 1
+		return nil
+	})
+	p, err := New(srv.URL, WithRuntimeMetrics(), WithSource(custom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.NewCounter("requests").Add(1)
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := received()[0]
+	if _, ok := m.Gauges["go.routines"]; !ok {
+		t.Errorf("want runtime metrics, got %v", m.Gauges)
+	}
+	if m.Gauges["custom"] != 1 {
+		t.Errorf("want custom gauge, got %v", m.Gauges)
+	}
+	if m.Counters["requests"] != 1 {
+		t.Errorf("want requests counter, got %v", m.Counters)
+	}
+}
+
+func TestProviderFlushError(t *testing.T) {
+	srv, _ := listen(t, http.StatusInternalServerError)
+
+	p, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err == nil {
+		t.Fatal("want error, got nil")
+	}
+
+	failing := xhmetrics.SourceFunc(func(*xhmetrics.Metrics) error {
+		return errors.New("boom")
+	})
+	p, err = New(srv.URL, WithSource(failing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err == nil {
+		t.Fatal("want error, got nil")
+	}
+}
+
+func TestProviderFlushErrorKeepsMetrics(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		fail     = true
+		received []xhmetrics.Metrics
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m xhmetrics.Metrics
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received = append(received, m)
+	}))
+	defer srv.Close()
+
+	p, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.NewCounter("requests").Add(2)
+	p.NewHistogram("duration", 0).Observe(10)
+	p.NewCardinalityCounter("users").Insert([]byte("a"))
+	if err := p.Flush(); err == nil {
+		t.Fatal("want error, got nil")
+	}
+
+	p.NewCounter("requests").Add(1)
+	p.NewCardinalityCounter("users").Insert([]byte("b"))
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("want 1 payload, got %d", len(received))
+	}
+	m := received[0]
+	if got := m.Counters["requests"]; got != 3 {
+		t.Errorf("want requests=3, got %v", got)
+	}
+	if got := m.Counters["duration.count"]; got != 1 {
+		t.Errorf("want duration.count=1, got %v", got)
+	}
+	if got := m.Gauges["users"]; got != 2 {
+		t.Errorf("want users=2, got %v", got)
+	}
+}
+
+func TestProviderRun(t *testing.T) {
+	srv, _ := listen(t, http.StatusInternalServerError)
+
+	errBoom := errors.New("boom")
+	p, err := New(srv.URL,
+		WithFlushInterval(10*time.Millisecond),
+		WithErrHandler(func(error) error { return errBoom }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := p.Run(ctx); !errors.Is(err, errBoom) {
+		t.Fatalf("want %v, got %v", errBoom, err)
+	}
+}
+
+func TestNewEmptyEndpoint(t *testing.T) {
+	_, err := New("")
+	if !errors.As(err, &xhmetrics.HerokuMetricsURLUnset{}) {
+		t.Fatalf("want HerokuMetricsURLUnset, got %v", err)
+	}
+}
+
+func TestExponentialHistogram(t *testing.T) {
+	srv, received := listen(t, http.StatusOK)
+
+	p, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmetrics.NewExponentialHistogramFrom(p, "latency", 20).With("route", "/").Observe(1)
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := received()[0].Counters["latency.route:/.count"]; got != 1 {
+		t.Fatalf("want 1 observation, got %v", got)
+	}
+}
@@ -0,0 +1,130 @@
+package inmem
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/generic"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+)
+
+var (
+	_ metrics.Counter             = (*Counter)(nil)
+	_ metrics.Gauge               = (*Gauge)(nil)
+	_ metrics.Histogram           = (*Histogram)(nil)
+	_ xmetrics.CardinalityCounter = (*CardinalityCounter)(nil)
+)
+
+// Counter is a counter.
+type Counter struct {
+	p    *Provider
+	name string
+	lvs  []string
+	c    *generic.Counter
+}
+
+// With implements metrics.Counter.
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	return c.p.newCounter(c.name, appendLabelValues(c.lvs, labelValues)...)
+}
+
+// Add implements metrics.Counter.
+func (c *Counter) Add(delta float64) {
+	c.c.Add(delta)
+}
+
+// Gauge is a gauge.
+type Gauge struct {
+	p    *Provider
+	name string
+	lvs  []string
+	g    *generic.Gauge
+}
+
+// With implements metrics.Gauge.
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	return g.p.newGauge(g.name, appendLabelValues(g.lvs, labelValues)...)
+}
+
+// Set implements metrics.Gauge.
+func (g *Gauge) Set(value float64) {
+	g.g.Set(value)
+}
+
+// Add implements metrics.Gauge.
+func (g *Gauge) Add(delta float64) {
+	g.g.Add(delta)
+}
+
+// Histogram is a histogram.
+type Histogram struct {
+	p       *Provider
+	name    string
+	lvs     []string
+	buckets int
+	h       *generic.Histogram
+
+	mu    sync.Mutex
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// With implements metrics.Histogram.
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	return h.p.newHistogram(h.name, h.buckets, appendLabelValues(h.lvs, labelValues)...)
+}
+
+// Observe implements metrics.Histogram.
+func (h *Histogram) Observe(value float64) {
+	h.h.Observe(value)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.count++
+	h.sum += value
+	if h.count == 1 || value < h.min {
+		h.min = value
+	}
+	if h.count == 1 || value > h.max {
+		h.max = value
+	}
+}
+
+func (h *Histogram) snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	s := HistogramSnapshot{Count: h.count, Sum: h.sum, Min: h.min, Max: h.max}
+	h.mu.Unlock()
+
+	if s.Count > 0 {
+		s.P50 = h.h.Quantile(0.50)
+		s.P95 = h.h.Quantile(0.95)
+		s.P99 = h.h.Quantile(0.99)
+	}
+	return s
+}
+
+// CardinalityCounter is a cardinality counter.
+type CardinalityCounter struct {
+	p    *Provider
+	name string
+	lvs  []string
+	c    *xmetrics.HLLCounter
+}
+
+// With implements xmetrics.CardinalityCounter.
+func (c *CardinalityCounter) With(labelValues ...string) xmetrics.CardinalityCounter {
+	return c.p.newCardinalityCounter(c.name, appendLabelValues(c.lvs, labelValues)...)
+}
+
+// Insert implements xmetrics.CardinalityCounter.
+func (c *CardinalityCounter) Insert(b []byte) {
+	c.c.Insert(b)
+}
+
+func appendLabelValues(lvs, more []string) []string {
+	return append(append(make([]string, 0, len(lvs)+len(more)), lvs...), more...)
+}
@@ -0,0 +1,209 @@
+// Package inmem provides a metrics Provider aggregating metrics in memory, so
+// that their current values can be read from within the process, e.g. by
+// admin pages or canary checks.
+//
+// The Provider can be used on its own or alongside a reporting provider:
+//
+//	agg := inmem.New()
+//	p := multiprovider.New(l2met.New(logger), agg)
+//	...
+//	snap := agg.Snapshot()
+//	requests := snap.Counters[inmem.Key("requests", "code", "200")]
+package inmem
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/generic"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+)
+
+// DefaultBuckets is the number of buckets used to estimate the quantiles of
+// histograms created without a number of buckets.
+const DefaultBuckets = 50
+
+var _ xmetrics.Provider = (*Provider)(nil)
+
+// Provider aggregates metrics in memory. Metrics are cumulative and never
+// reset. Memory use is bounded by the number of distinct metrics: histogram
+// quantiles are estimated from a fixed number of buckets, and cardinality
+// counters are HyperLogLog sketches.
+type Provider struct {
+	mu           sync.RWMutex
+	counters     map[string]*Counter
+	gauges       map[string]*Gauge
+	histograms   map[string]*Histogram
+	cardCounters map[string]*CardinalityCounter
+}
+
+// New returns a Provider.
+func New() *Provider {
+	return &Provider{
+		counters:     make(map[string]*Counter),
+		gauges:       make(map[string]*Gauge),
+		histograms:   make(map[string]*Histogram),
+		cardCounters: make(map[string]*CardinalityCounter),
+	}
+}
+
+// NewCounter implements Provider.
+func (p *Provider) NewCounter(name string) metrics.Counter {
+	return p.newCounter(name)
+}
+
+func (p *Provider) newCounter(name string, labelValues ...string) *Counter {
+	k := Key(name, labelValues...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.counters[k]; !ok {
+		p.counters[k] = &Counter{p: p, name: name, lvs: labelValues, c: generic.NewCounter(name)}
+	}
+	return p.counters[k]
+}
+
+// NewGauge implements Provider.
+func (p *Provider) NewGauge(name string) metrics.Gauge {
+	return p.newGauge(name)
+}
+
+func (p *Provider) newGauge(name string, labelValues ...string) *Gauge {
+	k := Key(name, labelValues...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.gauges[k]; !ok {
+		p.gauges[k] = &Gauge{p: p, name: name, lvs: labelValues, g: generic.NewGauge(name)}
+	}
+	return p.gauges[k]
+}
+
+// NewHistogram implements Provider. buckets is the number of buckets used to
+// estimate quantiles.
+func (p *Provider) NewHistogram(name string, buckets int) metrics.Histogram {
+	if buckets <= 0 {
+		buckets = DefaultBuckets
+	}
+	return p.newHistogram(name, buckets)
+}
+
+// NewExplicitHistogram implements Provider. Quantiles are estimated using as
+// many buckets as the distribution defines.
+func (p *Provider) NewExplicitHistogram(name string, fn xmetrics.DistributionFunc) metrics.Histogram {
+	return p.NewHistogram(name, len(fn())-1)
+}
+
+func (p *Provider) newHistogram(name string, buckets int, labelValues ...string) *Histogram {
+	k := Key(name, labelValues...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.histograms[k]; !ok {
+		p.histograms[k] = &Histogram{p: p, name: name, lvs: labelValues, buckets: buckets, h: generic.NewHistogram(name, buckets)}
+	}
+	return p.histograms[k]
+}
+
+// NewCardinalityCounter implements Provider.
+func (p *Provider) NewCardinalityCounter(name string) xmetrics.CardinalityCounter {
+	return p.newCardinalityCounter(name)
+}
+
+func (p *Provider) newCardinalityCounter(name string, labelValues ...string) *CardinalityCounter {
+	k := Key(name, labelValues...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.cardCounters[k]; !ok {
+		p.cardCounters[k] = &CardinalityCounter{p: p, name: name, lvs: labelValues, c: xmetrics.NewHLLCounter(name)}
+	}
+	return p.cardCounters[k]
+}
+
+// Stop implements Provider.
+func (p *Provider) Stop() {}
+
+// Flush implements Provider. There is nothing to flush.
+func (p *Provider) Flush() error {
+	return nil
+}
+
+// Snapshot is a point in time copy of a Provider's metrics, keyed by Key.
+type Snapshot struct {
+	Counters            map[string]float64
+	Gauges              map[string]float64
+	Histograms          map[string]HistogramSnapshot
+	CardinalityCounters map[string]uint64
+}
+
+// HistogramSnapshot summarizes a histogram's observations. Quantiles are
+// estimates; the other fields are exact.
+type HistogramSnapshot struct {
+	Count         int64
+	Sum           float64
+	Min           float64
+	Max           float64
+	P50, P95, P99 float64
+}
+
+// Mean of the observations, or 0 if there were none.
+func (s HistogramSnapshot) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Snapshot returns the current value of all metrics. It is safe to call
+// concurrently with metrics being recorded.
+func (p *Provider) Snapshot() Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	s := Snapshot{
+		Counters:            make(map[string]float64, len(p.counters)),
+		Gauges:              make(map[string]float64, len(p.gauges)),
+		Histograms:          make(map[string]HistogramSnapshot, len(p.histograms)),
+		CardinalityCounters: make(map[string]uint64, len(p.cardCounters)),
+	}
+
+	for k, c := range p.counters {
+		s.Counters[k] = c.c.Value()
+	}
+	for k, g := range p.gauges {
+		s.Gauges[k] = g.g.Value()
+	}
+	for k, h := range p.histograms {
+		s.Histograms[k] = h.snapshot()
+	}
+	for k, c := range p.cardCounters {
+		s.CardinalityCounters[k] = c.c.Estimate()
+	}
+
+	return s
+}
+
+// Key returns the key of the metric with name and labelValues in a Snapshot,
+// e.g. "requests.code:200.method:GET" for name "requests" and label values
+// "code", "200", "method", "GET". A missing label value is set to "unknown".
+func Key(name string, labelValues ...string) string {
+	if len(labelValues) == 0 {
+		return name
+	}
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues[:len(labelValues):len(labelValues)], "unknown")
+	}
+
+	parts := make([]string, 0, len(labelValues)/2)
+	for i := 0; i < len(labelValues); i += 2 {
+		parts = append(parts, labelValues[i]+":"+labelValues[i+1])
+	}
+	return name + "." + strings.Join(parts, ".")
+}
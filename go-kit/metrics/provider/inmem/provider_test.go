@@ -0,0 +1,130 @@
+package inmem
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/heroku/x/go-kit/metrics/multiprovider"
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+)
+
+func TestSnapshot(t *testing.T) {
+	p := New()
+
+	c := p.NewCounter("requests")
+	c.Add(1)
+	c.With("code", "200").Add(2)
+	c.With("code", "200").Add(3)
+
+	g := p.NewGauge("connections")
+	g.Set(10)
+	g.Add(-4)
+
+	h := p.NewHistogram("duration", 0)
+	for i := 1; i <= 100; i++ {
+		h.Observe(float64(i))
+	}
+
+	u := p.NewCardinalityCounter("users").With("space")
+	for _, user := range []string{"a", "b", "a"} {
+		u.Insert([]byte(user))
+	}
+
+	s := p.Snapshot()
+
+	if got := s.Counters["requests"]; got != 1 {
+		t.Errorf("want requests 1, got %v", got)
+	}
+	if got := s.Counters[Key("requests", "code", "200")]; got != 5 {
+		t.Errorf("want requests.code:200 5, got %v", got)
+	}
+	if got := s.Gauges["connections"]; got != 6 {
+		t.Errorf("want connections 6, got %v", got)
+	}
+	if got := s.CardinalityCounters["users.space:unknown"]; got != 2 {
+		t.Errorf("want 2 unique users, got %v", got)
+	}
+
+	hs, ok := s.Histograms["duration"]
+	if !ok {
+		t.Fatal("want duration histogram")
+	}
+	if hs.Count != 100 || hs.Sum != 5050 || hs.Min != 1 || hs.Max != 100 || hs.Mean() != 50.5 {
+		t.Errorf("want exact count, sum, min, max and mean, got %+v", hs)
+	}
+	for q, got := range map[float64]float64{50: hs.P50, 95: hs.P95, 99: hs.P99} {
+		if math.Abs(got-q) > 2 {
+			t.Errorf("want p%v close to %v, got %v", q, q, got)
+		}
+	}
+
+	// snapshots are copies
+	c.Add(1)
+	if got := s.Counters["requests"]; got != 1 {
+		t.Errorf("want snapshot to be unaffected, got %v", got)
+	}
+}
+
+func TestSnapshotEmptyHistogram(t *testing.T) {
+	p := New()
+	p.NewExplicitHistogram("duration", func() []float64 { return []float64{1, 2, 3} })
+
+	if got := p.Snapshot().Histograms["duration"]; got != (HistogramSnapshot{}) {
+		t.Fatalf("want empty snapshot, got %+v", got)
+	}
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+	p := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				p.NewCounter("requests").With("worker", "a").Add(1)
+				p.NewHistogram("duration", 10).Observe(float64(j))
+				p.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := p.Snapshot().Counters["requests.worker:a"]; got != 4000 {
+		t.Fatalf("want 4000, got %v", got)
+	}
+}
+
+func TestMultiprovider(t *testing.T) {
+	tp := testmetrics.NewProvider(t)
+	p := New()
+
+	multiprovider.New(tp, p).NewCounter("requests").Add(2)
+
+	tp.CheckCounter("requests", 2)
+	if got := p.Snapshot().Counters["requests"]; got != 2 {
+		t.Fatalf("want 2, got %v", got)
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := map[string]struct {
+		name string
+		lvs  []string
+		want string
+	}{
+		"no labels": {name: "a", want: "a"},
+		"labels":    {name: "a", lvs: []string{"b", "c", "d", "e"}, want: "a.b:c.d:e"},
+		"odd":       {name: "a", lvs: []string{"b"}, want: "a.b:unknown"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Key(test.name, test.lvs...); got != test.want {
+				t.Fatalf("want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -7,4 +7,9 @@
 // Package otel is a wrapper around Open-Telemetry's API for submitting metrics.
 //
 // This satisfies the go-kit metrics Provider type.
+//
+// Every distinct set of label values of a metric is a separate series. To
+// bound memory use and downstream cost, labels can be dropped with
+// WithAttributeAllowList and WithAttributeDenyList, and the number of series
+// per metric capped with WithCardinalityLimit.
 package otel
@@ -0,0 +1,111 @@
+package otel
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OverflowKey is the attribute set on a metric's overflow series, which
+// aggregates the values of the series dropped once its cardinality limit is
+// reached.
+const OverflowKey = attribute.Key("otel.metric.overflow")
+
+// DroppedSeriesMetricName is the name of the counter incremented, with a
+// `metric` attribute, every time a new series is redirected to its metric's
+// overflow series. It is prefixed like any other metric of the Provider.
+const DroppedSeriesMetricName = "otel.dropped_series"
+
+// ErrCardinalityLimit is returned by WithCardinalityLimit for negative limits.
+var ErrCardinalityLimit = errors.New("cardinality limit cannot be negative")
+
+// overflowLabels are the label values of overflow series.
+var overflowLabels = []string{string(OverflowKey), "true"}
+
+// WithCardinalityLimit limits the number of series, i.e. distinct sets of
+// label values, of every counter, gauge, histogram and cardinality counter.
+// Once a metric has limit series, new series of the metric are recorded to
+// its overflow series instead. A limit of 0, the default, disables limiting.
+func WithCardinalityLimit(limit int) Option {
+	return func(c *config) error {
+		if limit < 0 {
+			return ErrCardinalityLimit
+		}
+		c.cardinalityLimit = limit
+
+		return nil
+	}
+}
+
+// WithAttributeAllowList drops all labels but those with the given keys.
+func WithAttributeAllowList(keys ...string) Option {
+	return func(c *config) error {
+		c.allowedAttributes = makeKeySet(keys)
+
+		return nil
+	}
+}
+
+// WithAttributeDenyList drops the labels with the given keys.
+func WithAttributeDenyList(keys ...string) Option {
+	return func(c *config) error {
+		c.deniedAttributes = makeKeySet(keys)
+
+		return nil
+	}
+}
+
+func makeKeySet(keys []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
+
+// filterLabels returns labelValues without the labels dropped by the
+// attribute allow and deny lists. A missing last label value is set to
+// "unknown".
+func (c *config) filterLabels(labelValues []string) []string {
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues[:len(labelValues):len(labelValues)], "unknown")
+	}
+	if c.allowedAttributes == nil && c.deniedAttributes == nil {
+		return labelValues
+	}
+
+	filtered := make([]string, 0, len(labelValues))
+	for i := 0; i < len(labelValues); i += 2 {
+		if c.allowedAttributes != nil {
+			if _, ok := c.allowedAttributes[labelValues[i]]; !ok {
+				continue
+			}
+		}
+		if _, ok := c.deniedAttributes[labelValues[i]]; ok {
+			continue
+		}
+		filtered = append(filtered, labelValues[i], labelValues[i+1])
+	}
+	return filtered
+}
+
+// admit reports whether a new series of the metric of the given kind and
+// name can be created. Otherwise the series is dropped and counted as such.
+// The series without labels, created by the Provider's New* methods, is
+// always admitted and does not count towards the limit.
+// It must be called with p.mu held for writing.
+func (p *Provider) admit(kind, name string, labelValues []string) bool {
+	if p.cfg.cardinalityLimit == 0 || len(labelValues) == 0 {
+		return true
+	}
+
+	k := kind + ":" + name
+	if p.seriesCounts[k] < p.cfg.cardinalityLimit {
+		p.seriesCounts[k]++
+		return true
+	}
+
+	p.droppedSeries.Add(p.cfg.ctx, 1, metric.WithAttributes(attribute.String("metric", name)))
+	return false
+}
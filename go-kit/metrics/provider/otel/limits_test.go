@@ -0,0 +1,217 @@
+package otel
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// memExporter keeps the last exported metrics.
+type memExporter struct {
+	metric.Exporter
+
+	mu sync.Mutex
+	rm *metricdata.ResourceMetrics
+}
+
+func (e *memExporter) Temporality(ik metric.InstrumentKind) metricdata.Temporality {
+	return metric.DefaultTemporalitySelector(ik)
+}
+
+func (e *memExporter) Aggregation(ik metric.InstrumentKind) metric.Aggregation {
+	return metric.DefaultAggregationSelector(ik)
+}
+
+func (e *memExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rm = rm
+	return nil
+}
+
+func (e *memExporter) ForceFlush(context.Context) error { return nil }
+func (e *memExporter) Shutdown(context.Context) error   { return nil }
+
+// sums returns the value of the named counter for every attribute set.
+func (e *memExporter) sums(name string) map[attribute.Distinct]int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	sums := make(map[attribute.Distinct]int64)
+	for _, sm := range e.rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			switch data := m.Data.(type) {
+			case metricdata.Sum[float64]:
+				for _, dp := range data.DataPoints {
+					sums[dp.Attributes.Equivalent()] += int64(dp.Value)
+				}
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					sums[dp.Attributes.Equivalent()] += dp.Value
+				}
+			}
+		}
+	}
+	return sums
+}
+
+func distinct(kvs ...attribute.KeyValue) attribute.Distinct {
+	s := attribute.NewSet(kvs...)
+	return s.Equivalent()
+}
+
+func newTestProvider(t *testing.T, opts ...Option) (*Provider, *memExporter) {
+	t.Helper()
+
+	e := &memExporter{}
+	opts = append(opts, WithExporterFunc(func(*config) (metric.Exporter, error) { return e, nil }))
+
+	p, err := New(context.Background(), "test-service", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Stop)
+
+	return p.(*Provider), e
+}
+
+func TestCardinalityLimit(t *testing.T) {
+	p, e := newTestProvider(t, WithCardinalityLimit(2))
+
+	c := p.NewCounter("requests")
+	for _, user := range []string{"a", "b", "c", "d", "a"} {
+		c.With("user", user).Add(1)
+	}
+	p.NewHistogram("duration", 0).With("user", "a").With("path", "/").Observe(1)
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[attribute.Distinct]int64{
+		distinct(attribute.String("user", "a")): 2,
+		distinct(attribute.String("user", "b")): 1,
+		distinct(OverflowKey.String("true")):    2,
+	}
+	got := e.sums("requests")
+	if len(got) != len(want) {
+		t.Fatalf("want %d series, got %d: %v", len(want), len(got), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("want %v for %v, got %v", v, k, got[k])
+		}
+	}
+
+	dropped := e.sums(DroppedSeriesMetricName)
+	if got := dropped[distinct(attribute.String("metric", "requests"))]; got != 2 {
+		t.Errorf("want 2 dropped requests series, got %d", got)
+	}
+	if got := dropped[distinct(attribute.String("metric", "duration"))]; got != 0 {
+		t.Errorf("want no dropped duration series, got %d", got)
+	}
+}
+
+func TestCardinalityLimitCardinalityCounter(t *testing.T) {
+	p, _ := newTestProvider(t, WithCardinalityLimit(1))
+
+	a := p.NewCardinalityCounter("users").With("space", "a")
+	b := p.NewCardinalityCounter("users").With("space", "b")
+	c := p.NewCardinalityCounter("users").With("space", "c")
+
+	if a == b {
+		t.Fatal("want distinct series for the first two label sets")
+	}
+	if b != c {
+		t.Fatal("want series past the limit to share the overflow series")
+	}
+	if got := b.(*CardinalityCounter).labels; len(got) != 2 || got[0] != string(OverflowKey) {
+		t.Fatalf("want overflow labels, got %v", got)
+	}
+}
+
+func TestFilterLabels(t *testing.T) {
+	tests := map[string]struct {
+		opts []Option
+		lvs  []string
+		want []string
+	}{
+		"none":       {lvs: []string{"a", "1", "b", "2"}, want: []string{"a", "1", "b", "2"}},
+		"odd":        {lvs: []string{"a"}, want: []string{"a", "unknown"}},
+		"allow":      {opts: []Option{WithAttributeAllowList("b")}, lvs: []string{"a", "1", "b", "2"}, want: []string{"b", "2"}},
+		"deny":       {opts: []Option{WithAttributeDenyList("b")}, lvs: []string{"a", "1", "b", "2"}, want: []string{"a", "1"}},
+		"allow deny": {opts: []Option{WithAttributeAllowList("a", "b"), WithAttributeDenyList("b")}, lvs: []string{"a", "1", "b", "2", "c", "3"}, want: []string{"a", "1"}},
+		"odd denied": {opts: []Option{WithAttributeDenyList("b")}, lvs: []string{"a", "1", "b"}, want: []string{"a", "1"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config{}
+			for _, opt := range test.opts {
+				if err := opt(cfg); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := cfg.filterLabels(test.lvs)
+			if keyName("m", got...) != keyName("m", test.want...) {
+				t.Fatalf("want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestAttributeDenyListSharesSeries(t *testing.T) {
+	p, e := newTestProvider(t, WithAttributeDenyList("user"))
+
+	c := p.NewCounter("requests")
+	c.With("user", "a", "code", "200").Add(1)
+	c.With("user", "b", "code", "200").Add(1)
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := e.sums("requests")
+	if v := got[distinct(attribute.String("code", "200"))]; len(got) != 1 || v != 2 {
+		t.Fatalf("want a single series of 2, got %v", got)
+	}
+}
+
+func TestWithCardinalityLimitNegative(t *testing.T) {
+	if _, err := New(context.Background(), "test-service", WithCardinalityLimit(-1)); err == nil {
+		t.Fatal("want error")
+	}
+}
+
+func TestInstrumentErrorsHandled(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	prev := otelapi.GetErrorHandler()
+	otelapi.SetErrorHandler(otelapi.ErrorHandlerFunc(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}))
+	t.Cleanup(func() { otelapi.SetErrorHandler(prev) })
+
+	p, _ := newTestProvider(t)
+	p.NewCardinalityCounter("invalid name!").Insert([]byte("a"))
+	p.NewCounter("invalid counter!").Add(1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 2 {
+		t.Fatalf("want 2 errors handled, got %v", errs)
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	otelmetric "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 
@@ -31,6 +33,13 @@ type config struct {
 	aggregationSelector  metric.AggregationSelector
 	exporterFactory      exporterFactory
 	enableRuntimeMetrics bool
+	cardinalityLimit     int
+	allowedAttributes    map[string]struct{}
+	deniedAttributes     map[string]struct{}
+
+	// used by NewTracerProvider only
+	traceExporterFactory traceExporterFactory
+	sampler              sdktrace.Sampler
 }
 
 // Provider initializes a global otlp meter provider that can collect metrics and
@@ -42,10 +51,13 @@ type Provider struct {
 	meterProvider *metric.MeterProvider
 	viewCache     *viewCache
 
-	mu         sync.RWMutex
-	counters   map[string]*Counter
-	gauges     map[string]*Gauge
-	histograms map[string]*Histogram
+	mu            sync.RWMutex
+	counters      map[string]*Counter
+	gauges        map[string]*Gauge
+	histograms    map[string]*Histogram
+	cardCounters  map[string]*CardinalityCounter
+	seriesCounts  map[string]int
+	droppedSeries otelmetric.Int64Counter
 }
 
 type viewCache struct {
@@ -80,6 +92,54 @@ type exporterFactory func(*config) (metric.Exporter, error)
 // New returns a new, unstarted Provider. Use its Start() method to start
 // and establish a connection with its exporter's collector agent.
 func New(ctx context.Context, serviceName string, opts ...Option) (xmetrics.Provider, error) {
+	cfg, err := newConfig(ctx, serviceName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Provider{
+		cfg:          cfg,
+		counters:     make(map[string]*Counter),
+		gauges:       make(map[string]*Gauge),
+		histograms:   make(map[string]*Histogram),
+		cardCounters: make(map[string]*CardinalityCounter),
+		seriesCounts: make(map[string]int),
+		viewCache: &viewCache{
+			streams: make(map[string]metric.Stream),
+		},
+	}
+
+	exporter, err := cfg.exporterFactory(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := metric.NewPeriodicReader(
+		exporter,
+		metric.WithInterval(cfg.collectPeriod),
+	)
+
+	meterProvider := metric.NewMeterProvider(
+		metric.WithResource(cfg.serviceResource),
+		metric.WithReader(reader),
+		metric.WithView(p.viewCache.View),
+	)
+
+	// initialize the metricProvider
+	p.meterProvider = meterProvider
+
+	dropped := prefixName(cfg.prefix, DroppedSeriesMetricName)
+	p.droppedSeries, err = meterProvider.Meter(dropped).Int64Counter(dropped)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// newConfig applies opts on top of the defaults shared by the metrics and
+// tracer providers.
+func newConfig(ctx context.Context, serviceName string, opts []Option) (*config, error) {
 	// Start with environment detection but strip the schema to avoid conflicts
 	base := resource.Default()
 	schemalessBase := resource.NewSchemaless(base.Attributes()...)
@@ -93,6 +153,7 @@ func New(ctx context.Context, serviceName string, opts ...Option) (xmetrics.Prov
 		WithServiceStandard(serviceName),
 		DefaultAggregationSelector(),
 		DefaultEndpointExporter(),
+		DefaultTraceExporter(),
 	}
 
 	opts = append(defaultOpts, opts...)
@@ -110,36 +171,7 @@ func New(ctx context.Context, serviceName string, opts ...Option) (xmetrics.Prov
 		)
 	}
 
-	p := Provider{
-		cfg:        cfg,
-		counters:   make(map[string]*Counter),
-		gauges:     make(map[string]*Gauge),
-		histograms: make(map[string]*Histogram),
-		viewCache: &viewCache{
-			streams: make(map[string]metric.Stream),
-		},
-	}
-
-	exporter, err := cfg.exporterFactory(cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	reader := metric.NewPeriodicReader(
-		exporter,
-		metric.WithInterval(cfg.collectPeriod),
-	)
-
-	meterProvider := metric.NewMeterProvider(
-		metric.WithResource(cfg.serviceResource),
-		metric.WithReader(reader),
-		metric.WithView(p.viewCache.View),
-	)
-
-	// initialize the metricProvider
-	p.meterProvider = meterProvider
-
-	return &p, nil
+	return cfg, nil
 }
 
 // Start starts the provider's controller and exporter.
@@ -0,0 +1,105 @@
+package otel
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/heroku/x/tlsconfig"
+)
+
+// DefaultTraceExporter is the span exporter used by NewTracerProvider unless
+// another one is configured.
+var DefaultTraceExporter = WithHTTPTraceExporter
+
+type traceExporterFactory func(*config) (sdktrace.SpanExporter, error)
+
+// NewTracerProvider returns a TracerProvider exporting spans over OTLP, with
+// the same resource attributes as a metrics Provider created with the same
+// options. Spans are exported in batches; callers must Shutdown the returned
+// TracerProvider to flush pending spans.
+func NewTracerProvider(ctx context.Context, serviceName string, opts ...Option) (*sdktrace.TracerProvider, error) {
+	cfg, err := newConfig(ctx, serviceName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := cfg.traceExporterFactory(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := cfg.sampler
+	if sampler == nil {
+		sampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(cfg.serviceResource),
+		sdktrace.WithSampler(sampler),
+	), nil
+}
+
+// WithHTTPTraceExporter exports spans over OTLP/HTTP to DefaultAgentEndpoint,
+// configured with options.
+func WithHTTPTraceExporter(options ...otlptracehttp.Option) Option {
+	return WithHTTPEndpointTraceExporter(DefaultAgentEndpoint, options...)
+}
+
+// WithHTTPEndpointTraceExporter exports spans over OTLP/HTTP to endpoint,
+// configured with options. TLS is used for https endpoints, and the user info
+// of the endpoint, if any, is sent as basic auth.
+func WithHTTPEndpointTraceExporter(endpoint string, options ...otlptracehttp.Option) Option {
+	return WithTraceExporterFunc(func(cfg *config) (sdktrace.SpanExporter, error) {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		defaults := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(u.Host),
+		}
+
+		if u.Scheme == "https" {
+			defaults = append(defaults, otlptracehttp.WithTLSClientConfig(tlsconfig.New()))
+		} else {
+			defaults = append(defaults, otlptracehttp.WithInsecure())
+		}
+
+		if u.User.String() != "" {
+			authHeader := make(map[string]string)
+			authHeader["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.String()))
+
+			defaults = append(defaults, otlptracehttp.WithHeaders(authHeader))
+		}
+
+		// finally append any passed in options
+		options = append(defaults, options...)
+
+		return otlptracehttp.New(cfg.ctx, options...)
+	})
+}
+
+// WithTraceExporterFunc sets the function creating the span exporter of
+// NewTracerProvider.
+func WithTraceExporterFunc(fn traceExporterFactory) Option {
+	return func(c *config) error {
+		c.traceExporterFactory = fn
+
+		return nil
+	}
+}
+
+// WithSampler sets the sampler used by NewTracerProvider. It defaults to
+// sampling every trace not already sampled out by its parent.
+func WithSampler(sampler sdktrace.Sampler) Option {
+	return func(c *config) error {
+		c.sampler = sampler
+
+		return nil
+	}
+}
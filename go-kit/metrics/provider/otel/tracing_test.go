@@ -0,0 +1,44 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	ctx := context.Background()
+	exporter := tracetest.NewInMemoryExporter()
+
+	tp, err := NewTracerProvider(ctx, "test-service",
+		WithTraceExporterFunc(func(*config) (sdktrace.SpanExporter, error) { return exporter, nil }),
+		WithAttributes(Stage("staging"), Cloud("heroku")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Shutdown(ctx) //nolint:errcheck
+
+	_, span := tp.Tracer("test").Start(ctx, "work")
+	span.End()
+
+	if err := tp.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got %d", len(spans))
+	}
+
+	attrs := spans[0].Resource.Set()
+	for k, want := range map[attribute.Key]string{ServiceKey: "test-service", StageKey: "staging", CloudKey: "heroku"} {
+		v, ok := attrs.Value(k)
+		if !ok || v.AsString() != want {
+			t.Errorf("want resource attribute %s=%q, got %q", k, want, v.AsString())
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/metrics/generic"
+	otelapi "go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	sdk "go.opentelemetry.io/otel/sdk/metric"
@@ -19,6 +20,10 @@ var (
 	_ metrics.Counter   = (*Counter)(nil)
 	_ metrics.Gauge     = (*Gauge)(nil)
 	_ metrics.Histogram = (*Histogram)(nil)
+
+	_ xmetrics.CardinalityCounter = (*CardinalityCounter)(nil)
+
+	_ xmetrics.ExponentialHistogramProvider = (*Provider)(nil)
 )
 
 // Counter is a counter.
@@ -47,6 +52,7 @@ func (p *Provider) NewCounter(name string) metrics.Counter {
 }
 
 func (p *Provider) newCounter(name string, labelValues ...string) metrics.Counter {
+	labelValues = p.cfg.filterLabels(labelValues)
 	k := keyName(name, labelValues...)
 
 	p.mu.RLock()
@@ -59,10 +65,18 @@ func (p *Provider) newCounter(name string, labelValues ...string) metrics.Counte
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if _, ok := p.counters[k]; !ok && !p.admit("counter", name, labelValues) {
+		labelValues, k = overflowLabels, keyName(name, overflowLabels...)
+	}
+
 	m := p.meterProvider.Meter(name)
 
 	if _, ok := p.counters[k]; !ok {
-		c, _ := m.Float64Counter(name)
+		c, err := m.Float64Counter(name)
+		if err != nil {
+			// the instruments returned with errors are still usable
+			otelapi.Handle(fmt.Errorf("creating counter %s: %w", name, err))
+		}
 
 		p.counters[k] = &Counter{
 			Float64Counter: c,
@@ -92,6 +106,7 @@ func (p *Provider) NewGauge(name string) metrics.Gauge {
 }
 
 func (p *Provider) newGauge(name string, labelValues ...string) metrics.Gauge {
+	labelValues = p.cfg.filterLabels(labelValues)
 	k := keyName(name, labelValues...)
 
 	p.mu.RLock()
@@ -104,6 +119,10 @@ func (p *Provider) newGauge(name string, labelValues ...string) metrics.Gauge {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if _, ok := p.gauges[k]; !ok && !p.admit("gauge", name, labelValues) {
+		labelValues, k = overflowLabels, keyName(name, overflowLabels...)
+	}
+
 	m := p.meterProvider.Meter(name)
 
 	attributes := makeAttributes(labelValues)
@@ -117,7 +136,10 @@ func (p *Provider) newGauge(name string, labelValues ...string) metrics.Gauge {
 			return nil
 		}
 
-		g, _ := m.Float64ObservableGauge(name, metric.WithFloat64Callback(callback))
+		g, err := m.Float64ObservableGauge(name, metric.WithFloat64Callback(callback))
+		if err != nil {
+			otelapi.Handle(fmt.Errorf("creating gauge %s: %w", name, err))
+		}
 
 		p.gauges[k] = &Gauge{
 			Gauge:      gg,
@@ -186,8 +208,15 @@ func (p *Provider) NewHistogram(name string, buckets int) metrics.Histogram {
 	return p.newHistogram(stream)
 }
 
+// NewExponentialHistogram implements xmetrics.ExponentialHistogramProvider.
+// It is equivalent to NewHistogram.
+func (p *Provider) NewExponentialHistogram(name string, maxSize int) metrics.Histogram {
+	return p.NewHistogram(name, maxSize)
+}
+
 func (p *Provider) newHistogram(stream sdk.Stream, labelValues ...string) metrics.Histogram {
 	name := stream.Name
+	labelValues = p.cfg.filterLabels(labelValues)
 	k := keyName(name, labelValues...)
 
 	p.mu.RLock()
@@ -200,10 +229,17 @@ func (p *Provider) newHistogram(stream sdk.Stream, labelValues ...string) metric
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if _, ok := p.histograms[k]; !ok && !p.admit("histogram", name, labelValues) {
+		labelValues, k = overflowLabels, keyName(name, overflowLabels...)
+	}
+
 	m := p.meterProvider.Meter(name)
 
 	if _, ok := p.histograms[k]; !ok {
-		h, _ := m.Float64Histogram(name)
+		h, err := m.Float64Histogram(name)
+		if err != nil {
+			otelapi.Handle(fmt.Errorf("creating histogram %s: %w", name, err))
+		}
 		p.viewCache.Store(stream)
 
 		p.histograms[k] = &Histogram{
@@ -229,9 +265,72 @@ func (h *Histogram) Observe(value float64) {
 	h.Record(h.p.cfg.ctx, value, metric.WithAttributeSet(h.attributes))
 }
 
+// CardinalityCounter is a cardinality counter, reported as a gauge of the
+// estimated number of distinct values inserted since the last collection.
+type CardinalityCounter struct {
+	*xmetrics.HLLCounter
+	observer metric.Int64Observable
+	name     string
+	labels   []string
+	p        *Provider
+}
+
 // NewCardinalityCounter implements metrics.Provider.
 func (p *Provider) NewCardinalityCounter(name string) xmetrics.CardinalityCounter {
-	return xmetrics.NewHLLCounter(name)
+	return p.newCardinalityCounter(prefixName(p.cfg.prefix, name))
+}
+
+func (p *Provider) newCardinalityCounter(name string, labelValues ...string) xmetrics.CardinalityCounter {
+	labelValues = p.cfg.filterLabels(labelValues)
+	k := keyName(name, labelValues...)
+
+	p.mu.RLock()
+	c, ok := p.cardCounters[k]
+	p.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.cardCounters[k]; !ok && !p.admit("cardinality_counter", name, labelValues) {
+		labelValues, k = overflowLabels, keyName(name, overflowLabels...)
+	}
+
+	m := p.meterProvider.Meter(name)
+
+	if _, ok := p.cardCounters[k]; !ok {
+		hll := xmetrics.NewHLLCounter(name)
+		attributes := makeAttributes(labelValues)
+
+		callback := func(_ context.Context, result metric.Int64Observer) error {
+			result.Observe(int64(hll.EstimateReset()), metric.WithAttributeSet(attributes)) //nolint:gosec // estimates fit in an int64
+
+			return nil
+		}
+
+		o, err := m.Int64ObservableGauge(name, metric.WithInt64Callback(callback))
+		if err != nil {
+			otelapi.Handle(fmt.Errorf("creating cardinality counter %s: %w", name, err))
+		}
+
+		p.cardCounters[k] = &CardinalityCounter{
+			HLLCounter: hll,
+			observer:   o,
+			name:       name,
+			labels:     labelValues,
+			p:          p,
+		}
+	}
+
+	return p.cardCounters[k]
+}
+
+// With implements metrics.CardinalityCounter.
+func (c *CardinalityCounter) With(labelValues ...string) xmetrics.CardinalityCounter {
+	lvs := append(append([]string(nil), c.labels...), labelValues...)
+	return c.p.newCardinalityCounter(c.name, lvs...)
 }
 
 func prefixName(prefix, name string) string {
@@ -258,7 +357,7 @@ func keyName(name string, labelValues ...string) string {
 
 // makeAttributes is used to convert labels into attribute.KeyValues.
 func makeAttributes(labels []string) attribute.Set {
-	attributes := make([]attribute.KeyValue, len(labels))
+	attributes := make([]attribute.KeyValue, 0, (len(labels)+1)/2)
 	if len(labels)%2 != 0 {
 		labels = append(labels, "unknown")
 	}
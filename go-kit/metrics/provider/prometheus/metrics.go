@@ -0,0 +1,338 @@
+package prometheus
+
+import (
+	"bufio"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+)
+
+var (
+	_ metrics.Counter             = (*Counter)(nil)
+	_ metrics.Gauge               = (*Gauge)(nil)
+	_ metrics.Histogram           = (*Histogram)(nil)
+	_ xmetrics.CardinalityCounter = (*CardinalityCounter)(nil)
+)
+
+// Counter is a counter.
+type Counter struct {
+	f   *family
+	lvs []string
+}
+
+// With implements metrics.Counter.
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	return &Counter{f: c.f, lvs: appendLabelValues(c.lvs, labelValues)}
+}
+
+// Add implements metrics.Counter. Negative deltas are ignored, as Prometheus
+// counters only go up.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.f.update(c.lvs, func(s *series) { s.value += delta })
+}
+
+// Gauge is a gauge.
+type Gauge struct {
+	f   *family
+	lvs []string
+}
+
+// With implements metrics.Gauge.
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	return &Gauge{f: g.f, lvs: appendLabelValues(g.lvs, labelValues)}
+}
+
+// Set implements metrics.Gauge.
+func (g *Gauge) Set(value float64) {
+	g.f.update(g.lvs, func(s *series) { s.value = value })
+}
+
+// Add implements metrics.Gauge.
+func (g *Gauge) Add(delta float64) {
+	g.f.update(g.lvs, func(s *series) { s.value += delta })
+}
+
+// Histogram is a histogram with explicit bucket boundaries.
+type Histogram struct {
+	f   *family
+	lvs []string
+}
+
+// With implements metrics.Histogram.
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	return &Histogram{f: h.f, lvs: appendLabelValues(h.lvs, labelValues)}
+}
+
+// Observe implements metrics.Histogram.
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.f.bounds, value)
+	h.f.update(h.lvs, func(s *series) {
+		s.buckets[i]++
+		s.count++
+		s.sum += value
+	})
+}
+
+// CardinalityCounter is a cardinality counter, exposed as a gauge of the
+// estimated number of unique values inserted.
+type CardinalityCounter struct {
+	f   *family
+	lvs []string
+}
+
+// With implements xmetrics.CardinalityCounter.
+func (c *CardinalityCounter) With(labelValues ...string) xmetrics.CardinalityCounter {
+	return &CardinalityCounter{f: c.f, lvs: appendLabelValues(c.lvs, labelValues)}
+}
+
+// Insert implements xmetrics.CardinalityCounter.
+func (c *CardinalityCounter) Insert(b []byte) {
+	c.f.update(c.lvs, func(s *series) { s.hll.Insert(b) })
+}
+
+type metricType int
+
+const (
+	typeCounter metricType = iota
+	typeGauge
+	typeHistogram
+	typeCardinality
+)
+
+func (t metricType) String() string {
+	switch t {
+	case typeCounter:
+		return "counter"
+	case typeHistogram:
+		return "histogram"
+	default:
+		return "gauge"
+	}
+}
+
+// family holds the series of a metric, one per distinct set of labels.
+type family struct {
+	name   string
+	source string // unsanitized name
+	typ    metricType
+	bounds []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labels []labelPair
+	value  float64
+
+	// histograms only; buckets aren't cumulative, the last one holds
+	// observations above the highest boundary
+	buckets []uint64
+	count   uint64
+	sum     float64
+
+	// cardinality counters only
+	hll *xmetrics.HLLCounter
+}
+
+type labelPair struct {
+	name, value string
+}
+
+func newFamily(name, source string, typ metricType, bounds []float64) *family {
+	return &family{
+		name:   name,
+		source: source,
+		typ:    typ,
+		bounds: bounds,
+		series: make(map[string]*series),
+	}
+}
+
+// update calls fn with the series for labelValues, creating it if needed.
+func (f *family) update(labelValues []string, fn func(*series)) {
+	labels := makeLabels(labelValues)
+	key := labelsKey(labels)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		switch f.typ {
+		case typeHistogram:
+			s.buckets = make([]uint64, len(f.bounds)+1)
+		case typeCardinality:
+			s.hll = xmetrics.NewHLLCounter(f.name)
+		}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.series) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w.WriteString("# TYPE " + f.name + " " + f.typ.String() + "\n")
+	for _, k := range keys {
+		s := f.series[k]
+		switch f.typ {
+		case typeHistogram:
+			var cumulative uint64
+			for i, b := range f.bounds {
+				cumulative += s.buckets[i]
+				writeSample(w, f.name+"_bucket", s.labels, labelPair{"le", formatFloat(b)}, float64(cumulative))
+			}
+			writeSample(w, f.name+"_bucket", s.labels, labelPair{"le", "+Inf"}, float64(s.count))
+			writeSample(w, f.name+"_sum", s.labels, labelPair{}, s.sum)
+			writeSample(w, f.name+"_count", s.labels, labelPair{}, float64(s.count))
+		case typeCardinality:
+			writeSample(w, f.name, s.labels, labelPair{}, float64(s.hll.Estimate()))
+		default:
+			writeSample(w, f.name, s.labels, labelPair{}, s.value)
+		}
+	}
+}
+
+// writeSample writes a sample line. extra is appended to labels unless its
+// name is empty.
+func writeSample(w *bufio.Writer, name string, labels []labelPair, extra labelPair, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extra.name != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, l)
+		}
+		if extra.name != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extra)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabel(w *bufio.Writer, l labelPair) {
+	w.WriteString(l.name)
+	w.WriteString(`="`)
+	labelValueReplacer.WriteString(w, l.value) //nolint:errcheck
+	w.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func appendLabelValues(lvs, more []string) []string {
+	return append(append(make([]string, 0, len(lvs)+len(more)), lvs...), more...)
+}
+
+// makeLabels pairs up labelValues, sorted by name. A missing value is set to
+// "unknown" and, should a name be repeated, the last value wins.
+func makeLabels(labelValues []string) []labelPair {
+	if len(labelValues) == 0 {
+		return nil
+	}
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues, "unknown")
+	}
+
+	labels := make([]labelPair, 0, len(labelValues)/2)
+	for i := 0; i < len(labelValues); i += 2 {
+		labels = append(labels, labelPair{name: sanitizeLabelName(labelValues[i]), value: labelValues[i+1]})
+	}
+
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].name < labels[j].name
+	})
+
+	deduped := labels[:0]
+	for i, l := range labels {
+		if i+1 < len(labels) && labels[i+1].name == l.name {
+			continue
+		}
+		deduped = append(deduped, l)
+	}
+	return deduped
+}
+
+func labelsKey(labels []labelPair) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.name)
+		b.WriteByte(0xff)
+		b.WriteString(l.value)
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
+
+// sanitizeName replaces characters not allowed in metric names with
+// underscores.
+func sanitizeName(name string) string {
+	return sanitize(name, true)
+}
+
+// sanitizeLabelName replaces characters not allowed in label names with
+// underscores.
+func sanitizeLabelName(name string) string {
+	return sanitize(name, false)
+}
+
+func sanitize(name string, allowColon bool) string {
+	if name == "" {
+		return "_"
+	}
+
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' ||
+			(c >= 'a' && c <= 'z') ||
+			(c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9' && i > 0) ||
+			(c == ':' && allowColon)
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
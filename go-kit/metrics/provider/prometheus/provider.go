@@ -0,0 +1,215 @@
+// Package prometheus provides a metrics Provider exposing metrics in the
+// Prometheus text format, for services scraped by a Prometheus server.
+//
+// Mount the provider's Handler to expose the metrics:
+//
+//	p := prometheus.New()
+//	mux.Handle("/metrics", p.Handler())
+//
+// Metric and label names are sanitized to the characters Prometheus allows,
+// e.g. "http.request-duration.ms" is exposed as "http_request_duration_ms".
+// Labels are added with With, as name/value pairs.
+package prometheus
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/sirupsen/logrus"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/go-kit/metrics/provider/discard"
+)
+
+// ContentType of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var _ xmetrics.Provider = (*Provider)(nil)
+
+type config struct {
+	prefix       string
+	distribution xmetrics.DistributionFunc
+	logger       logrus.FieldLogger
+}
+
+// Option configures a Provider.
+type Option func(*config)
+
+// WithPrefix prefixes the names of all metrics with prefix.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithDefaultDistribution sets the buckets of histograms created with
+// NewHistogram. Defaults to xmetrics.TenSecondDistribution, matching
+// durations recorded in milliseconds.
+func WithDefaultDistribution(fn xmetrics.DistributionFunc) Option {
+	return func(c *config) {
+		c.distribution = fn
+	}
+}
+
+// WithLogger sets the logger of the metrics the provider rejects. Defaults to
+// the standard logrus logger.
+func WithLogger(l logrus.FieldLogger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// Provider is a metrics Provider keeping metrics in memory for them to be
+// scraped. Metrics are cumulative and never reset.
+//
+// Metrics conflicting with a previously created metric are logged and
+// discarded, as the resulting exposition would be rejected by Prometheus:
+// metrics of a different type under the same name, histograms with different
+// buckets, and metrics whose names only match once sanitized, e.g. "a.b" and
+// "a_b".
+type Provider struct {
+	cfg config
+
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// New returns a Provider.
+func New(opts ...Option) *Provider {
+	cfg := config{distribution: xmetrics.TenSecondDistribution, logger: logrus.StandardLogger()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Provider{
+		cfg:      cfg,
+		families: make(map[string]*family),
+	}
+}
+
+// NewCounter implements Provider.
+func (p *Provider) NewCounter(name string) metrics.Counter {
+	f := p.family(name, typeCounter, nil)
+	if f == nil {
+		return discard.New().NewCounter(name)
+	}
+	return &Counter{f: f}
+}
+
+// NewGauge implements Provider.
+func (p *Provider) NewGauge(name string) metrics.Gauge {
+	f := p.family(name, typeGauge, nil)
+	if f == nil {
+		return discard.New().NewGauge(name)
+	}
+	return &Gauge{f: f}
+}
+
+// NewHistogram implements Provider. The number of buckets is ignored in favor
+// of the provider's default distribution.
+func (p *Provider) NewHistogram(name string, _ int) metrics.Histogram {
+	return p.NewExplicitHistogram(name, p.cfg.distribution)
+}
+
+// NewExplicitHistogram implements Provider. The boundaries returned by fn are
+// used as the upper bounds of the histogram's buckets.
+func (p *Provider) NewExplicitHistogram(name string, fn xmetrics.DistributionFunc) metrics.Histogram {
+	bounds := append([]float64(nil), fn()...)
+	sort.Float64s(bounds)
+	f := p.family(name, typeHistogram, bounds)
+	if f == nil {
+		return discard.New().NewExplicitHistogram(name, fn)
+	}
+	return &Histogram{f: f}
+}
+
+// NewCardinalityCounter implements Provider. Cardinality estimates are exposed
+// as gauges.
+func (p *Provider) NewCardinalityCounter(name string) xmetrics.CardinalityCounter {
+	f := p.family(name, typeCardinality, nil)
+	if f == nil {
+		return discard.New().NewCardinalityCounter(name)
+	}
+	return &CardinalityCounter{f: f}
+}
+
+// Stop implements Provider.
+func (p *Provider) Stop() {}
+
+// Flush implements Provider. Metrics are exposed when scraped, so there is
+// nothing to flush.
+func (p *Provider) Flush() error {
+	return nil
+}
+
+// Handler returns an http.Handler exposing the provider's metrics.
+func (p *Provider) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = p.WriteText(w)
+	})
+}
+
+// WriteText writes the provider's metrics to w in the text exposition format,
+// sorted by name.
+func (p *Provider) WriteText(w io.Writer) error {
+	p.mu.Lock()
+	families := make([]*family, 0, len(p.families))
+	for _, f := range p.families {
+		families = append(families, f)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// family returns the family of the named metric, creating it if needed, or
+// nil if the metric conflicts with an existing family.
+func (p *Provider) family(name string, typ metricType, bounds []float64) *family {
+	if p.cfg.prefix != "" {
+		name = p.cfg.prefix + "." + name
+	}
+	sanitized := sanitizeName(name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, ok := p.families[sanitized]
+	if !ok {
+		f = newFamily(sanitized, name, typ, bounds)
+		p.families[sanitized] = f
+		return f
+	}
+
+	var conflict string
+	switch {
+	case f.source != name:
+		conflict = "name already registered for " + f.source
+	case f.typ != typ:
+		conflict = "already registered as a " + f.typ.String()
+	case !slices.Equal(f.bounds, bounds):
+		conflict = "already registered with different buckets"
+	}
+	if conflict != "" {
+		p.cfg.logger.WithFields(logrus.Fields{
+			"at":     "discarding-metric",
+			"metric": name,
+			"type":   typ.String(),
+		}).Warn(conflict)
+		return nil
+	}
+	return f
+}
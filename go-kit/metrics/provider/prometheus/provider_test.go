@@ -0,0 +1,142 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestProviderExposition(t *testing.T) {
+	p := New(WithPrefix("svc"))
+
+	c := p.NewCounter("http.requests")
+	c.With("method", "GET", "code", "200").Add(2)
+	c.With("code", "200", "method", "GET").Add(1)
+	c.With("method", "POST", "code", "500").Add(1)
+
+	g := p.NewGauge("connections")
+	g.Set(10)
+	g.Add(-3)
+
+	h := p.NewExplicitHistogram("request-duration.ms", func() []float64 { return []float64{100, 10, 1000} })
+	for _, v := range []float64{5, 10, 50, 5000} {
+		h.Observe(v)
+	}
+
+	u := p.NewCardinalityCounter("users").With("space", `a "quoted\ space`)
+	for _, user := range []string{"a", "b", "a"} {
+		u.Insert([]byte(user))
+	}
+
+	// never recorded to, so not exposed
+	p.NewCounter("unused")
+
+	want := strings.Join([]string{
+		`# TYPE svc_connections gauge`,
+		`svc_connections 7`,
+		`# TYPE svc_http_requests counter`,
+		`svc_http_requests{code="200",method="GET"} 3`,
+		`svc_http_requests{code="500",method="POST"} 1`,
+		`# TYPE svc_request_duration_ms histogram`,
+		`svc_request_duration_ms_bucket{le="10"} 2`,
+		`svc_request_duration_ms_bucket{le="100"} 3`,
+		`svc_request_duration_ms_bucket{le="1000"} 3`,
+		`svc_request_duration_ms_bucket{le="+Inf"} 4`,
+		`svc_request_duration_ms_sum 5065`,
+		`svc_request_duration_ms_count 4`,
+		`# TYPE svc_users gauge`,
+		`svc_users{space="a \"quoted\\ space"} 2`,
+	}, "\n") + "\n"
+
+	var b strings.Builder
+	if err := p.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != want {
+		t.Fatalf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestProviderHandler(t *testing.T) {
+	p := New()
+	p.NewHistogram("latency", 10).With("route", "/").Observe(20)
+
+	rec := httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Fatalf("want content type %q, got %q", ContentType, got)
+	}
+	// default distribution is TenSecondDistribution
+	for _, want := range []string{
+		`latency_bucket{route="/",le="20"} 1`,
+		`latency_bucket{route="/",le="10000"} 1`,
+		`latency_count{route="/"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want+"\n") {
+			t.Errorf("want body to contain %q, got:\n%s", want, rec.Body)
+		}
+	}
+}
+
+func TestProviderConflicts(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	p := New(WithLogger(logger))
+
+	c := p.NewCounter("metric")
+	c.Add(2)
+	c.Add(-1)
+	p.NewExplicitHistogram("duration", func() []float64 { return []float64{1, 2} }).Observe(1)
+
+	// conflicting metrics are discarded
+	p.NewGauge("metric").Set(10)
+	p.NewCounter("metric_").Add(1)
+	p.NewCounter("metric.").Add(1)
+	p.NewExplicitHistogram("duration", func() []float64 { return []float64{1, 5} }).Observe(3)
+
+	if got := len(hook.AllEntries()); got != 3 {
+		t.Fatalf("want 3 conflicts logged, got %d", got)
+	}
+
+	var b strings.Builder
+	if err := p.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"metric 2\n", `duration_bucket{le="2"} 1` + "\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("want exposition to contain %q, got:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestMakeLabels(t *testing.T) {
+	tests := map[string]struct {
+		lvs  []string
+		want string
+	}{
+		"none":        {want: ""},
+		"sorted":      {lvs: []string{"b", "2", "a", "1"}, want: "a=1,b=2"},
+		"odd":         {lvs: []string{"a"}, want: "a=unknown"},
+		"repeated":    {lvs: []string{"a", "1", "a", "2"}, want: "a=2"},
+		"sanitized":   {lvs: []string{"dyno.type", "web"}, want: "dyno_type=web"},
+		"leading 0-9": {lvs: []string{"1a", "x"}, want: "_a=x"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var parts []string
+			for _, l := range makeLabels(test.lvs) {
+				parts = append(parts, l.name+"="+l.value)
+			}
+			if got := strings.Join(parts, ","); got != test.want {
+				t.Fatalf("want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
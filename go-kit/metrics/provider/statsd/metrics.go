@@ -0,0 +1,228 @@
+package statsd
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+)
+
+// maxSamples bounds the number of histogram observations sent per flush.
+// Above it, a uniform sample of the observations is sent along with its
+// sample rate.
+const maxSamples = 1000
+
+// maxSetMembers bounds the number of distinct set members kept between
+// flushes. Above it, new members are dropped until the next flush, so that
+// the set undercounts rather than growing without bound.
+const maxSetMembers = 10000
+
+var (
+	_ metrics.Counter             = (*Counter)(nil)
+	_ metrics.Gauge               = (*Gauge)(nil)
+	_ metrics.Histogram           = (*Histogram)(nil)
+	_ xmetrics.CardinalityCounter = (*CardinalityCounter)(nil)
+)
+
+// Counter is a counter, sent as the sum of its increments since the last
+// flush.
+type Counter struct {
+	p    *Provider
+	name string
+	lvs  []string
+	s    *series
+}
+
+// With implements metrics.Counter.
+func (c *Counter) With(labelValues ...string) metrics.Counter {
+	lvs := appendLabelValues(c.lvs, labelValues)
+	return &Counter{p: c.p, name: c.name, lvs: lvs, s: c.p.lookup(kindCounter, c.name, lvs)}
+}
+
+// Add implements metrics.Counter.
+func (c *Counter) Add(delta float64) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	c.s.value += delta
+	c.s.dirty = true
+}
+
+// Gauge is a gauge, sent with its last value on every flush once set.
+type Gauge struct {
+	p    *Provider
+	name string
+	lvs  []string
+	s    *series
+}
+
+// With implements metrics.Gauge.
+func (g *Gauge) With(labelValues ...string) metrics.Gauge {
+	lvs := appendLabelValues(g.lvs, labelValues)
+	return &Gauge{p: g.p, name: g.name, lvs: lvs, s: g.p.lookup(kindGauge, g.name, lvs)}
+}
+
+// Set implements metrics.Gauge.
+func (g *Gauge) Set(value float64) {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	g.s.value = value
+	g.s.dirty = true
+}
+
+// Add implements metrics.Gauge.
+func (g *Gauge) Add(delta float64) {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	g.s.value += delta
+	g.s.dirty = true
+}
+
+// Histogram is a histogram, sent as its observations since the last flush.
+type Histogram struct {
+	p    *Provider
+	name string
+	lvs  []string
+	s    *series
+}
+
+// With implements metrics.Histogram.
+func (h *Histogram) With(labelValues ...string) metrics.Histogram {
+	lvs := appendLabelValues(h.lvs, labelValues)
+	return &Histogram{p: h.p, name: h.name, lvs: lvs, s: h.p.lookup(kindHistogram, h.name, lvs)}
+}
+
+// Observe implements metrics.Histogram.
+func (h *Histogram) Observe(value float64) {
+	h.s.mu.Lock()
+	defer h.s.mu.Unlock()
+
+	h.s.count++
+	if len(h.s.samples) < maxSamples {
+		h.s.samples = append(h.s.samples, value)
+	} else if i := rand.Int64N(h.s.count); i < maxSamples {
+		h.s.samples[i] = value
+	}
+}
+
+// CardinalityCounter is a cardinality counter, sent as a StatsD set of the
+// distinct values inserted since the last flush, up to 10000 values.
+type CardinalityCounter struct {
+	p    *Provider
+	name string
+	lvs  []string
+	s    *series
+}
+
+// With implements xmetrics.CardinalityCounter.
+func (c *CardinalityCounter) With(labelValues ...string) xmetrics.CardinalityCounter {
+	lvs := appendLabelValues(c.lvs, labelValues)
+	return &CardinalityCounter{p: c.p, name: c.name, lvs: lvs, s: c.p.lookup(kindSet, c.name, lvs)}
+}
+
+// Insert implements xmetrics.CardinalityCounter.
+func (c *CardinalityCounter) Insert(b []byte) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	if len(c.s.members) < maxSetMembers {
+		c.s.members[string(b)] = struct{}{}
+	}
+}
+
+type kind int
+
+const (
+	kindCounter kind = iota
+	kindGauge
+	kindHistogram
+	kindSet
+)
+
+// String returns the StatsD metric type.
+func (k kind) String() string {
+	switch k {
+	case kindCounter:
+		return "c"
+	case kindGauge:
+		return "g"
+	case kindHistogram:
+		return "h"
+	default:
+		return "s"
+	}
+}
+
+// series aggregates a metric with a given set of tags.
+type series struct {
+	kind kind
+	name string
+	tags string
+
+	mu      sync.Mutex
+	value   float64
+	dirty   bool
+	count   int64
+	samples []float64
+	members map[string]struct{}
+}
+
+func newSeries(k kind, name, tags string) *series {
+	s := &series{kind: k, name: name, tags: tags}
+	if k == kindSet {
+		s.members = make(map[string]struct{})
+	}
+	return s
+}
+
+// drain appends the lines to send for s to lines and resets s.
+func (s *series) drain(lines []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.kind {
+	case kindCounter:
+		if s.dirty {
+			lines = append(lines, s.line(formatFloat(s.value), ""))
+			s.value, s.dirty = 0, false
+		}
+	case kindGauge:
+		if s.dirty {
+			// a signed value is a delta to StatsD, so negative values are
+			// set by resetting the gauge first
+			if s.value < 0 {
+				lines = append(lines, s.line("0", ""))
+			}
+			lines = append(lines, s.line(formatFloat(s.value), ""))
+		}
+	case kindHistogram:
+		rate := ""
+		if int64(len(s.samples)) < s.count {
+			rate = "|@" + formatFloat(float64(len(s.samples))/float64(s.count))
+		}
+		for _, v := range s.samples {
+			lines = append(lines, s.line(formatFloat(v), rate))
+		}
+		s.count, s.samples = 0, s.samples[:0]
+	case kindSet:
+		for m := range s.members {
+			lines = append(lines, s.line(sanitize(m), ""))
+		}
+		clear(s.members)
+	}
+
+	return lines
+}
+
+func (s *series) line(value, rate string) string {
+	return s.name + ":" + value + "|" + s.kind.String() + rate + s.tags
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func appendLabelValues(lvs, more []string) []string {
+	return append(append(make([]string, 0, len(lvs)+len(more)), lvs...), more...)
+}
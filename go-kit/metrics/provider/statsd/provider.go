@@ -0,0 +1,259 @@
+// Package statsd provides a metrics Provider pushing metrics to a StatsD
+// agent over UDP, using DogStatsD tags for labels.
+//
+// Metrics are aggregated in memory and flushed on an interval by Run:
+// counters are sent as the sum of their increments, gauges as their last
+// value, histograms as their observations (sampled above a limit) and
+// cardinality counters as the distinct values inserted, using StatsD sets.
+// Labels added with With are name/value pairs, sent as name:value tags.
+package statsd
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+)
+
+const (
+	// DefaultFlushInterval is the default interval metrics are sent at.
+	DefaultFlushInterval = 10 * time.Second
+
+	// DefaultMaxPacketSize is the default maximum size of UDP packets. It
+	// fits a packet in an Ethernet frame without fragmentation.
+	DefaultMaxPacketSize = 1432
+)
+
+var _ xmetrics.Provider = (*Provider)(nil)
+
+type config struct {
+	prefix        string
+	tags          []string
+	flushInterval time.Duration
+	maxPacketSize int
+}
+
+// Option configures a Provider.
+type Option func(*config)
+
+// WithPrefix prefixes the names of all metrics with prefix.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithTags adds tags, in the name:value form, to all metrics.
+func WithTags(tags ...string) Option {
+	return func(c *config) {
+		c.tags = append(c.tags, tags...)
+	}
+}
+
+// WithFlushInterval sets the interval Run sends metrics at. If d <= 0,
+// DefaultFlushInterval is used.
+func WithFlushInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.flushInterval = d
+	}
+}
+
+// WithMaxPacketSize sets the maximum size of the UDP packets sent. Metrics
+// larger than it are sent in a packet of their own. If n <= 0,
+// DefaultMaxPacketSize is used.
+func WithMaxPacketSize(n int) Option {
+	return func(c *config) {
+		c.maxPacketSize = n
+	}
+}
+
+// Provider aggregates metrics and sends them to a StatsD agent.
+type Provider struct {
+	cfg  config
+	conn net.Conn
+
+	mu     sync.Mutex
+	series map[string]*series
+
+	stopOnce sync.Once
+}
+
+// New returns a Provider sending metrics to the StatsD agent at addr, e.g.
+// "127.0.0.1:8125". Start sending metrics with Run.
+func New(addr string, opts ...Option) (*Provider, error) {
+	cfg := config{
+		flushInterval: DefaultFlushInterval,
+		maxPacketSize: DefaultMaxPacketSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.flushInterval <= 0 {
+		cfg.flushInterval = DefaultFlushInterval
+	}
+	if cfg.maxPacketSize <= 0 {
+		cfg.maxPacketSize = DefaultMaxPacketSize
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "dialing statsd agent")
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		conn:   conn,
+		series: make(map[string]*series),
+	}, nil
+}
+
+// NewCounter implements Provider.
+func (p *Provider) NewCounter(name string) metrics.Counter {
+	return &Counter{p: p, name: name, s: p.lookup(kindCounter, name, nil)}
+}
+
+// NewGauge implements Provider.
+func (p *Provider) NewGauge(name string) metrics.Gauge {
+	return &Gauge{p: p, name: name, s: p.lookup(kindGauge, name, nil)}
+}
+
+// NewHistogram implements Provider. Buckets are ignored, as the agent
+// aggregates observations.
+func (p *Provider) NewHistogram(name string, _ int) metrics.Histogram {
+	return &Histogram{p: p, name: name, s: p.lookup(kindHistogram, name, nil)}
+}
+
+// NewExplicitHistogram implements Provider. The distribution is ignored, as
+// the agent aggregates observations.
+func (p *Provider) NewExplicitHistogram(name string, _ xmetrics.DistributionFunc) metrics.Histogram {
+	return p.NewHistogram(name, 0)
+}
+
+// NewCardinalityCounter implements Provider.
+func (p *Provider) NewCardinalityCounter(name string) xmetrics.CardinalityCounter {
+	return &CardinalityCounter{p: p, name: name, s: p.lookup(kindSet, name, nil)}
+}
+
+// Run sends metrics every flush interval until ctx is canceled.
+func (p *Provider) Run(ctx context.Context) error {
+	tick := time.NewTicker(p.cfg.flushInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+			_ = p.Flush()
+		}
+	}
+}
+
+// Stop implements Provider. Pending metrics are sent before closing the
+// connection to the agent.
+func (p *Provider) Stop() {
+	p.stopOnce.Do(func() {
+		_ = p.Flush()
+		p.conn.Close()
+	})
+}
+
+// Flush implements Provider, sending the metrics aggregated since the last
+// flush.
+func (p *Provider) Flush() error {
+	p.mu.Lock()
+	all := make([]*series, 0, len(p.series))
+	for _, s := range p.series {
+		all = append(all, s)
+	}
+	p.mu.Unlock()
+
+	var (
+		packet   []byte
+		firstErr error
+	)
+	send := func() {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := p.conn.Write(packet); err != nil && firstErr == nil {
+			firstErr = errors.Wrap(err, "sending metrics")
+		}
+		packet = packet[:0]
+	}
+
+	var lines []string
+	for _, s := range all {
+		lines = s.drain(lines[:0])
+		for _, line := range lines {
+			if len(packet) > 0 && len(packet)+1+len(line) > p.cfg.maxPacketSize {
+				send()
+			}
+			if len(packet) > 0 {
+				packet = append(packet, '\n')
+			}
+			packet = append(packet, line...)
+		}
+	}
+	send()
+
+	return firstErr
+}
+
+// lookup returns the series for name and labelValues, creating it if needed.
+func (p *Provider) lookup(k kind, name string, labelValues []string) *series {
+	if p.cfg.prefix != "" {
+		name = p.cfg.prefix + "." + name
+	}
+	name = sanitize(name)
+	tags := p.tags(labelValues)
+	key := name + "|" + k.String() + tags
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s, ok := p.series[key]; ok {
+		return s
+	}
+	s := newSeries(k, name, tags)
+	p.series[key] = s
+	return s
+}
+
+// tags formats the default tags and labelValues as a DogStatsD tag suffix.
+func (p *Provider) tags(labelValues []string) string {
+	if len(p.cfg.tags) == 0 && len(labelValues) == 0 {
+		return ""
+	}
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues, "unknown")
+	}
+
+	tags := make([]string, 0, len(p.cfg.tags)+len(labelValues)/2)
+	for _, t := range p.cfg.tags {
+		tags = append(tags, sanitizeTag(t))
+	}
+	for i := 0; i < len(labelValues); i += 2 {
+		tags = append(tags, sanitizeTag(labelValues[i])+":"+sanitizeTag(labelValues[i+1]))
+	}
+	return "|#" + strings.Join(tags, ",")
+}
+
+var (
+	nameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", "\n", "_")
+	tagReplacer  = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+)
+
+func sanitize(s string) string {
+	return nameReplacer.Replace(s)
+}
+
+func sanitizeTag(s string) string {
+	return tagReplacer.Replace(s)
+}
@@ -0,0 +1,232 @@
+package statsd
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listen returns the address of a local UDP listener, and a function
+// returning the packets it received.
+func listen(t *testing.T) (string, func() []string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().String(), func() []string {
+		var packets []string
+		buf := make([]byte, 64*1024)
+		for {
+			if err := conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+				t.Fatal(err)
+			}
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return packets
+			}
+			packets = append(packets, string(buf[:n]))
+		}
+	}
+}
+
+func lines(packets []string) []string {
+	var all []string
+	for _, p := range packets {
+		all = append(all, strings.Split(p, "\n")...)
+	}
+	sort.Strings(all)
+	return all
+}
+
+func TestProviderFlush(t *testing.T) {
+	addr, received := listen(t)
+
+	p, err := New(addr, WithPrefix("svc"), WithTags("env:test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	c := p.NewCounter("requests")
+	c.Add(1)
+	c.Add(2)
+	c.With("code", "200").Add(1)
+
+	g := p.NewGauge("connections")
+	g.Set(10)
+	g.Add(-3)
+
+	p.NewHistogram("duration", 10).With("route").Observe(12.5)
+
+	u := p.NewCardinalityCounter("users")
+	for _, user := range []string{"a", "b", "a"} {
+		u.Insert([]byte(user))
+	}
+
+	// never recorded to, so not sent
+	p.NewCounter("unused")
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"svc.connections:7|g|#env:test",
+		"svc.duration:12.5|h|#env:test,route:unknown",
+		"svc.requests:1|c|#env:test,code:200",
+		"svc.requests:3|c|#env:test",
+		"svc.users:a|s|#env:test",
+		"svc.users:b|s|#env:test",
+	}
+	if got := lines(received()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want lines:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// counters, histograms and sets are reset, gauges are sent again
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"svc.connections:7|g|#env:test"}
+	if got := lines(received()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want lines %q, got %q", want, got)
+	}
+}
+
+func TestProviderMaxPacketSize(t *testing.T) {
+	addr, received := listen(t)
+
+	p, err := New(addr, WithMaxPacketSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	h := p.NewHistogram("latency", 0)
+	for i := 0; i < 20; i++ {
+		h.Observe(float64(i))
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := received()
+	if len(packets) < 2 {
+		t.Fatalf("want multiple packets, got %q", packets)
+	}
+	for _, packet := range packets {
+		if len(packet) > 64 {
+			t.Errorf("want packets of at most 64 bytes, got %d: %q", len(packet), packet)
+		}
+	}
+	if got := len(lines(packets)); got != 20 {
+		t.Fatalf("want 20 lines, got %d", got)
+	}
+}
+
+func TestProviderDefaults(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"zero":     {WithFlushInterval(0), WithMaxPacketSize(0)},
+		"negative": {WithFlushInterval(-time.Second), WithMaxPacketSize(-1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, err := New("127.0.0.1:8125", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Stop()
+
+			if p.cfg.flushInterval != DefaultFlushInterval {
+				t.Errorf("want flush interval %v, got %v", DefaultFlushInterval, p.cfg.flushInterval)
+			}
+			if p.cfg.maxPacketSize != DefaultMaxPacketSize {
+				t.Errorf("want max packet size %d, got %d", DefaultMaxPacketSize, p.cfg.maxPacketSize)
+			}
+		})
+	}
+}
+
+func TestHistogramSampleRate(t *testing.T) {
+	addr, received := listen(t)
+
+	p, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	h := p.NewHistogram("latency", 0)
+	for i := 0; i < 4*maxSamples; i++ {
+		h.Observe(1)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := lines(received())
+	if len(got) != maxSamples {
+		t.Fatalf("want %d lines, got %d", maxSamples, len(got))
+	}
+	if want := "latency:1|h|@0.25"; got[0] != want {
+		t.Fatalf("want %q, got %q", want, got[0])
+	}
+}
+
+func TestNegativeGauge(t *testing.T) {
+	addr, received := listen(t)
+
+	p, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	p.NewGauge("temperature").Set(-5)
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "temperature:0|g\ntemperature:-5|g"
+	if got := strings.Join(received(), "\n"); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestCardinalityCounterMaxMembers(t *testing.T) {
+	p, err := New("127.0.0.1:8125")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	c := p.NewCardinalityCounter("users").(*CardinalityCounter)
+	for i := 0; i < maxSetMembers+10; i++ {
+		c.Insert([]byte(strconv.Itoa(i)))
+	}
+
+	if got := len(c.s.members); got != maxSetMembers {
+		t.Fatalf("want %d members, got %d", maxSetMembers, got)
+	}
+}
+
+func TestStopFlushes(t *testing.T) {
+	addr, received := listen(t)
+
+	p, err := New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.NewCounter("requests").Add(1)
+	p.Stop()
+	p.Stop()
+
+	if got, want := lines(received()), "requests:1|c"; len(got) != 1 || got[0] != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
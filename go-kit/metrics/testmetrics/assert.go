@@ -0,0 +1,386 @@
+package testmetrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+)
+
+// UpdateGoldenEnv is the environment variable which, when set to a non-empty
+// value, makes CheckGolden write golden files instead of comparing them.
+const UpdateGoldenEnv = "TESTMETRICS_UPDATE_GOLDEN"
+
+// Kind is the kind of a metric.
+type Kind string
+
+// Kinds of metrics.
+const (
+	KindCounter            Kind = "counter"
+	KindGauge              Kind = "gauge"
+	KindHistogram          Kind = "histogram"
+	KindCardinalityCounter Kind = "cardinality_counter"
+)
+
+// Series is a metric with a given set of labels recorded by a Provider.
+type Series struct {
+	Kind   Kind
+	Name   string
+	Labels map[string]string
+
+	// Value is the value of counters and gauges, the estimate of
+	// cardinality counters, and the number of observations of histograms.
+	Value float64
+
+	// Observations of histograms.
+	Observations []float64
+}
+
+func (s Series) String() string {
+	return string(s.Kind) + " " + s.Name + formatLabels(s.Labels)
+}
+
+// Matcher matches series in CheckMetric. Label matchers select the series to
+// check, and value matchers check the aggregate of the selected series.
+type Matcher interface {
+	String() string
+}
+
+// LabelMatcher selects series by their labels.
+type LabelMatcher struct {
+	desc  string
+	match func(labels map[string]string) bool
+}
+
+func (m LabelMatcher) String() string { return m.desc }
+
+// Labels selects the series with at least the given label values.
+func Labels(labelValues ...string) LabelMatcher {
+	want := labelMap(labelValues)
+	return LabelMatcher{
+		desc: "labels" + formatLabels(want),
+		match: func(labels map[string]string) bool {
+			for k, v := range want {
+				if got, ok := labels[k]; !ok || got != v {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// LabelMatching selects the series with a label matching pattern.
+func LabelMatching(key, pattern string) LabelMatcher {
+	re := regexp.MustCompile(pattern)
+	return LabelMatcher{
+		desc: fmt.Sprintf("label %s matching %q", key, pattern),
+		match: func(labels map[string]string) bool {
+			v, ok := labels[key]
+			return ok && re.MatchString(v)
+		},
+	}
+}
+
+// WithoutLabel selects the series without the label.
+func WithoutLabel(key string) LabelMatcher {
+	return LabelMatcher{
+		desc: "without label " + key,
+		match: func(labels map[string]string) bool {
+			_, ok := labels[key]
+			return !ok
+		},
+	}
+}
+
+// ValueMatcher checks the aggregate of the selected series: the sum of their
+// values, and all of their observations.
+type ValueMatcher struct {
+	desc  string
+	match func(s Series) bool
+}
+
+func (m ValueMatcher) String() string { return m.desc }
+
+// Equal checks that the value is v.
+func Equal(v float64) ValueMatcher {
+	return ValueMatcher{
+		desc:  fmt.Sprintf("value = %v", v),
+		match: func(s Series) bool { return s.Value == v },
+	}
+}
+
+// Between checks that the value is within minValue..maxValue inclusive.
+func Between(minValue, maxValue float64) ValueMatcher {
+	return ValueMatcher{
+		desc:  fmt.Sprintf("value in %v..%v", minValue, maxValue),
+		match: func(s Series) bool { return s.Value >= minValue && s.Value <= maxValue },
+	}
+}
+
+// Approx checks that the value is within tolerance of v.
+func Approx(v, tolerance float64) ValueMatcher {
+	return Between(v-tolerance, v+tolerance)
+}
+
+// NonZero checks that the value isn't 0.
+func NonZero() ValueMatcher {
+	return ValueMatcher{
+		desc:  "value != 0",
+		match: func(s Series) bool { return s.Value != 0 },
+	}
+}
+
+// ObservationsBetween checks that all observations are within
+// minValue..maxValue inclusive.
+func ObservationsBetween(minValue, maxValue float64) ValueMatcher {
+	return ValueMatcher{
+		desc: fmt.Sprintf("observations in %v..%v", minValue, maxValue),
+		match: func(s Series) bool {
+			for _, o := range s.Observations {
+				if o < minValue || o > maxValue {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// Quantile checks that the q quantile of the observations, as estimated by an
+// exponential histogram, is within tolerance of v.
+func Quantile(q, v, tolerance float64) ValueMatcher {
+	return ValueMatcher{
+		desc: fmt.Sprintf("quantile %v = %v ± %v", q, v, tolerance),
+		match: func(s Series) bool {
+			h := xmetrics.NewExponentialHistogram(0)
+			for _, o := range s.Observations {
+				h.Observe(o)
+			}
+			got := h.Snapshot().Quantile(q)
+			return got >= v-tolerance && got <= v+tolerance
+		},
+	}
+}
+
+// Series returns all series of the Provider which were recorded to, sorted by
+// kind, name and labels.
+func (p *Provider) Series() []Series {
+	p.Lock()
+	defer p.Unlock()
+
+	var all []Series
+	for _, c := range p.counters {
+		if c.isRecorded() {
+			all = append(all, Series{Kind: KindCounter, Name: c.name, Labels: labelMap(c.labelValues), Value: c.getValue()})
+		}
+	}
+	for _, g := range p.gauges {
+		if g.isRecorded() {
+			all = append(all, Series{Kind: KindGauge, Name: g.name, Labels: labelMap(g.labelValues), Value: g.getValue()})
+		}
+	}
+	for _, h := range p.histograms {
+		if obs := h.getObservations(); len(obs) > 0 {
+			obs = append([]float64(nil), obs...)
+			all = append(all, Series{Kind: KindHistogram, Name: h.name, Labels: labelMap(h.labelValues), Value: float64(len(obs)), Observations: obs})
+		}
+	}
+	for _, c := range p.cardCounters {
+		if c.isRecorded() {
+			all = append(all, Series{Kind: KindCardinalityCounter, Name: c.Name, Labels: labelMap(c.lvs), Value: float64(c.Estimate())})
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].String() < all[j].String()
+	})
+	return all
+}
+
+// CheckMetric checks that there are recorded series of the kind with the name
+// matching all label matchers, and that their aggregate matches all value
+// matchers. E.g. to check that 2 to 5 requests failed, whatever their other
+// labels:
+//
+//	p.CheckMetric(testmetrics.KindCounter, "requests", testmetrics.Labels("status", "500"), testmetrics.Between(2, 5))
+func (p *Provider) CheckMetric(kind Kind, name string, matchers ...Matcher) {
+	p.t.Helper()
+
+	if err := p.matchMetric(kind, name, matchers); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// CheckMetricEventually is like CheckMetric, but polls until the check passes
+// or the timeout expires, for metrics recorded asynchronously.
+func (p *Provider) CheckMetricEventually(timeout time.Duration, kind Kind, name string, matchers ...Matcher) {
+	p.t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := p.matchMetric(kind, name, matchers)
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			p.t.Fatalf("after %v: %v", timeout, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// CheckNoOtherMetrics checks that no series were recorded but those of the
+// metrics checked with CheckMetric or CheckMetricEventually.
+func (p *Provider) CheckNoOtherMetrics() {
+	p.t.Helper()
+
+	var unchecked []string
+	for _, s := range p.Series() {
+		if !p.isChecked(s.Kind, s.Name) {
+			unchecked = append(unchecked, s.String())
+		}
+	}
+	if len(unchecked) > 0 {
+		p.t.Fatalf("unexpected metrics recorded:\n%s", strings.Join(unchecked, "\n"))
+	}
+}
+
+// CheckGolden checks that the kinds, names and labels of all recorded series
+// match the golden file at path. Values aren't compared, so that golden files
+// catch metrics being renamed or relabeled regardless of timings.
+//
+// Run tests with $TESTMETRICS_UPDATE_GOLDEN set to create or update golden
+// files.
+func (p *Provider) CheckGolden(path string) {
+	p.t.Helper()
+
+	var b strings.Builder
+	for _, s := range p.Series() {
+		b.WriteString(s.String())
+		b.WriteString("\n")
+	}
+	got := b.String()
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			p.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil { //nolint:gosec // golden files are not secret
+			p.t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		p.t.Fatalf("reading golden file (set $%s to create it): %v", UpdateGoldenEnv, err)
+	}
+	if got != string(want) {
+		p.t.Fatalf("metrics differ from golden file %s (set $%s to update it):\n--- want\n%s--- got\n%s", path, UpdateGoldenEnv, want, got)
+	}
+}
+
+func (p *Provider) matchMetric(kind Kind, name string, matchers []Matcher) error {
+	var (
+		labelMatchers []LabelMatcher
+		valueMatchers []ValueMatcher
+		descs         []string
+	)
+	for _, m := range matchers {
+		switch m := m.(type) {
+		case LabelMatcher:
+			labelMatchers = append(labelMatchers, m)
+		case ValueMatcher:
+			valueMatchers = append(valueMatchers, m)
+		default:
+			return fmt.Errorf("unsupported matcher %T", m)
+		}
+		descs = append(descs, m.String())
+	}
+	want := fmt.Sprintf("%s %s (%s)", kind, name, strings.Join(descs, ", "))
+
+	all := p.Series()
+	agg := Series{Kind: kind, Name: name}
+	var found bool
+	for _, s := range all {
+		if s.Kind != kind || s.Name != name || !matchLabels(s.Labels, labelMatchers) {
+			continue
+		}
+		found = true
+		agg.Value += s.Value
+		agg.Observations = append(agg.Observations, s.Observations...)
+	}
+
+	if !found {
+		available := make([]string, 0, len(all))
+		for _, s := range all {
+			available = append(available, s.String())
+		}
+		return fmt.Errorf("no series matching %s out of recorded series:\n%s", want, strings.Join(available, "\n"))
+	}
+
+	for _, m := range valueMatchers {
+		if !m.match(agg) {
+			return fmt.Errorf("%s: got value %v, observations %v", want, agg.Value, agg.Observations)
+		}
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if p.checked == nil {
+		p.checked = make(map[string]bool)
+	}
+	p.checked[string(kind)+" "+name] = true
+
+	return nil
+}
+
+func (p *Provider) isChecked(kind Kind, name string) bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.checked[string(kind)+" "+name]
+}
+
+func matchLabels(labels map[string]string, matchers []LabelMatcher) bool {
+	for _, m := range matchers {
+		if !m.match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// labelMap returns the labels of label values. A missing last value is set
+// to "unknown".
+func labelMap(labelValues []string) map[string]string {
+	m := make(map[string]string, len(labelValues)/2)
+	for i := 0; i < len(labelValues); i += 2 {
+		v := "unknown"
+		if i+1 < len(labelValues) {
+			v = labelValues[i+1]
+		}
+		m[labelValues[i]] = v
+	}
+	return m
+}
+
+// formatLabels returns labels as " k1=v1 k2=v2", sorted by key.
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(" " + k + "=" + labels[k])
+	}
+	return b.String()
+}
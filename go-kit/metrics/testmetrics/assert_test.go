@@ -0,0 +1,83 @@
+package testmetrics
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckMetric(t *testing.T) {
+	p := NewProvider(t)
+
+	p.NewCounter("requests").With("method", "GET", "status", "200").Add(3)
+	p.NewCounter("requests").With("method", "POST", "status", "500").Add(2)
+	p.NewGauge("connections").With("region", "us").Set(7)
+	h := p.NewHistogram("duration", 0).With("route", "/apps/:id")
+	for i := 1; i <= 100; i++ {
+		h.Observe(float64(i))
+	}
+	p.NewCardinalityCounter("users").Insert([]byte("a"))
+
+	p.CheckMetric(KindCounter, "requests", Equal(5))
+	p.CheckMetric(KindCounter, "requests", Labels("status", "500"), Equal(2))
+	p.CheckMetric(KindCounter, "requests", LabelMatching("status", "^2"), Between(1, 3))
+	p.CheckMetric(KindGauge, "connections", WithoutLabel("zone"), Approx(7.1, 0.2), NonZero())
+	p.CheckMetric(KindHistogram, "duration", Equal(100), ObservationsBetween(1, 100), Quantile(0.5, 50, 2))
+	p.CheckMetric(KindCardinalityCounter, "users", Equal(1))
+	p.CheckNoOtherMetrics()
+}
+
+func TestCheckMetricFailures(t *testing.T) {
+	p := NewProvider(t)
+	p.NewCounter("requests").With("status", "200").Add(3)
+	p.NewGauge("idle") // never set, so not recorded
+
+	tests := map[string]struct {
+		kind     Kind
+		name     string
+		matchers []Matcher
+	}{
+		"unknown name":       {kind: KindCounter, name: "errors"},
+		"wrong kind":         {kind: KindGauge, name: "requests"},
+		"not recorded":       {kind: KindGauge, name: "idle"},
+		"labels":             {kind: KindCounter, name: "requests", matchers: []Matcher{Labels("status", "500")}},
+		"label pattern":      {kind: KindCounter, name: "requests", matchers: []Matcher{LabelMatching("status", "^5")}},
+		"without label":      {kind: KindCounter, name: "requests", matchers: []Matcher{WithoutLabel("status")}},
+		"value":              {kind: KindCounter, name: "requests", matchers: []Matcher{Equal(4)}},
+		"value out of range": {kind: KindCounter, name: "requests", matchers: []Matcher{Between(4, 10)}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := p.matchMetric(test.kind, test.name, test.matchers); err == nil {
+				t.Fatal("want error, got nil")
+			}
+		})
+	}
+
+	if p.isChecked(KindCounter, "requests") {
+		t.Fatal("want failed checks not to count as checked")
+	}
+}
+
+func TestCheckMetricEventually(t *testing.T) {
+	p := NewProvider(t)
+	c := p.NewCounter("jobs")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		c.Add(1)
+	}()
+
+	p.CheckMetricEventually(5*time.Second, KindCounter, "jobs", Equal(1))
+}
+
+func TestCheckGolden(t *testing.T) {
+	p := NewProvider(t)
+	p.NewCounter("requests").With("status", "200", "method", "GET").Add(1)
+	p.NewCounter("requests").With("status", "500", "method", "GET").Add(1)
+	p.NewGauge("connections").Set(1)
+	p.NewHistogram("duration", 0).With("route").Observe(1)
+
+	p.CheckGolden(filepath.Join("testdata", "golden.txt"))
+}
@@ -16,6 +16,7 @@ type Counter struct {
 	p           *Provider
 	labelValues []string
 	value       float64
+	recorded    bool
 	sync.RWMutex
 }
 
@@ -24,6 +25,7 @@ func (c *Counter) Add(delta float64) {
 	c.Lock()
 	defer c.Unlock()
 	c.value += delta
+	c.recorded = true
 }
 
 // With implements the metrics.Counter interface.
@@ -38,12 +40,19 @@ func (c *Counter) getValue() float64 {
 	return c.value
 }
 
+func (c *Counter) isRecorded() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.recorded
+}
+
 // Gauge stores a value based on Add/Set calls.
 type Gauge struct {
 	name        string
 	p           *Provider
 	labelValues []string
 	value       float64
+	recorded    bool
 	sync.RWMutex
 }
 
@@ -52,6 +61,7 @@ func (g *Gauge) Add(delta float64) {
 	g.Lock()
 	defer g.Unlock()
 	g.value += delta
+	g.recorded = true
 }
 
 // Set implements the metrics.Gauge interface.
@@ -59,6 +69,7 @@ func (g *Gauge) Set(v float64) {
 	g.Lock()
 	defer g.Unlock()
 	g.value = v
+	g.recorded = true
 }
 
 // With implements the metrics.Gauge interface.
@@ -73,12 +84,19 @@ func (g *Gauge) getValue() float64 {
 	return g.value
 }
 
+func (g *Gauge) isRecorded() bool {
+	g.RLock()
+	defer g.RUnlock()
+	return g.recorded
+}
+
 // Histogram collects observations without computing quantiles
 // so the observations can be checked by tests.
 type Histogram struct {
 	name         string
 	p            *Provider
 	labelValues  []string
+	maxSize      int
 	observations []float64
 	sync.RWMutex
 }
@@ -101,17 +119,18 @@ func (h *Histogram) Observe(v float64) {
 // With implements the metrics.Histogram interface.
 func (h *Histogram) With(labelValues ...string) metrics.Histogram {
 	lvs := append(append([]string(nil), h.labelValues...), labelValues...)
-	return h.p.newHistogram(h.name, lvs...)
+	return h.p.newHistogram(h.name, h.maxSize, lvs...)
 }
 
 // CardinalityCounter provides a wrapper around a HyperLogLog probabalistic
 // counter. It implements CardinalityCounter Interface.
 type CardinalityCounter struct {
-	Name    string
-	lvs     []string
-	mu      sync.Mutex
-	counter *hll.Sketch
-	p       *Provider
+	Name     string
+	lvs      []string
+	mu       sync.Mutex
+	counter  *hll.Sketch
+	recorded bool
+	p        *Provider
 	sync.RWMutex
 }
 
@@ -126,6 +145,13 @@ func (c *CardinalityCounter) Insert(i []byte) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.counter.Insert(i)
+	c.recorded = true
+}
+
+func (c *CardinalityCounter) isRecorded() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recorded
 }
 
 func (c *CardinalityCounter) Estimate() uint64 {
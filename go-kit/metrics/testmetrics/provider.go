@@ -27,6 +27,9 @@ type Provider struct {
 	histograms   map[string]*Histogram
 	cardCounters map[string]*CardinalityCounter
 	stopped      bool
+
+	// kinds and names of the metrics checked by CheckMetric
+	checked map[string]bool
 }
 
 // NewProvider constructs a test provider which can later be checked.
@@ -82,21 +85,27 @@ func (p *Provider) newGauge(name string, labelValues ...string) metrics.Gauge {
 
 // NewHistogram implements go-kit's Provider interface.
 func (p *Provider) NewHistogram(name string, _ int) metrics.Histogram {
-	return p.newHistogram(name)
+	return p.newHistogram(name, 0)
 }
 
 // NewExplicitHistogram implements go-kit's Provider interface.
 func (p *Provider) NewExplicitHistogram(name string, _ xmetrics.DistributionFunc) metrics.Histogram {
-	return p.newHistogram(name)
+	return p.newHistogram(name, 0)
+}
+
+// NewExponentialHistogram implements xmetrics.ExponentialHistogramProvider.
+// maxSize is used by CheckQuantile and HistogramSnapshot.
+func (p *Provider) NewExponentialHistogram(name string, maxSize int) metrics.Histogram {
+	return p.newHistogram(name, maxSize)
 }
 
-func (p *Provider) newHistogram(name string, labelValues ...string) metrics.Histogram {
+func (p *Provider) newHistogram(name string, maxSize int, labelValues ...string) metrics.Histogram {
 	p.Lock()
 	defer p.Unlock()
 
 	k := p.keyFor(name, labelValues...)
 	if _, ok := p.histograms[k]; !ok {
-		p.histograms[k] = &Histogram{name: name, p: p, labelValues: labelValues, observations: []float64{}}
+		p.histograms[k] = &Histogram{name: name, p: p, labelValues: labelValues, maxSize: maxSize, observations: []float64{}}
 	}
 	return p.histograms[k]
 }
@@ -244,9 +253,41 @@ func (p *Provider) CheckObservationCount(name string, n int, labelValues ...stri
 	}
 }
 
+// CheckQuantile checks that there is a histogram with the name, and that the
+// q quantile of its observations, as estimated by an exponential histogram,
+// is within tolerance of v.
+func (p *Provider) CheckQuantile(name string, q, v, tolerance float64, labelValues ...string) {
+	p.t.Helper()
+
+	got := p.HistogramSnapshot(name, labelValues...).Quantile(q)
+	if got < v-tolerance || got > v+tolerance {
+		p.t.Fatalf("%v quantile %v = %v, want %v ± %v", p.keyFor(name, labelValues...), q, got, v, tolerance)
+	}
+}
+
+// HistogramSnapshot returns an exponential histogram snapshot of the
+// observations of the histogram with the name.
+func (p *Provider) HistogramSnapshot(name string, labelValues ...string) xmetrics.ExponentialHistogramSnapshot {
+	p.t.Helper()
+
+	h := p.getHistogram(name, labelValues...)
+
+	eh := xmetrics.NewExponentialHistogram(h.maxSize)
+	for _, o := range h.getObservations() {
+		eh.Observe(o)
+	}
+	return eh.Snapshot()
+}
+
 func (p *Provider) getObservations(name string, labelValues ...string) []float64 {
 	p.t.Helper()
 
+	return p.getHistogram(name, labelValues...).getObservations()
+}
+
+func (p *Provider) getHistogram(name string, labelValues ...string) *Histogram {
+	p.t.Helper()
+
 	p.Lock()
 	defer p.Unlock()
 
@@ -261,7 +302,7 @@ func (p *Provider) getObservations(name string, labelValues ...string) []float64
 		p.t.Fatalf("no histogram named %s out available histograms: \n%s", k, available)
 	}
 
-	return h.getObservations()
+	return h
 }
 
 // CheckGauge checks that there is a registered gauge
@@ -0,0 +1,311 @@
+package runtimemetrics
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	kitmetrics "github.com/go-kit/kit/metrics"
+
+	"github.com/heroku/x/cmdutil"
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/tickgroup"
+)
+
+// maxHistogramObservations bounds the number of observations a
+// MetricsCollector makes per runtime histogram and collection. Above it, the
+// counts of the runtime's buckets are scaled down, preserving the shape of
+// the distribution but not the number of events.
+const maxHistogramObservations = 1000
+
+// maxHistogramBounds bounds the number of bucket boundaries of the explicit
+// histograms a MetricsCollector reports runtime histograms with, as each
+// boundary is a series with most providers.
+const maxHistogramBounds = 20
+
+// Names of the runtime/metrics samples read by MetricsCollector.
+const (
+	sampleGoroutines      = "/sched/goroutines:goroutines"
+	sampleGOMAXPROCS      = "/sched/gomaxprocs:threads"
+	sampleSchedLatencies  = "/sched/latencies:seconds"
+	sampleGCPauses        = "/sched/pauses/total/gc:seconds"
+	sampleGCCycles        = "/gc/cycles/total:gc-cycles"
+	sampleGCHeapGoal      = "/gc/heap/goal:bytes"
+	sampleGOMEMLIMIT      = "/gc/gomemlimit:bytes"
+	sampleGCCPUSeconds    = "/cpu/classes/gc/total:cpu-seconds"
+	sampleTotalCPUSeconds = "/cpu/classes/total:cpu-seconds"
+	sampleMutexWait       = "/sync/mutex/wait/total:seconds"
+	sampleMemoryTotal     = "/memory/classes/total:bytes"
+
+	heapClassPrefix = "/memory/classes/heap/"
+)
+
+// MetricsCollector collects metrics about the Go runtime into go-kit
+// metrics, using the runtime/metrics package. Unlike Collector, it doesn't
+// stop the world.
+//
+// It collects the following metrics:
+//
+//	go.goroutines - number of goroutines
+//	go.sched.gomaxprocs - value of GOMAXPROCS
+//	go.sched.latency.ms - histogram of the time goroutines spent runnable before running
+//	go.gc.pause-duration.ms - histogram of GC stop-the-world pauses
+//	go.gc.cycles - number of completed GC cycles
+//	go.gc.cpu-fraction - fraction of CPU time spent on GC since the last collection
+//	go.gc.next-target-heap-size-bytes - target heap size of the next GC cycle
+//	go.gc.gomemlimit-bytes - value of GOMEMLIMIT
+//	go.sync.mutex-wait.ms - time goroutines spent blocked on mutexes
+//	go.mem.total-bytes - bytes mapped by the runtime
+//	go.mem.heap.<class>-bytes - heap bytes by class, e.g. go.mem.heap.objects-bytes
+//
+// Runtime histograms are reported with exponential histograms if the provider
+// implements xmetrics.ExponentialHistogramProvider. Otherwise, they are
+// reported with NewExplicitHistogram, using at most 20 of the runtime's bucket
+// boundaries, evenly picked. As go-kit histograms only accept single
+// observations, each bucket is observed at its midpoint as many times as it
+// gained events, scaled down if needed so that at most 1000 observations are
+// made per histogram and collection.
+type MetricsCollector struct {
+	Goroutines      kitmetrics.Gauge
+	GOMAXPROCS      kitmetrics.Gauge
+	SchedLatency    kitmetrics.Histogram
+	GCPauseDuration kitmetrics.Histogram
+	GCCycles        kitmetrics.Counter
+	GCCPUFraction   kitmetrics.Gauge
+	NextGCBytes     kitmetrics.Gauge
+	GOMEMLIMIT      kitmetrics.Gauge
+	MutexWait       kitmetrics.Counter
+	TotalBytes      kitmetrics.Gauge
+
+	// HeapBytes holds a gauge per heap memory class, by runtime/metrics name.
+	HeapBytes map[string]kitmetrics.Gauge
+
+	samples []metrics.Sample
+	index   map[string]int
+
+	// previous values of cumulative metrics, to report deltas
+	prevCounts       map[string][]uint64
+	prevGCCycles     uint64
+	prevGCCPUSeconds float64
+	prevCPUSeconds   float64
+	prevMutexWait    float64
+}
+
+// NewMetricsCollector returns a collector whose metrics are registered with
+// p.
+func NewMetricsCollector(p xmetrics.Provider) *MetricsCollector {
+	names := []string{
+		sampleGoroutines,
+		sampleGOMAXPROCS,
+		sampleSchedLatencies,
+		sampleGCPauses,
+		sampleGCCycles,
+		sampleGCHeapGoal,
+		sampleGOMEMLIMIT,
+		sampleGCCPUSeconds,
+		sampleTotalCPUSeconds,
+		sampleMutexWait,
+		sampleMemoryTotal,
+	}
+
+	c := &MetricsCollector{
+		Goroutines:    p.NewGauge("go.goroutines"),
+		GOMAXPROCS:    p.NewGauge("go.sched.gomaxprocs"),
+		GCCycles:      p.NewCounter("go.gc.cycles"),
+		GCCPUFraction: p.NewGauge("go.gc.cpu-fraction"),
+		NextGCBytes:   p.NewGauge("go.gc.next-target-heap-size-bytes"),
+		GOMEMLIMIT:    p.NewGauge("go.gc.gomemlimit-bytes"),
+		MutexWait:     p.NewCounter("go.sync.mutex-wait.ms"),
+		TotalBytes:    p.NewGauge("go.mem.total-bytes"),
+		HeapBytes:     make(map[string]kitmetrics.Gauge),
+		index:         make(map[string]int),
+		prevCounts:    make(map[string][]uint64),
+	}
+
+	for _, d := range metrics.All() {
+		if !strings.HasPrefix(d.Name, heapClassPrefix) || d.Kind != metrics.KindUint64 {
+			continue
+		}
+		class := strings.TrimSuffix(strings.TrimPrefix(d.Name, heapClassPrefix), ":bytes")
+		c.HeapBytes[d.Name] = p.NewGauge("go.mem.heap." + class + "-bytes")
+		names = append(names, d.Name)
+	}
+
+	c.samples = make([]metrics.Sample, len(names))
+	for i, name := range names {
+		c.samples[i].Name = name
+		c.index[name] = i
+	}
+
+	// Bucket boundaries of runtime histograms are fixed for the life of
+	// the process, so they can be read once.
+	metrics.Read(c.samples)
+	c.SchedLatency = c.newHistogram(p, "go.sched.latency.ms", sampleSchedLatencies)
+	c.GCPauseDuration = c.newHistogram(p, "go.gc.pause-duration.ms", sampleGCPauses)
+
+	return c
+}
+
+// Collect reads the runtime's metrics and updates the collector's metrics.
+func (c *MetricsCollector) Collect() {
+	metrics.Read(c.samples)
+
+	c.Goroutines.Set(float64(c.uint64(sampleGoroutines)))
+	c.GOMAXPROCS.Set(float64(c.uint64(sampleGOMAXPROCS)))
+	c.NextGCBytes.Set(float64(c.uint64(sampleGCHeapGoal)))
+	c.GOMEMLIMIT.Set(float64(c.uint64(sampleGOMEMLIMIT)))
+	c.TotalBytes.Set(float64(c.uint64(sampleMemoryTotal)))
+	for name, g := range c.HeapBytes {
+		g.Set(float64(c.uint64(name)))
+	}
+
+	cycles := c.uint64(sampleGCCycles)
+	c.GCCycles.Add(float64(cycles - c.prevGCCycles))
+	c.prevGCCycles = cycles
+
+	gcCPU, totalCPU := c.float64(sampleGCCPUSeconds), c.float64(sampleTotalCPUSeconds)
+	if d := totalCPU - c.prevCPUSeconds; d > 0 {
+		c.GCCPUFraction.Set((gcCPU - c.prevGCCPUSeconds) / d)
+	}
+	c.prevGCCPUSeconds, c.prevCPUSeconds = gcCPU, totalCPU
+
+	wait := c.float64(sampleMutexWait)
+	c.MutexWait.Add((wait - c.prevMutexWait) * 1000)
+	c.prevMutexWait = wait
+
+	c.observe(sampleSchedLatencies, c.SchedLatency)
+	c.observe(sampleGCPauses, c.GCPauseDuration)
+}
+
+// NewServer returns a cmdutil.Server collecting runtime metrics into p
+// immediately, and then every interval until stopped.
+func NewServer(p xmetrics.Provider, interval time.Duration) cmdutil.Server {
+	c := NewMetricsCollector(p)
+
+	return cmdutil.NewContextServer(func(ctx context.Context) error {
+		g := tickgroup.New(ctx)
+		g.Go(interval, func() error {
+			c.Collect()
+			return nil
+		})
+		return g.Wait()
+	})
+}
+
+// observe records the events added to the runtime histogram name since the
+// last collection into h, in milliseconds.
+func (c *MetricsCollector) observe(name string, h kitmetrics.Histogram) {
+	if hist := c.histogram(name); hist != nil {
+		c.observeHistogram(name, hist, h)
+	}
+}
+
+func (c *MetricsCollector) observeHistogram(name string, hist *metrics.Float64Histogram, h kitmetrics.Histogram) {
+	prev := c.prevCounts[name]
+	deltas := make([]uint64, len(hist.Counts))
+	var total uint64
+	for i, n := range hist.Counts {
+		if i < len(prev) {
+			n -= prev[i]
+		}
+		deltas[i] = n
+		total += n
+	}
+	c.prevCounts[name] = append(prev[:0], hist.Counts...)
+
+	scale := 1.0
+	if total > maxHistogramObservations {
+		scale = maxHistogramObservations / float64(total)
+	}
+
+	for i, n := range deltas {
+		if n == 0 {
+			continue
+		}
+		v := midpoint(hist.Buckets[i], hist.Buckets[i+1]) * 1000
+		for j := math.Round(float64(n) * scale); j > 0; j-- {
+			h.Observe(v)
+		}
+	}
+}
+
+// newHistogram returns a histogram from p for the runtime histogram sample.
+func (c *MetricsCollector) newHistogram(p xmetrics.Provider, name, sample string) kitmetrics.Histogram {
+	if ep, ok := p.(xmetrics.ExponentialHistogramProvider); ok {
+		return ep.NewExponentialHistogram(name, 0)
+	}
+	return p.NewExplicitHistogram(name, c.boundaries(sample))
+}
+
+// boundaries returns at most maxHistogramBounds of the finite bucket
+// boundaries of the runtime histogram name, in milliseconds.
+func (c *MetricsCollector) boundaries(name string) xmetrics.DistributionFunc {
+	var bounds []float64
+	if hist := c.histogram(name); hist != nil {
+		for _, b := range hist.Buckets {
+			if !math.IsInf(b, 0) {
+				bounds = append(bounds, b*1000)
+			}
+		}
+	}
+	bounds = downsample(bounds, maxHistogramBounds)
+
+	return func() []float64 {
+		return bounds
+	}
+}
+
+// downsample returns at most n of bounds, evenly picked and including the
+// first and last ones. As runtime buckets grow exponentially, so do the
+// picked ones.
+func downsample(bounds []float64, n int) []float64 {
+	if len(bounds) <= n {
+		return bounds
+	}
+
+	picked := make([]float64, n)
+	last := len(bounds) - 1
+	for i := range picked {
+		picked[i] = bounds[i*last/(n-1)]
+	}
+	return picked
+}
+
+// The accessors below return zero values for metrics unsupported by the
+// running Go version, rather than panicking.
+
+func (c *MetricsCollector) uint64(name string) uint64 {
+	if v := c.samples[c.index[name]].Value; v.Kind() == metrics.KindUint64 {
+		return v.Uint64()
+	}
+	return 0
+}
+
+func (c *MetricsCollector) float64(name string) float64 {
+	if v := c.samples[c.index[name]].Value; v.Kind() == metrics.KindFloat64 {
+		return v.Float64()
+	}
+	return 0
+}
+
+func (c *MetricsCollector) histogram(name string) *metrics.Float64Histogram {
+	if v := c.samples[c.index[name]].Value; v.Kind() == metrics.KindFloat64Histogram {
+		return v.Float64Histogram()
+	}
+	return nil
+}
+
+// midpoint returns the middle of a bucket, or its finite bound if the other
+// is infinite.
+func midpoint(lower, upper float64) float64 {
+	switch {
+	case math.IsInf(lower, -1):
+		return upper
+	case math.IsInf(upper, 1):
+		return lower
+	default:
+		return (lower + upper) / 2
+	}
+}
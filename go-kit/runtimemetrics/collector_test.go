@@ -0,0 +1,106 @@
+package runtimemetrics
+
+import (
+	"runtime"
+	"runtime/metrics"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/heroku/x/go-kit/metrics/provider/discard"
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	p := testmetrics.NewProvider(t)
+	c := NewMetricsCollector(p)
+
+	runtime.GC()
+	c.Collect()
+
+	p.CheckGaugeNonZero("go.goroutines")
+	p.CheckGauge("go.sched.gomaxprocs", float64(runtime.GOMAXPROCS(0)))
+	p.CheckGaugeNonZero("go.gc.next-target-heap-size-bytes")
+	p.CheckGaugeNonZero("go.gc.gomemlimit-bytes")
+	p.CheckGaugeNonZero("go.mem.total-bytes")
+	p.CheckGaugeNonZero("go.mem.heap.objects-bytes")
+	p.CheckMetric(testmetrics.KindCounter, "go.gc.cycles", testmetrics.NonZero())
+	p.CheckMetric(testmetrics.KindGauge, "go.gc.cpu-fraction", testmetrics.Between(0, 1))
+	p.CheckMetric(testmetrics.KindHistogram, "go.gc.pause-duration.ms", testmetrics.NonZero())
+	p.CheckMetric(testmetrics.KindCounter, "go.sync.mutex-wait.ms")
+}
+
+func TestMetricsCollectorDeltas(t *testing.T) {
+	p := testmetrics.NewProvider(t)
+	c := NewMetricsCollector(p)
+
+	c.Collect()
+	before := p.HistogramSnapshot("go.gc.pause-duration.ms").Count
+
+	// each GC cycle stops the world twice
+	runtime.GC()
+	c.Collect()
+
+	if got := p.HistogramSnapshot("go.gc.pause-duration.ms").Count - before; got < 2 || got > maxHistogramObservations {
+		t.Fatalf("want the new pauses observed, got %d", got)
+	}
+}
+
+func TestMetricsCollectorScalesHistograms(t *testing.T) {
+	p := testmetrics.NewProvider(t)
+	c := NewMetricsCollector(p)
+	h := p.NewHistogram("test", 0)
+
+	hist := &metrics.Float64Histogram{
+		Counts:  []uint64{0, 5000, 15000},
+		Buckets: []float64{0, 0.001, 0.002, 0.003},
+	}
+	c.observeHistogram("test", hist, h)
+
+	p.CheckObservationCount("test", maxHistogramObservations)
+	p.CheckMetric(testmetrics.KindHistogram, "test", testmetrics.ObservationsBetween(1.5, 2.5), testmetrics.Quantile(0.5, 2.5, 0.01))
+
+	// only new events are observed
+	hist.Counts = []uint64{1, 5000, 15000}
+	c.observeHistogram("test", hist, h)
+
+	p.CheckObservationCount("test", maxHistogramObservations+1)
+}
+
+func TestMetricsCollectorExplicitHistograms(t *testing.T) {
+	c := NewMetricsCollector(discard.New())
+
+	bounds := c.boundaries(sampleGCPauses)()
+	if len(bounds) == 0 || len(bounds) > maxHistogramBounds {
+		t.Fatalf("want at most %d bounds, got %d", maxHistogramBounds, len(bounds))
+	}
+	if !slices.IsSorted(bounds) {
+		t.Fatalf("want sorted bounds, got %v", bounds)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	bounds := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	if got := downsample(bounds, 20); !slices.Equal(got, bounds) {
+		t.Errorf("want bounds kept, got %v", got)
+	}
+	if got, want := downsample(bounds, 4), []float64{1, 4, 7, 10}; !slices.Equal(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	p := testmetrics.NewProvider(t)
+	s := NewServer(p, time.Hour)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run() }()
+
+	p.CheckMetricEventually(5*time.Second, testmetrics.KindGauge, "go.goroutines", testmetrics.NonZero())
+
+	s.Stop(nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -11,4 +11,9 @@
 //	go.mem.frees - cumultaive number of freed heap objects
 //	go.gc.pause-duration - histogram of GC pause durations
 //	go.gc.next-target-heap-size-bytes - target heap size of the next GC cycle
+//
+// Collector reads runtime.MemStats, which stops the world. MetricsCollector
+// reads the runtime/metrics package instead, and reports scheduler and GC
+// detail as well. NewServer runs a MetricsCollector on an interval as a
+// cmdutil.Server.
 package runtimemetrics
@@ -11,6 +11,7 @@ import (
 	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
 	grpc_validator "github.com/grpc-ecosystem/go-grpc-middleware/validator"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -38,6 +39,7 @@ type options struct {
 	highCardUnaryInterceptor  grpc.UnaryServerInterceptor
 	highCardStreamInterceptor grpc.StreamServerInterceptor
 	readHeaderTimeout         time.Duration
+	tracerProvider            trace.TracerProvider
 
 	useValidateInterceptor bool
 
@@ -122,6 +124,10 @@ func (o *options) unaryInterceptors() []grpc.UnaryServerInterceptor {
 		unaryPeerNameTagger,
 	}
 
+	if o.tracerProvider != nil {
+		i = append(i, unaryServerTracer(o.tracerProvider))
+	}
+
 	if o.highCardUnaryInterceptor != nil {
 		i = append(i, o.highCardUnaryInterceptor)
 	} else if o.metricsProvider != nil {
@@ -155,6 +161,10 @@ func (o *options) streamInterceptors() []grpc.StreamServerInterceptor {
 		streamPeerNameTagger,
 	}
 
+	if o.tracerProvider != nil {
+		i = append(i, streamServerTracer(o.tracerProvider))
+	}
+
 	if o.highCardStreamInterceptor != nil {
 		i = append(i, o.highCardStreamInterceptor)
 	} else if o.metricsProvider != nil {
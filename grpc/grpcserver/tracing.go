@@ -0,0 +1,102 @@
+package grpcserver
+
+import (
+	"context"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/heroku/x/grpc/requestid"
+)
+
+const tracerName = "github.com/heroku/x/grpc/grpcserver"
+
+// Tracing starts a server span for every call, continuing the trace
+// propagated in the incoming metadata, if any. The request ID is recorded as
+// the `request_id` attribute to correlate spans with logs.
+func Tracing(tp trace.TracerProvider) ServerOption {
+	return func(o *options) {
+		o.tracerProvider = tp
+	}
+}
+
+func unaryServerTracer(tp trace.TracerProvider) grpc.UnaryServerInterceptor {
+	tracer := tp.Tracer(tracerName)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ interface{}, err error) {
+		ctx, span := startServerSpan(ctx, tracer, info.FullMethod)
+		defer func() { endServerSpan(span, err) }()
+
+		return handler(ctx, req)
+	}
+}
+
+func streamServerTracer(tp trace.TracerProvider) grpc.StreamServerInterceptor {
+	tracer := tp.Tracer(tracerName)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, span := startServerSpan(ss.Context(), tracer, info.FullMethod)
+		defer func() { endServerSpan(span, err) }()
+
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+func startServerSpan(ctx context.Context, tracer trace.Tracer, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	attrs := []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+	}
+	if id, ok := requestid.FromContext(ctx); ok {
+		attrs = append(attrs, attribute.String("request_id", id))
+	}
+
+	return tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func endServerSpan(span trace.Span, err error) {
+	code := ErrorToCode(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	if code != codes.OK {
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+	span.End()
+}
+
+// metadataCarrier adapts incoming metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+var _ propagation.TextMapCarrier = metadataCarrier(nil)
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,86 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/heroku/x/grpc/requestid"
+)
+
+func TestUnaryServerTracer(t *testing.T) {
+	prev := otelapi.GetTextMapPropagator()
+	otelapi.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otelapi.SetTextMapPropagator(prev) })
+
+	tests := map[string]struct {
+		err        error
+		wantStatus otelcodes.Code
+	}{
+		"ok":    {wantStatus: otelcodes.Unset},
+		"error": {err: status.Error(codes.NotFound, "nope"), wantStatus: otelcodes.Error},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			_, parent := tp.Tracer("test").Start(context.Background(), "parent")
+			parent.End()
+
+			md := requestid.NewMetadata("abc")
+			propagation.TraceContext{}.Inject(trace.ContextWithSpan(context.Background(), parent), metadataCarrier(md))
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			var gotSpan trace.SpanContext
+			handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+				gotSpan = trace.SpanContextFromContext(ctx)
+				return nil, test.err
+			}
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}
+			if _, err := unaryServerTracer(tp)(ctx, nil, info, handler); err != test.err {
+				t.Fatalf("want error %v, got %v", test.err, err)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("want 2 spans, got %d", len(spans))
+			}
+			span := spans[1]
+
+			if gotSpan.SpanID() != span.SpanContext.SpanID() {
+				t.Errorf("want span in handler context")
+			}
+			if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("want span to continue the propagated trace")
+			}
+			if span.Name != info.FullMethod {
+				t.Errorf("want name %q, got %q", info.FullMethod, span.Name)
+			}
+			if span.Status.Code != test.wantStatus {
+				t.Errorf("want status %v, got %v", test.wantStatus, span.Status.Code)
+			}
+
+			attrs := attribute.NewSet(span.Attributes...)
+			if v, _ := attrs.Value("request_id"); v.AsString() != "abc" {
+				t.Errorf("want request_id abc, got %q", v.AsString())
+			}
+			if v, _ := attrs.Value("rpc.system"); v.AsString() != "grpc" {
+				t.Errorf("want rpc.system grpc, got %q", v.AsString())
+			}
+		})
+	}
+}
@@ -92,7 +92,7 @@ func Report(ctx context.Context, endpoint string, ef ErrHandler) error {
 		return err
 	}
 
-	report(ctx, &http.Client{Timeout: 20 * time.Second}, endpoint, errorHandler(ef))
+	report(ctx, &http.Client{Timeout: 20 * time.Second}, endpoint, errorHandler(ef), NewRuntimeSource())
 
 	mu.Lock()
 	defer mu.Unlock()
@@ -132,13 +132,10 @@ func errorHandler(ef ErrHandler) ErrHandler {
 	return ef
 }
 
-func report(ctx context.Context, client *http.Client, endpoint string, ef ErrHandler) {
+func report(ctx context.Context, client *http.Client, endpoint string, ef ErrHandler, sources ...Source) {
 	t := time.NewTicker(interval)
 	defer t.Stop()
 
-	var buf bytes.Buffer
-	var pauseTotalNS uint64
-	var numGC uint32
 	for {
 		select {
 		case <-t.C:
@@ -146,51 +143,88 @@ func report(ctx context.Context, client *http.Client, endpoint string, ef ErrHan
 			return
 		}
 
-		buf.Reset()
-
-		var err error
-		pauseTotalNS, numGC, err = gatherMetrics(&buf, pauseTotalNS, numGC)
-		if err != nil {
+		if err := Submit(ctx, client, endpoint, sources...); err != nil {
 			if err := ef(err); err != nil {
 				return
 			}
-			continue
 		}
-		if err := submitMetrics(ctx, client, &buf, endpoint); err != nil {
-			if err := ef(err); err != nil {
-				return
-			}
-			continue
+	}
+}
+
+// Metrics is the JSON payload accepted by Heroku language metrics. Counters
+// hold the increments since the last report, gauges their current value.
+type Metrics struct {
+	Counters map[string]float64 `json:"counters"`
+	Gauges   map[string]float64 `json:"gauges"`
+}
+
+// Source gathers metrics to report. Sources are gathered once per report,
+// into the same Metrics.
+type Source interface {
+	Gather(m *Metrics) error
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(m *Metrics) error
+
+// Gather implements Source.
+func (f SourceFunc) Gather(m *Metrics) error {
+	return f(m)
+}
+
+// Submit gathers metrics from sources and submits them to the endpoint using
+// client. Nothing is submitted if a source fails.
+func Submit(ctx context.Context, client *http.Client, endpoint string, sources ...Source) error {
+	m := Metrics{
+		Counters: make(map[string]float64),
+		Gauges:   make(map[string]float64),
+	}
+	for _, s := range sources {
+		if err := s.Gather(&m); err != nil {
+			return err
 		}
 	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(m); err != nil {
+		return err
+	}
+	return submitMetrics(ctx, client, &buf, endpoint)
+}
+
+// NewRuntimeSource returns a Source of Go runtime metrics: garbage
+// collections, memory use and goroutines. It is the Source used by Report.
+func NewRuntimeSource() Source {
+	return &runtimeSource{}
+}
+
+type runtimeSource struct {
+	mu           sync.Mutex
+	pauseTotalNS uint64
+	numGC        uint32
 }
 
-// gatherMetrics and write the JSON encoded representation to w.
-// returns the sampled PauseTotalNs+NumGC & any encoding errors.
+// Gather implements Source.
 // TODO: If we ever have high frequency charts HeapIdle minus HeapReleased could be interesting.
-func gatherMetrics(w io.Writer, prevPauseTotalNS uint64, prevNumGC uint32) (uint64, uint32, error) {
+func (s *runtimeSource) Gather(m *Metrics) error {
 	var stats runtime.MemStats
 	runtime.ReadMemStats(&stats)
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// cribbed from https://github.com/codahale/metrics/blob/master/runtime/memstats.go
-	result := struct {
-		Counters map[string]float64 `json:"counters"`
-		Gauges   map[string]float64 `json:"gauges"`
-	}{
-		Counters: map[string]float64{
-			"go.gc.collections": float64(stats.NumGC - prevNumGC),
-			"go.gc.pause.ns":    float64(stats.PauseTotalNs - prevPauseTotalNS),
-		},
-		Gauges: map[string]float64{
-			"go.memory.heap.bytes":   float64(stats.Alloc),
-			"go.memory.stack.bytes":  float64(stats.StackInuse),
-			"go.memory.heap.objects": float64(stats.Mallocs - stats.Frees), // Number of "live" objects.
-			"go.gc.goal":             float64(stats.NextGC),                // Goal heap size for next GC.
-			"go.routines":            float64(runtime.NumGoroutine()),      // Current number of goroutines.
-		},
-	}
+	m.Counters["go.gc.collections"] = float64(stats.NumGC - s.numGC)
+	m.Counters["go.gc.pause.ns"] = float64(stats.PauseTotalNs - s.pauseTotalNS)
+
+	m.Gauges["go.memory.heap.bytes"] = float64(stats.Alloc)
+	m.Gauges["go.memory.stack.bytes"] = float64(stats.StackInuse)
+	m.Gauges["go.memory.heap.objects"] = float64(stats.Mallocs - stats.Frees) // Number of "live" objects.
+	m.Gauges["go.gc.goal"] = float64(stats.NextGC)                            // Goal heap size for next GC.
+	m.Gauges["go.routines"] = float64(runtime.NumGoroutine())                 // Current number of goroutines.
 
-	return stats.PauseTotalNs, stats.NumGC, json.NewEncoder(w).Encode(result)
+	s.pauseTotalNS, s.numGC = stats.PauseTotalNs, stats.NumGC
+	return nil
 }
 
 // submitMetrics read from r to the endpoint using the provided client
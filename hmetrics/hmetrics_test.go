@@ -13,3 +13,19 @@ func TestStartable_EmptyEndpoint(t *testing.T) {
 		t.Errorf("Expected an error, but got nil instead")
 	}
 }
+
+func TestRuntimeSource(t *testing.T) {
+	m := Metrics{Counters: make(map[string]float64), Gauges: make(map[string]float64)}
+	if err := NewRuntimeSource().Gather(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"go.gc.collections", "go.gc.pause.ns"} {
+		if _, ok := m.Counters[name]; !ok {
+			t.Errorf("want counter %s, got %v", name, m.Counters)
+		}
+	}
+	if m.Gauges["go.routines"] < 1 {
+		t.Errorf("want at least 1 goroutine, got %v", m.Gauges["go.routines"])
+	}
+}
@@ -0,0 +1,107 @@
+package hmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	tests := map[string]struct {
+		status     int
+		wantStatus codes.Code
+	}{
+		"ok":    {status: http.StatusOK, wantStatus: codes.Unset},
+		"error": {status: http.StatusBadGateway, wantStatus: codes.Error},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			var gotSpan trace.SpanContext
+			r := chi.NewRouter()
+			r.Use(RequestID, Tracing(tp))
+			r.Get("/apps/{id}", func(w http.ResponseWriter, r *http.Request) {
+				gotSpan = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(test.status)
+			})
+
+			req := httptest.NewRequest("GET", "/apps/123", nil)
+			req.Header.Set("Request-Id", "abc")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("want 1 span, got %d", len(spans))
+			}
+			span := spans[0]
+
+			if !gotSpan.IsValid() || gotSpan.SpanID() != span.SpanContext.SpanID() {
+				t.Errorf("want span in handler context")
+			}
+			if want := "GET /apps/{id}"; span.Name != want {
+				t.Errorf("want name %q, got %q", want, span.Name)
+			}
+			if span.SpanKind != trace.SpanKindServer {
+				t.Errorf("want server span, got %v", span.SpanKind)
+			}
+			if span.Status.Code != test.wantStatus {
+				t.Errorf("want status %v, got %v", test.wantStatus, span.Status.Code)
+			}
+
+			attrs := attribute.NewSet(span.Attributes...)
+			// hcontext appends the incoming request ID to a generated one
+			if v, _ := attrs.Value("request_id"); !strings.HasSuffix(v.AsString(), ",abc") {
+				t.Errorf("want request_id ending in abc, got %q", v.AsString())
+			}
+			if v, _ := attrs.Value("http.route"); v.AsString() != "/apps/{id}" {
+				t.Errorf("want route /apps/{id}, got %q", v.AsString())
+			}
+			if v, _ := attrs.Value("http.response.status_code"); v.AsInt64() != int64(test.status) {
+				t.Errorf("want status code %d, got %d", test.status, v.AsInt64())
+			}
+		})
+	}
+}
+
+func TestTracingPropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	propagator := propagation.TraceContext{}
+	_, parent := tp.Tracer("test").Start(t.Context(), "parent")
+	parent.End()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	propagator.Inject(trace.ContextWithSpan(t.Context(), parent), propagation.HeaderCarrier(req.Header))
+
+	h := Tracing(tp)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	prev := otelapi.GetTextMapPropagator()
+	otelapi.SetTextMapPropagator(propagator)
+	t.Cleanup(func() { otelapi.SetTextMapPropagator(prev) })
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got %d", len(spans))
+	}
+	if got, want := spans[1].Parent.SpanID(), parent.SpanContext().SpanID(); got != want {
+		t.Fatalf("want parent %v, got %v", want, got)
+	}
+	if got, want := spans[1].SpanContext.TraceID(), parent.SpanContext().TraceID(); got != want {
+		t.Fatalf("want trace %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,315 @@
+// Package logplex provides clients and handlers speaking the logplex drain
+// protocol: batches of octet-framed syslog messages POSTed over HTTP(S).
+package logplex
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/go-kit/metrics/provider/discard"
+	"github.com/heroku/x/logplex/encoding"
+)
+
+const (
+	// ContentType of logplex drain request bodies.
+	ContentType = "application/logplex-1"
+
+	// MsgCountHeader holds the number of messages in a request body.
+	MsgCountHeader = "Logplex-Msg-Count"
+
+	// FrameIDHeader holds a unique id for a request body. It is kept across
+	// retries so that receivers can detect duplicate deliveries.
+	FrameIDHeader = "Logplex-Frame-Id"
+
+	// DrainTokenHeader holds the token of the drain messages are sent to.
+	DrainTokenHeader = "Logplex-Drain-Token"
+
+	// DefaultBatchSize is the default maximum number of messages per request.
+	DefaultBatchSize = 500
+
+	// DefaultBufferSize is the default maximum number of messages buffered
+	// awaiting delivery.
+	DefaultBufferSize = 10000
+
+	// DefaultFlushInterval is the default interval at which partial batches
+	// are delivered.
+	DefaultFlushInterval = time.Second
+
+	// DefaultMaxAttempts is the default number of delivery attempts per batch.
+	DefaultMaxAttempts = 5
+
+	// DefaultMinBackoff is the default delay before retrying a delivery.
+	DefaultMinBackoff = 100 * time.Millisecond
+
+	// DefaultMaxBackoff is the default maximum delay between delivery attempts.
+	DefaultMaxBackoff = 10 * time.Second
+
+	// maxDrainedBody is the size of the largest response bodies drained to
+	// reuse connections.
+	maxDrainedBody = 64 * 1024
+)
+
+// ErrBufferFull is returned by Send when a message is dropped because the
+// buffer is full.
+var ErrBufferFull = errors.New("logplex: buffer full")
+
+// ClientConfig configures a Client. Only URL is required.
+type ClientConfig struct {
+	// URL of the drain. Credentials in the URL are sent using basic auth.
+	URL *url.URL
+
+	// DrainToken, if set, is sent in the Logplex-Drain-Token header.
+	DrainToken string
+
+	// HTTPClient used for deliveries. Defaults to a client with a 20 second
+	// timeout.
+	HTTPClient *http.Client
+
+	// Batching, buffering and retry settings. Zero values are replaced by
+	// the corresponding Default* constant. MaxBackoff is raised to
+	// MinBackoff if below it.
+	BatchSize     int
+	BufferSize    int
+	FlushInterval time.Duration
+	MaxAttempts   int
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+
+	// MetricsProvider receives delivery metrics, prefixed with
+	// "logplex.client.". Defaults to discarding them.
+	MetricsProvider xmetrics.Provider
+}
+
+// Client batches messages into logplex frames and delivers them to a drain.
+// A Client is a cmdutil.Server: Run delivers messages until Stop is called.
+type Client struct {
+	cfg ClientConfig
+
+	frames chan []byte
+	done   chan struct{}
+	once   sync.Once
+
+	sent       metrics.Counter
+	dropped    metrics.Counter
+	failed     metrics.Counter
+	requests   metrics.Counter
+	retries    metrics.Counter
+	duration   metrics.Histogram
+	bufferSize metrics.Gauge
+}
+
+// NewClient returns a Client delivering to cfg.URL.
+func NewClient(cfg ClientConfig) (*Client, error) {
+	if cfg.URL == nil {
+		return nil, errors.New("logplex: missing URL")
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 20 * time.Second}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	cfg.MaxBackoff = max(cfg.MaxBackoff, cfg.MinBackoff)
+	if cfg.MetricsProvider == nil {
+		cfg.MetricsProvider = discard.New()
+	}
+
+	p := cfg.MetricsProvider
+	return &Client{
+		cfg:        cfg,
+		frames:     make(chan []byte, cfg.BufferSize),
+		done:       make(chan struct{}),
+		sent:       p.NewCounter("logplex.client.messages.sent"),
+		dropped:    p.NewCounter("logplex.client.messages.dropped"),
+		failed:     p.NewCounter("logplex.client.messages.failed"),
+		requests:   p.NewCounter("logplex.client.requests"),
+		retries:    p.NewCounter("logplex.client.retries"),
+		duration:   p.NewExplicitHistogram("logplex.client.request-duration.ms", xmetrics.ThirtySecondDistribution),
+		bufferSize: p.NewGauge("logplex.client.buffer.messages"),
+	}, nil
+}
+
+// Send buffers msg for delivery. It never blocks: ErrBufferFull is returned,
+// and the message dropped, when the buffer is full. Invalid messages are
+// rejected with encoding.ErrInvalidMessage.
+func (c *Client) Send(msg encoding.Message) error {
+	frame, err := encoding.Encode(msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case c.frames <- frame:
+		return nil
+	default:
+		c.dropped.Add(1)
+		return ErrBufferFull
+	}
+}
+
+// Run delivers buffered messages until Stop is called. Messages still
+// buffered when stopping are delivered without retries.
+func (c *Client) Run() error {
+	tick := time.NewTicker(c.cfg.FlushInterval)
+	defer tick.Stop()
+
+	var batch [][]byte
+	for {
+		select {
+		case frame := <-c.frames:
+			batch = append(batch, frame)
+			if len(batch) < c.cfg.BatchSize {
+				continue
+			}
+		case <-tick.C:
+			c.bufferSize.Set(float64(len(c.frames)))
+			if len(batch) == 0 {
+				continue
+			}
+		case <-c.done:
+			c.drain(batch)
+			return nil
+		}
+
+		c.deliver(batch, c.cfg.MaxAttempts)
+		batch = nil
+	}
+}
+
+// Stop Run, which delivers any buffered messages before returning. Pending
+// retries are abandoned.
+func (c *Client) Stop(error) {
+	c.once.Do(func() { close(c.done) })
+}
+
+// drain delivers the last batch and whatever is buffered, making a single
+// attempt each.
+func (c *Client) drain(batch [][]byte) {
+	for {
+		select {
+		case frame := <-c.frames:
+			batch = append(batch, frame)
+			if len(batch) < c.cfg.BatchSize {
+				continue
+			}
+		default:
+		}
+
+		if len(batch) == 0 {
+			return
+		}
+		c.deliver(batch, 1)
+		batch = nil
+	}
+}
+
+// deliver batch, retrying with exponential backoff on retriable errors.
+func (c *Client) deliver(batch [][]byte, attempts int) {
+	body := bytes.Join(batch, nil)
+	frameID := uuid.NewString()
+
+	for attempt := 1; ; attempt++ {
+		retry, err := c.post(body, len(batch), frameID)
+		if err == nil {
+			c.sent.Add(float64(len(batch)))
+			return
+		}
+
+		if !retry || attempt >= attempts || !c.sleep(c.backoff(attempt)) {
+			c.failed.Add(float64(len(batch)))
+			return
+		}
+		c.retries.Add(1)
+	}
+}
+
+// post a request, reporting whether it can be retried on failure.
+func (c *Client) post(body []byte, count int, frameID string) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, c.cfg.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set(MsgCountHeader, strconv.Itoa(count))
+	req.Header.Set(FrameIDHeader, frameID)
+	if c.cfg.DrainToken != "" {
+		req.Header.Set(DrainTokenHeader, c.cfg.DrainToken)
+	}
+	if u := c.cfg.URL.User; u != nil {
+		pass, _ := u.Password()
+		req.SetBasicAuth(u.Username(), pass)
+	}
+
+	c.requests.Add(1)
+	start := time.Now()
+	resp, err := c.cfg.HTTPClient.Do(req)
+	xmetrics.MeasureSince(c.duration, start)
+	if err != nil {
+		return true, err
+	}
+	// drain the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedBody))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("logplex: unexpected response %s", resp.Status)
+	default:
+		return false, fmt.Errorf("logplex: unexpected response %s", resp.Status)
+	}
+}
+
+// backoff returns the delay before the next attempt: an exponentially
+// increasing delay, capped at MaxBackoff, with up to 50% of jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.MaxBackoff
+	if attempt < 32 {
+		if exp := c.cfg.MinBackoff << uint(attempt-1); exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1)) //nolint:gosec
+}
+
+// sleep for d, returning false if the client is stopped first.
+func (c *Client) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-c.done:
+		return false
+	}
+}
@@ -0,0 +1,352 @@
+package logplex
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+	"github.com/heroku/x/logplex/encoding"
+)
+
+type drainRequest struct {
+	header   http.Header
+	user     string
+	pass     string
+	messages []encoding.Message
+}
+
+type testDrain struct {
+	mu       sync.Mutex
+	requests []drainRequest
+	statuses []int // served in order, then 204s
+}
+
+func (d *testDrain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := drainRequest{header: r.Header}
+	req.user, req.pass, _ = r.BasicAuth()
+
+	s := encoding.NewDrainScanner(r.Body)
+	for s.Scan() {
+		req.messages = append(req.messages, s.Message())
+	}
+	if err := s.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, req)
+
+	status := http.StatusNoContent
+	if len(d.statuses) > 0 {
+		status, d.statuses = d.statuses[0], d.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (d *testDrain) received() []drainRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]drainRequest(nil), d.requests...)
+}
+
+func newTestClient(t *testing.T, d *testDrain, cfg ClientConfig) (*Client, *testmetrics.Provider) {
+	t.Helper()
+
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.User = url.UserPassword("user", "secret")
+
+	p := testmetrics.NewProvider(t)
+	cfg.URL = u
+	cfg.MetricsProvider = p
+	cfg.MinBackoff = time.Millisecond
+	cfg.MaxBackoff = 2 * time.Millisecond
+
+	c, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, p
+}
+
+func testMessage(i int) encoding.Message {
+	return encoding.Message{
+		Timestamp:   time.Now(),
+		Hostname:    "host",
+		Application: "app",
+		Process:     "web.1",
+		Message:     "message " + strconv.Itoa(i),
+		Version:     1,
+		Priority:    190,
+	}
+}
+
+func TestClientBatches(t *testing.T) {
+	d := &testDrain{}
+	c, p := newTestClient(t, d, ClientConfig{BatchSize: 2, FlushInterval: time.Hour, DrainToken: "d.123"})
+
+	for i := 0; i < 5; i++ {
+		if err := c.Send(testMessage(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Stop before Run: everything buffered is delivered before returning.
+	c.Stop(nil)
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := d.received()
+	if len(reqs) != 3 {
+		t.Fatalf("want 3 requests, got %d", len(reqs))
+	}
+
+	seen := 0
+	frameIDs := map[string]bool{}
+	for _, req := range reqs {
+		if got := req.header.Get("Content-Type"); got != ContentType {
+			t.Errorf("want content type %q, got %q", ContentType, got)
+		}
+		if got := req.header.Get(DrainTokenHeader); got != "d.123" {
+			t.Errorf("want drain token %q, got %q", "d.123", got)
+		}
+		if req.user != "user" || req.pass != "secret" {
+			t.Errorf("want basic auth user:secret, got %s:%s", req.user, req.pass)
+		}
+		if got, want := req.header.Get(MsgCountHeader), strconv.Itoa(len(req.messages)); got != want {
+			t.Errorf("want msg count %s, got %s", want, got)
+		}
+		frameIDs[req.header.Get(FrameIDHeader)] = true
+
+		for _, msg := range req.messages {
+			if want := "message " + strconv.Itoa(seen); msg.Message != want {
+				t.Errorf("want message %q, got %q", want, msg.Message)
+			}
+			seen++
+		}
+	}
+
+	if len(frameIDs) != 3 {
+		t.Errorf("want 3 unique frame ids, got %v", frameIDs)
+	}
+
+	p.CheckCounter("logplex.client.messages.sent", 5)
+	p.CheckCounter("logplex.client.requests", 3)
+}
+
+func TestClientFlushInterval(t *testing.T) {
+	d := &testDrain{}
+	c, p := newTestClient(t, d, ClientConfig{FlushInterval: 10 * time.Millisecond})
+
+	done := make(chan error)
+	go func() { done <- c.Run() }()
+
+	if err := c.Send(testMessage(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(d.received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for delivery")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.Stop(nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	p.CheckCounter("logplex.client.messages.sent", 1)
+}
+
+func TestClientRetries(t *testing.T) {
+	d := &testDrain{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	c, p := newTestClient(t, d, ClientConfig{FlushInterval: time.Hour, BatchSize: 1})
+
+	done := make(chan error)
+	go func() { done <- c.Run() }()
+
+	if err := c.Send(testMessage(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(d.received()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for delivery")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.Stop(nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := d.received()
+	if len(reqs) != 3 {
+		t.Fatalf("want 3 requests, got %d", len(reqs))
+	}
+	for _, req := range reqs[1:] {
+		if got, want := req.header.Get(FrameIDHeader), reqs[0].header.Get(FrameIDHeader); got != want {
+			t.Errorf("want retries to keep frame id %s, got %s", want, got)
+		}
+	}
+
+	p.CheckCounter("logplex.client.retries", 2)
+	p.CheckCounter("logplex.client.messages.sent", 1)
+}
+
+func TestClientGivesUp(t *testing.T) {
+	tests := map[string]struct {
+		statuses     []int
+		wantRequests int
+	}{
+		"client error": {
+			statuses:     []int{http.StatusBadRequest},
+			wantRequests: 1,
+		},
+		"max attempts": {
+			statuses:     []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			wantRequests: 3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := &testDrain{statuses: test.statuses}
+			c, p := newTestClient(t, d, ClientConfig{FlushInterval: time.Hour, BatchSize: 1, MaxAttempts: 3})
+
+			done := make(chan error)
+			go func() { done <- c.Run() }()
+
+			if err := c.Send(testMessage(0)); err != nil {
+				t.Fatal(err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for len(d.received()) < test.wantRequests {
+				if time.Now().After(deadline) {
+					t.Fatal("timed out waiting for delivery")
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			c.Stop(nil)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(d.received()); got != test.wantRequests {
+				t.Fatalf("want %d requests, got %d", test.wantRequests, got)
+			}
+			p.CheckCounter("logplex.client.messages.failed", 1)
+			p.CheckCounter("logplex.client.messages.sent", 0)
+		})
+	}
+}
+
+func TestClientDropsWhenFull(t *testing.T) {
+	d := &testDrain{}
+	c, p := newTestClient(t, d, ClientConfig{BufferSize: 2})
+
+	for i := 0; i < 2; i++ {
+		if err := c.Send(testMessage(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Send(testMessage(2)); err != ErrBufferFull {
+		t.Fatalf("want %v, got %v", ErrBufferFull, err)
+	}
+
+	if err := c.Send(encoding.Message{}); err == nil {
+		t.Fatal("want error sending invalid message")
+	}
+
+	p.CheckCounter("logplex.client.messages.dropped", 1)
+}
+
+func TestClientBackoffDefaults(t *testing.T) {
+	u, err := url.Parse("http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		min, max         time.Duration
+		wantMin, wantMax time.Duration
+	}{
+		"defaults":          {wantMin: DefaultMinBackoff, wantMax: DefaultMaxBackoff},
+		"max below min":     {min: time.Second, max: time.Millisecond, wantMin: time.Second, wantMax: time.Second},
+		"min above default": {min: 20 * time.Second, wantMin: 20 * time.Second, wantMax: 20 * time.Second},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(ClientConfig{URL: u, MinBackoff: test.min, MaxBackoff: test.max})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.cfg.MinBackoff != test.wantMin || c.cfg.MaxBackoff != test.wantMax {
+				t.Fatalf("want backoff %v-%v, got %v-%v", test.wantMin, test.wantMax, c.cfg.MinBackoff, c.cfg.MaxBackoff)
+			}
+		})
+	}
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = io.WriteString(w, strings.Repeat("ok", 1024))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(ClientConfig{URL: u, BatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := c.Send(testMessage(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Stop(nil)
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := conns.Load(); got != 1 {
+		t.Fatalf("want 1 connection, got %d", got)
+	}
+}
@@ -1,7 +1,6 @@
 package encoding
 
 import (
-	"fmt"
 	"io"
 	"strconv"
 
@@ -51,45 +50,6 @@ func (p *plainEncoder) KeepAlive() error {
 	return err
 }
 
-// sseEncoder wraps an io.Writer and provides convenience methods for SSE
-type sseEncoder struct {
-	w io.Writer
-}
-
-// NewSSE instantiates a new SSE encoder
-func NewSSE(w io.Writer) Encoder {
-	return &sseEncoder{w}
-}
-
-// KeepAlive sends a blank comment.
-func (s *sseEncoder) KeepAlive() error {
-	_, err := fmt.Fprintf(s.w, ": \n")
-	return err
-}
-
-// Encode assembles the message according to the SSE spec and writes it out
-func (s *sseEncoder) Encode(msg Message) error {
-	// Use time as the base for creating an ID, since we need monotonic numbers that we can potentially do offsets from
-	s.id(msg.Timestamp.Unix())
-	s.data(msg)
-	s.separator()
-	return nil
-}
-
-func (s *sseEncoder) id(id int64) {
-	fmt.Fprintf(s.w, "id: %v\n", id)
-}
-
-func (s *sseEncoder) data(msg Message) {
-	fmt.Fprint(s.w, "data: ")
-	fmt.Fprint(s.w, messageToString(msg))
-	fmt.Fprint(s.w, "\n")
-}
-
-func (s *sseEncoder) separator() {
-	fmt.Fprint(s.w, "\n\n")
-}
-
 func messageToString(msg Message) string {
 	return msg.Timestamp.Format(HumanTimeFormat) + " " + msg.Application + "[" + msg.Process + "]: " + msg.Message
 }
@@ -97,25 +57,46 @@ func messageToString(msg Message) string {
 // Encode serializes a syslog message into their wire format ( octet-framed syslog )
 // Disabling RFC 5424 compliance is the default and needed due to https://github.com/heroku/logplex/issues/204
 func Encode(msg Message) ([]byte, error) {
-	sd := ""
-	if msg.RFCCompliant {
-		sd = "- "
+	line, err := AppendLine(nil, msg)
+	if err != nil {
+		return nil, err
 	}
 
+	b := make([]byte, 0, len(line)+8)
+	b = strconv.AppendInt(b, int64(len(line)), 10)
+	b = append(b, ' ')
+	return append(b, line...), nil
+}
+
+// AppendLine appends the syslog line of msg to dst, like Encode but without
+// the octet count framing it, and returns the extended buffer.
+func AppendLine(dst []byte, msg Message) ([]byte, error) {
+	n := len(dst)
 	if msg.Version == 0 {
-		return nil, errors.Wrap(ErrInvalidMessage, "version")
+		return dst, errors.Wrap(ErrInvalidMessage, "version")
 	}
 
-	line := "<" + strconv.Itoa(int(msg.Priority)) + ">" + strconv.Itoa(int(msg.Version)) + " " +
-		msg.Timestamp.Format(SyslogTimeFormat) + " " +
-		stringOrNil(msg.Hostname) + " " +
-		stringOrNil(msg.Application) + " " +
-		stringOrNil(msg.Process) + " " +
-		stringOrNil(msg.ID) + " " +
-		sd +
-		msg.Message
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(msg.Priority), 10)
+	dst = append(dst, '>')
+	dst = strconv.AppendInt(dst, int64(msg.Version), 10)
+	dst = append(dst, ' ')
+	dst = msg.Timestamp.AppendFormat(dst, SyslogTimeFormat)
+	for _, s := range []string{msg.Hostname, msg.Application, msg.Process, msg.ID} {
+		dst = append(dst, ' ')
+		dst = append(dst, stringOrNil(s)...)
+	}
+	dst = append(dst, ' ')
+
+	if msg.RFCCompliant || msg.StructuredData != nil {
+		if err := msg.StructuredData.validate(); err != nil {
+			return dst[:n], err
+		}
+		dst = append(dst, msg.StructuredData.String()...)
+		dst = append(dst, ' ')
+	}
 
-	return []byte(strconv.Itoa(len(line)) + " " + line), nil
+	return append(dst, msg.Message...), nil
 }
 
 func stringOrNil(s string) string {
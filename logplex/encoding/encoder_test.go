@@ -77,6 +77,38 @@ func TestEncode(t *testing.T) {
 	}
 }
 
+func TestAppendLine(t *testing.T) {
+	lockedDate, _ := time.Parse("2006-01-02T15:04:05.000Z", "2019-01-12T11:45:26.371Z")
+	msg := Message{
+		Version:     1,
+		Priority:    134,
+		Hostname:    "hostname",
+		Application: "application",
+		Process:     "process",
+		ID:          "msgid",
+		Timestamp:   lockedDate,
+		Message:     "hi",
+	}
+
+	line, err := AppendLine([]byte("prefix:"), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "prefix:<134>1 2019-01-12T11:45:26.371+00:00 hostname application process msgid hi"
+	if string(line) != want {
+		t.Fatalf("want %q, got %q", want, line)
+	}
+
+	msg.StructuredData = StructuredData{{ID: "a b"}}
+	line, err = AppendLine([]byte("prefix:"), msg)
+	if errors.Cause(err) != ErrInvalidMessage {
+		t.Fatalf("expected %v, got %v", ErrInvalidMessage, err)
+	}
+	if string(line) != "prefix:" {
+		t.Fatalf("want the buffer unchanged on error, got %q", line)
+	}
+}
+
 func TestEncoderTypes(t *testing.T) {
 	lockedDate, _ := time.Parse("2006-01-02T15:04:05.000Z", "2019-01-12T11:45:26.371Z")
 
@@ -115,7 +147,7 @@ func TestEncoderTypes(t *testing.T) {
 				Timestamp:   lockedDate,
 				Message:     "hi",
 			},
-			wantEncodedMsg: "id: 1547293526\ndata: 2019-01-12T11:45:26.371000+00:00 application[process]: hi\n\n\n",
+			wantEncodedMsg: "id: 1547293526371000000-0\ndata: 2019-01-12T11:45:26.371000+00:00 application[process]: hi\n\n\n",
 		},
 	}
 
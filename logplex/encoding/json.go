@@ -0,0 +1,127 @@
+package encoding
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Special time formats for JSON encoders, encoding timestamps as numbers
+// rather than strings.
+const (
+	// JSONTimeUnix encodes timestamps as seconds since the epoch.
+	JSONTimeUnix = "unix"
+	// JSONTimeUnixMilli encodes timestamps as milliseconds since the epoch.
+	JSONTimeUnixMilli = "unixmilli"
+	// JSONTimeUnixNano encodes timestamps as nanoseconds since the epoch.
+	JSONTimeUnixNano = "unixnano"
+)
+
+// JSONOption configures the JSON encoders.
+type JSONOption func(*jsonFormatter)
+
+// WithTimeFormat sets the layout used to encode timestamps. It's either a
+// time package layout or one of the JSONTime* formats. The default is
+// time.RFC3339Nano.
+func WithTimeFormat(layout string) JSONOption {
+	return func(f *jsonFormatter) {
+		f.timeFormat = layout
+	}
+}
+
+// WithLocation converts timestamps to loc before encoding them.
+func WithLocation(loc *time.Location) JSONOption {
+	return func(f *jsonFormatter) {
+		f.location = loc
+	}
+}
+
+type jsonFormatter struct {
+	timeFormat string
+	location   *time.Location
+}
+
+func newJSONFormatter(opts []JSONOption) *jsonFormatter {
+	f := &jsonFormatter{timeFormat: time.RFC3339Nano}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// jsonMessage is the JSON representation of a Message.
+type jsonMessage struct {
+	Timestamp      interface{}    `json:"timestamp"`
+	Hostname       string         `json:"hostname,omitempty"`
+	Application    string         `json:"application,omitempty"`
+	Process        string         `json:"process,omitempty"`
+	ID             string         `json:"msgid,omitempty"`
+	Priority       uint8          `json:"priority"`
+	Facility       string         `json:"facility"`
+	Severity       string         `json:"severity"`
+	Version        uint16         `json:"version"`
+	StructuredData StructuredData `json:"structured_data,omitempty"`
+	Message        string         `json:"message"`
+}
+
+func (f *jsonFormatter) marshal(msg Message) ([]byte, error) {
+	return json.Marshal(jsonMessage{
+		Timestamp:      f.timestamp(msg.Timestamp),
+		Hostname:       msg.Hostname,
+		Application:    msg.Application,
+		Process:        msg.Process,
+		ID:             msg.ID,
+		Priority:       msg.Priority,
+		Facility:       FacilityName(msg.Facility()),
+		Severity:       SeverityName(msg.Severity()),
+		Version:        msg.Version,
+		StructuredData: msg.StructuredData,
+		Message:        msg.Message,
+	})
+}
+
+func (f *jsonFormatter) timestamp(t time.Time) interface{} {
+	if f.location != nil {
+		t = t.In(f.location)
+	}
+
+	switch f.timeFormat {
+	case JSONTimeUnix:
+		return t.Unix()
+	case JSONTimeUnixMilli:
+		return t.UnixMilli()
+	case JSONTimeUnixNano:
+		return t.UnixNano()
+	default:
+		return t.Format(f.timeFormat)
+	}
+}
+
+type jsonLinesEncoder struct {
+	w io.Writer
+	f *jsonFormatter
+}
+
+// NewJSONLines creates an encoder writing each message as a JSON object on
+// its own line (JSON Lines, also known as NDJSON). All message fields are
+// written, along with the facility and severity decoded from the priority.
+func NewJSONLines(w io.Writer, opts ...JSONOption) Encoder {
+	return &jsonLinesEncoder{w: w, f: newJSONFormatter(opts)}
+}
+
+// Encode writes the message as a single line of JSON.
+func (j *jsonLinesEncoder) Encode(msg Message) error {
+	b, err := j.f.marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = j.w.Write(append(b, '\n'))
+	return err
+}
+
+// KeepAlive sends an empty line, which NDJSON parsers ignore.
+func (j *jsonLinesEncoder) KeepAlive() error {
+	_, err := j.w.Write([]byte{'\n'})
+	return err
+}
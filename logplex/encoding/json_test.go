@@ -0,0 +1,98 @@
+package encoding
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestJSONEncoders(t *testing.T) {
+	lockedDate, _ := time.Parse("2006-01-02T15:04:05.000Z", "2019-01-12T11:45:26.371Z")
+
+	msg := Message{
+		Version:     1,
+		Priority:    134,
+		Hostname:    "hostname",
+		Application: "application",
+		Process:     "process",
+		ID:          "msgid",
+		Timestamp:   lockedDate,
+		Message:     "hi \"there\"",
+	}
+
+	sdMsg := msg
+	sdMsg.StructuredData = StructuredData{{ID: "origin@123", Params: []SDParam{{Name: "ip", Value: "1.2.3.4"}}}}
+
+	tests := []struct {
+		name           string
+		newEncoder     func(*bytes.Buffer) Encoder
+		msg            Message
+		wantEncodedMsg string
+	}{
+		{
+			name:       "json lines",
+			newEncoder: func(w *bytes.Buffer) Encoder { return NewJSONLines(w) },
+			msg:        msg,
+			wantEncodedMsg: `{"timestamp":"2019-01-12T11:45:26.371Z","hostname":"hostname","application":"application",` +
+				`"process":"process","msgid":"msgid","priority":134,"facility":"local0","severity":"info","version":1,` +
+				`"message":"hi \"there\""}` + "\n",
+		},
+		{
+			name:       "json lines with structured data",
+			newEncoder: func(w *bytes.Buffer) Encoder { return NewJSONLines(w) },
+			msg:        sdMsg,
+			wantEncodedMsg: `{"timestamp":"2019-01-12T11:45:26.371Z","hostname":"hostname","application":"application",` +
+				`"process":"process","msgid":"msgid","priority":134,"facility":"local0","severity":"info","version":1,` +
+				`"structured_data":[{"id":"origin@123","params":[{"name":"ip","value":"1.2.3.4"}]}],"message":"hi \"there\""}` + "\n",
+		},
+		{
+			name: "json lines with custom time format",
+			newEncoder: func(w *bytes.Buffer) Encoder {
+				return NewJSONLines(w, WithTimeFormat(HumanTimeFormat), WithLocation(time.FixedZone("", -3600)))
+			},
+			msg: Message{Timestamp: lockedDate, Priority: 190},
+			wantEncodedMsg: `{"timestamp":"2019-01-12T10:45:26.371000-01:00","priority":190,"facility":"local7",` +
+				`"severity":"info","version":0,"message":""}` + "\n",
+		},
+		{
+			name:       "json lines with unix millis",
+			newEncoder: func(w *bytes.Buffer) Encoder { return NewJSONLines(w, WithTimeFormat(JSONTimeUnixMilli)) },
+			msg:        Message{Timestamp: lockedDate, Priority: 11},
+			wantEncodedMsg: `{"timestamp":1547293526371,"priority":11,"facility":"user","severity":"err",` +
+				`"version":0,"message":""}` + "\n",
+		},
+		{
+			name:       "json sse",
+			newEncoder: func(w *bytes.Buffer) Encoder { return NewJSONSSE(w, WithTimeFormat(JSONTimeUnix)) },
+			msg:        msg,
+			wantEncodedMsg: "id: 1547293526371000000-0\n" +
+				`data: {"timestamp":1547293526,"hostname":"hostname","application":"application",` +
+				`"process":"process","msgid":"msgid","priority":134,"facility":"local0","severity":"info","version":1,` +
+				`"message":"hi \"there\""}` + "\n\n\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			if err := test.newEncoder(w).Encode(test.msg); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.wantEncodedMsg != w.String() {
+				t.Fatalf("want message: %v, got: %v", test.wantEncodedMsg, w.String())
+			}
+		})
+	}
+}
+
+func TestJSONLinesKeepAlive(t *testing.T) {
+	w := &bytes.Buffer{}
+	if err := NewJSONLines(w).KeepAlive(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := w.String(); got != "\n" {
+		t.Fatalf("want keepalive %q, got %q", "\n", got)
+	}
+}
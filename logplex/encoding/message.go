@@ -2,20 +2,62 @@ package encoding
 
 import (
 	"io"
+	"strconv"
 	"time"
 )
 
 // Message is a syslog message
 type Message struct {
-	Timestamp    time.Time
-	Hostname     string
-	Application  string
-	Process      string
-	ID           string
-	Message      string
-	Version      uint16
-	Priority     uint8
-	RFCCompliant bool
+	Timestamp   time.Time
+	Hostname    string
+	Application string
+	Process     string
+	ID          string
+	Message     string
+	// StructuredData is encoded when it is non-nil, or as the NILVALUE ('-')
+	// when RFCCompliant is set.
+	StructuredData StructuredData
+	Version        uint16
+	Priority       uint8
+	RFCCompliant   bool
+}
+
+// Facility returns the syslog facility encoded in Priority.
+func (m Message) Facility() uint8 {
+	return m.Priority / 8
+}
+
+// Severity returns the syslog severity encoded in Priority.
+func (m Message) Severity() uint8 {
+	return m.Priority % 8
+}
+
+var facilityNames = [...]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityNames = [...]string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// FacilityName returns the conventional keyword for a syslog facility, e.g.
+// "local7", or its number when it has none.
+func FacilityName(facility uint8) string {
+	if int(facility) < len(facilityNames) {
+		return facilityNames[facility]
+	}
+	return strconv.Itoa(int(facility))
+}
+
+// SeverityName returns the conventional keyword for a syslog severity, e.g.
+// "info".
+func SeverityName(severity uint8) string {
+	if int(severity) < len(severityNames) {
+		return severityNames[severity]
+	}
+	return strconv.Itoa(int(severity))
 }
 
 // Size returns the message size in bytes, including the octet framing header
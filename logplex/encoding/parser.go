@@ -57,3 +57,48 @@ func TruncatingSyslogSplitFunc(maxLength int) bufio.SplitFunc {
 		return
 	}
 }
+
+// NewlineSplitFunc splits the data on LF, as used by non-transparent framing
+// (RFC 6587 section 3.4.2). A trailing CR is dropped and empty lines are
+// skipped.
+func NewlineSplitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for advance < len(data) {
+		i := bytes.IndexByte(data[advance:], '\n')
+		if i == -1 {
+			break
+		}
+
+		line := bytes.TrimSuffix(data[advance:advance+i], []byte{'\r'})
+		advance += i + 1
+		if len(line) > 0 {
+			return advance, line, nil
+		}
+	}
+
+	if atEOF && advance < len(data) {
+		if line := bytes.TrimSuffix(data[advance:], []byte{'\r'}); len(line) > 0 {
+			return len(data), line, nil
+		}
+		return len(data), nil, nil
+	}
+
+	// request more data, discarding any empty lines seen so far
+	return advance, nil, nil
+}
+
+// AutoSplitFunc detects the framing of each frame: frames starting with a
+// frame length are split with SyslogSplitFunc, anything else (e.g. a line
+// starting with '<PRI>') with NewlineSplitFunc. This allows accepting both
+// octet-counted and LF-delimited syslog on the same stream.
+func AutoSplitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	// RFC 6587: octet counting frames start with a NONZERO-DIGIT
+	if data[0] >= '1' && data[0] <= '9' {
+		return SyslogSplitFunc(data, atEOF)
+	}
+
+	return NewlineSplitFunc(data, atEOF)
+}
@@ -0,0 +1,196 @@
+package encoding
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// RawMessage is a syslog message whose fields reference the buffer it was
+// decoded from. When returned by a RawScanner, its fields are only valid
+// until the next call to Scan.
+type RawMessage struct {
+	// Timestamp is left unparsed; use Time to parse it.
+	Timestamp   []byte
+	Hostname    []byte
+	Application []byte
+	Process     []byte
+	ID          []byte
+	// StructuredData is the still escaped STRUCTURED-DATA field, or nil
+	// when the message was decoded without structured data.
+	StructuredData []byte
+	Message        []byte
+	Version        uint16
+	Priority       uint8
+}
+
+// Time parses the message timestamp.
+func (m *RawMessage) Time() (time.Time, error) {
+	return time.Parse(FlexibleSyslogTimeFormat, string(m.Timestamp))
+}
+
+// ToMessage copies the raw message into a Message, parsing its timestamp and
+// structured data.
+func (m *RawMessage) ToMessage() (Message, error) {
+	msg := Message{
+		Hostname:    string(m.Hostname),
+		Application: string(m.Application),
+		Process:     string(m.Process),
+		ID:          string(m.ID),
+		Message:     string(m.Message),
+		Version:     m.Version,
+		Priority:    m.Priority,
+	}
+
+	var err error
+	if msg.Timestamp, err = m.Time(); err != nil {
+		return msg, err
+	}
+
+	if m.StructuredData != nil {
+		if msg.StructuredData, err = decodeStructuredData(m.StructuredData); err != nil {
+			return msg, err
+		}
+	}
+
+	return msg, nil
+}
+
+// DecodeRaw decodes a rfc5424 message into m without allocating. The fields
+// of m alias raw.
+func DecodeRaw(raw []byte, hasStructuredData bool, m *RawMessage) error {
+	*m = RawMessage{}
+
+	priVal, raw, err := rawSyslogField(raw)
+	if err != nil {
+		return err
+	}
+	if m.Priority, m.Version, err = parsePriVal(priVal); err != nil {
+		return err
+	}
+
+	if m.Timestamp, raw, err = rawSyslogField(raw); err != nil {
+		return err
+	}
+	if m.Hostname, raw, err = rawSyslogField(raw); err != nil {
+		return err
+	}
+	if m.Application, raw, err = rawSyslogField(raw); err != nil {
+		return err
+	}
+	if m.Process, raw, err = rawSyslogField(raw); err != nil {
+		return err
+	}
+	if m.ID, raw, err = rawSyslogField(raw); err != nil {
+		return err
+	}
+
+	if hasStructuredData {
+		sd, n, err := scanStructuredData(raw)
+		if err != nil {
+			return err
+		}
+		m.StructuredData = sd
+		raw = raw[n:]
+	}
+
+	m.Message = raw
+	return nil
+}
+
+// parsePriVal parses a '<PRI>VERSION' header field.
+func parsePriVal(b []byte) (uint8, uint16, error) {
+	if len(b) < 4 || b[0] != '<' {
+		return 0, 0, ErrInvalidPriVal
+	}
+
+	gt := bytes.IndexByte(b, '>')
+	if gt < 2 || gt == len(b)-1 {
+		return 0, 0, ErrInvalidPriVal
+	}
+
+	pri, ok := parseUint(b[1:gt], 1<<8-1)
+	if !ok {
+		return 0, 0, ErrInvalidPriVal
+	}
+
+	version, ok := parseUint(b[gt+1:], 1<<16-1)
+	if !ok {
+		return 0, 0, ErrInvalidPriVal
+	}
+
+	return uint8(pri), uint16(version), nil
+}
+
+func parseUint(b []byte, limit int) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+		if n > limit {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+func rawSyslogField(b []byte) (field []byte, rest []byte, err error) {
+	sp := bytes.IndexByte(b, ' ')
+	if sp == -1 {
+		return nil, nil, io.EOF
+	}
+	return b[:sp], b[sp+1:], nil
+}
+
+// RawScanner is a syslog octet frame stream parser that reuses its buffers
+// instead of allocating a Message per frame. It's meant for high volume
+// ingesters that only need to look at, or copy, parts of each message.
+type RawScanner struct {
+	s    *syslogScanner
+	item RawMessage
+	err  error
+}
+
+// NewRawScanner returns a RawScanner. It accepts the same options as
+// NewScanner, but only decodes RFC5424 messages: if another format is
+// selected with WithFormat or WithAutoFraming, Scan returns false and Err
+// returns ErrUnsupportedFormat.
+func NewRawScanner(r io.Reader, opts ...ScannerOption) *RawScanner {
+	s := &RawScanner{s: newSyslogScanner(r, opts...)}
+	if s.s.format != FormatRFC5424 {
+		s.err = ErrUnsupportedFormat
+	}
+	return s
+}
+
+// Scan returns true until all messages are parsed or an error occurs.
+// When an error occur, the underlying error will be presented as `Err()`
+func (s *RawScanner) Scan() bool {
+	if s.err == ErrUnsupportedFormat || !s.s.parser.Scan() {
+		return false
+	}
+
+	s.err = DecodeRaw(s.s.parser.Bytes(), s.s.rfcCompliant, &s.item)
+	return s.err == nil
+}
+
+// Err returns the last scanner error
+func (s *RawScanner) Err() error {
+	if err := s.s.parser.Err(); err != nil {
+		return err
+	}
+
+	return s.err
+}
+
+// Message returns the current message. Its fields are only valid until the
+// next call to Scan; copy them, or use ToMessage, to retain them.
+func (s *RawScanner) Message() *RawMessage {
+	return &s.item
+}
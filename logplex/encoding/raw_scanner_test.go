@@ -0,0 +1,185 @@
+package encoding
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRawScanner(t *testing.T) {
+	tests := map[string]struct {
+		log   string
+		opts  []ScannerOption
+		count int
+		err   error
+	}{
+		"multiple": {
+			log:   "64 <190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 99\n65 <190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 100\n",
+			count: 2,
+		},
+		"structured data": {
+			log:   "131 <133>1 2013-05-24T06:48:51.740194+00:00 host token process - [meta sequenceId=\"518\"][meta somethingElse=\"blah\"] this is the message",
+			count: 1,
+		},
+		"non-compliant": {
+			log:   "64 <190>1 2019-07-21T22:13:34.598992Z shuttle t.http shuttle - 168\n",
+			opts:  []ScannerOption{RFCCompliant(false)},
+			count: 1,
+		},
+		"bad prefix structured data": {
+			log: "131 <133>1 2013-05-24T06:48:51.740194+00:00 host token process - |meta sequenceId=\"518\"][meta somethingElse=\"blah\"] this is the message",
+			err: ErrInvalidStructuredData,
+		},
+		"bad prival": {
+			log: "64 <190>   2019-07-20T17:50:10.879238Z shuttle token shuttle - - 99\n 65 <190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 10",
+			err: ErrInvalidPriVal,
+		},
+		"short read": {
+			log:   "64 <190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 99\n10 ---",
+			count: 1,
+			err:   ErrBadFrame,
+		},
+		"too long": {
+			log:  "66 <190>1 2019-07-21T22:13:34.598992Z shuttle t.http shuttle - - 168\n",
+			opts: []ScannerOption{WithBuffer(32, 64)},
+			err:  bufio.ErrTooLong,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The raw scanner must agree with the regular one.
+			want := NewScanner(strings.NewReader(test.log), test.opts...)
+			s := NewRawScanner(strings.NewReader(test.log), test.opts...)
+
+			i := 0
+			for s.Scan() {
+				if !want.Scan() {
+					t.Fatalf("raw scanner found extra message: %v", want.Err())
+				}
+
+				got, err := s.Message().ToMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want.Message()) {
+					t.Fatalf("want %#v, got %#v", want.Message(), got)
+				}
+				i++
+			}
+
+			if got, want := i, test.count; got != want {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+
+			if got, want := errors.Cause(s.Err()), test.err; got != want {
+				t.Errorf("scanner: expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestRawScannerFormats(t *testing.T) {
+	log := "<190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 99\n"
+
+	s := NewRawScanner(strings.NewReader(log), WithSplit(AutoSplitFunc))
+	if !s.Scan() {
+		t.Fatalf("want LF-delimited message scanned, got %v", s.Err())
+	}
+	if got := string(s.Message().Message); got != "99" {
+		t.Fatalf("want message %q, got %q", "99", got)
+	}
+
+	for name, opt := range map[string]ScannerOption{
+		"rfc3164":      WithFormat(FormatRFC3164),
+		"auto":         WithFormat(FormatAuto),
+		"auto framing": WithAutoFraming(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := NewRawScanner(strings.NewReader(log), opt)
+			if s.Scan() {
+				t.Fatal("want no message scanned")
+			}
+			if got := s.Err(); got != ErrUnsupportedFormat {
+				t.Fatalf("expected %v, got %v", ErrUnsupportedFormat, got)
+			}
+		})
+	}
+}
+
+func TestRawScannerAllocs(t *testing.T) {
+	s := NewRawScanner(&repeatReader{data: []byte(benchmarkFrames)})
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		if !s.Scan() {
+			t.Fatal(s.Err())
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("want 0 allocations per message, got %v", allocs)
+	}
+}
+
+const benchmarkFrames = "64 <190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 99\n" +
+	"131 <133>1 2013-05-24T06:48:51.740194+00:00 host token process - [meta sequenceId=\"518\"][meta somethingElse=\"blah\"] this is the message"
+
+// repeatReader endlessly yields data.
+type repeatReader struct {
+	data []byte
+	off  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.data[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.data)
+	}
+	return n, nil
+}
+
+func BenchmarkScanner(b *testing.B) {
+	s := NewScanner(&repeatReader{data: []byte(benchmarkFrames)})
+	var msg Message
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkFrames) / 2))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if !s.Scan() {
+			b.Fatal(s.Err())
+		}
+		msg = s.Message()
+	}
+	_ = msg
+}
+
+func BenchmarkRawScanner(b *testing.B) {
+	s := NewRawScanner(&repeatReader{data: []byte(benchmarkFrames)})
+	var msg *RawMessage
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkFrames) / 2))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if !s.Scan() {
+			b.Fatal(s.Err())
+		}
+		msg = s.Message()
+	}
+	_ = msg
+}
+
+func BenchmarkDecodeRaw(b *testing.B) {
+	frame := []byte("<190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 99\n")
+	var msg RawMessage
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := DecodeRaw(frame, true, &msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package encoding
+
+import (
+	"bytes"
+	"time"
+)
+
+// rfc3164TimeFormat is the BSD syslog TIMESTAMP, which lacks year and zone.
+const rfc3164TimeFormat = time.Stamp
+
+// DecodeRFC3164 converts a BSD syslog (RFC3164) message to our model, e.g.:
+//
+//	<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed
+//
+// TIMESTAMPs are assumed to be UTC and in the year closest to now. TIMESTAMPs
+// in RFC3339 format, as sent by some syslog daemons, are accepted too. As
+// RFC3164 has no VERSION, Version is set to 1 so that the message can be
+// re-encoded as RFC5424.
+func DecodeRFC3164(raw []byte) (Message, error) {
+	return decodeRFC3164(raw, time.Now().UTC())
+}
+
+func decodeRFC3164(raw []byte, now time.Time) (Message, error) {
+	msg := Message{Version: 1}
+
+	if len(raw) == 0 || raw[0] != '<' {
+		return msg, ErrInvalidPriVal
+	}
+	gt := bytes.IndexByte(raw, '>')
+	if gt < 2 {
+		return msg, ErrInvalidPriVal
+	}
+	pri, ok := parseUint(raw[1:gt], 1<<8-1)
+	if !ok {
+		return msg, ErrInvalidPriVal
+	}
+	msg.Priority = uint8(pri)
+	raw = raw[gt+1:]
+
+	var err error
+	if msg.Timestamp, raw, err = parseRFC3164Time(raw, now); err != nil {
+		return msg, err
+	}
+
+	hostname, raw, err := rawSyslogField(raw)
+	if err != nil {
+		return msg, err
+	}
+	msg.Hostname = string(hostname)
+
+	// TAG is terminated by the first non-alphanumeric character, usually
+	// '[' (followed by the pid) or ':'.
+	end := bytes.IndexAny(raw, "[: ")
+	if end == -1 {
+		// no TAG, everything is CONTENT
+		msg.Message = string(raw)
+		return msg, nil
+	}
+	msg.Application = string(raw[:end])
+	raw = raw[end:]
+
+	if raw[0] == '[' {
+		if rb := bytes.IndexByte(raw, ']'); rb != -1 {
+			msg.Process = string(raw[1:rb])
+			raw = raw[rb+1:]
+		}
+	}
+
+	raw = bytes.TrimPrefix(raw, []byte{':'})
+	raw = bytes.TrimPrefix(raw, []byte{' '})
+	msg.Message = string(raw)
+
+	return msg, nil
+}
+
+// parseRFC3164Time parses the TIMESTAMP at the start of raw, returning the
+// remainder following the space after it.
+func parseRFC3164Time(raw []byte, now time.Time) (time.Time, []byte, error) {
+	if len(raw) > len(rfc3164TimeFormat) && raw[len(rfc3164TimeFormat)] == ' ' {
+		t, err := time.Parse(rfc3164TimeFormat, string(raw[:len(rfc3164TimeFormat)]))
+		if err == nil {
+			return inferYear(t, now), raw[len(rfc3164TimeFormat)+1:], nil
+		}
+	}
+
+	field, rest, err := rawSyslogField(raw)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	t, err := time.Parse(FlexibleSyslogTimeFormat, string(field))
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	return t, rest, nil
+}
+
+// inferYear places t, which has no year, in the year that brings it closest
+// to now. This handles messages sent just before midnight on Dec 31st being
+// received after it, and the opposite for slightly skewed clocks.
+func inferYear(t, now time.Time) time.Time {
+	inYear := func(year int) time.Time {
+		return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	}
+
+	best := inYear(now.Year())
+	for _, year := range []int{now.Year() - 1, now.Year() + 1} {
+		candidate := inYear(year)
+		if absDuration(candidate.Sub(now)) < absDuration(best.Sub(now)) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// isRFC5424 reports whether raw looks like a RFC5424 message, i.e. its PRI
+// is followed by a VERSION and a space rather than a RFC3164 TIMESTAMP.
+func isRFC5424(raw []byte) bool {
+	gt := bytes.IndexByte(raw, '>')
+	if gt == -1 {
+		return false
+	}
+
+	sp := bytes.IndexByte(raw[gt+1:], ' ')
+	if sp < 1 || sp > 3 {
+		return false
+	}
+
+	_, ok := parseUint(raw[gt+1:gt+1+sp], 1<<16-1)
+	return ok
+}
@@ -0,0 +1,106 @@
+package encoding
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecodeRFC3164(t *testing.T) {
+	now := time.Date(2019, time.October, 12, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		raw  string
+		now  time.Time
+		want Message
+		err  error
+	}{
+		"rfc example": {
+			raw: "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+			want: Message{
+				Timestamp:   time.Date(2019, time.October, 11, 22, 14, 15, 0, time.UTC),
+				Hostname:    "mymachine",
+				Application: "su",
+				Message:     "'su root' failed for lonvick on /dev/pts/8",
+				Priority:    34,
+			},
+		},
+		"pid": {
+			raw: "<13>Oct  1 02:03:04 host sshd[4242]: Accepted publickey",
+			want: Message{
+				Timestamp:   time.Date(2019, time.October, 1, 2, 3, 4, 0, time.UTC),
+				Hostname:    "host",
+				Application: "sshd",
+				Process:     "4242",
+				Message:     "Accepted publickey",
+				Priority:    13,
+			},
+		},
+		"no tag": {
+			raw: "<13>Oct  1 02:03:04 host hello",
+			want: Message{
+				Timestamp: time.Date(2019, time.October, 1, 2, 3, 4, 0, time.UTC),
+				Hostname:  "host",
+				Message:   "hello",
+				Priority:  13,
+			},
+		},
+		"previous year": {
+			raw: "<13>Dec 31 23:59:59 host app: hi",
+			now: time.Date(2020, time.January, 1, 0, 0, 1, 0, time.UTC),
+			want: Message{
+				Timestamp:   time.Date(2019, time.December, 31, 23, 59, 59, 0, time.UTC),
+				Hostname:    "host",
+				Application: "app",
+				Message:     "hi",
+				Priority:    13,
+			},
+		},
+		"rfc3339 timestamp": {
+			raw: "<13>2019-10-11T22:14:15.003Z host app: hi",
+			want: Message{
+				Timestamp:   time.Date(2019, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+				Hostname:    "host",
+				Application: "app",
+				Message:     "hi",
+				Priority:    13,
+			},
+		},
+		"missing pri": {
+			raw: "Oct 11 22:14:15 mymachine su: hi",
+			err: ErrInvalidPriVal,
+		},
+		"bad pri": {
+			raw: "<999>Oct 11 22:14:15 mymachine su: hi",
+			err: ErrInvalidPriVal,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			n := test.now
+			if n.IsZero() {
+				n = now
+			}
+
+			got, err := decodeRFC3164([]byte(test.raw), n)
+			if want := test.err; errors.Cause(err) != want {
+				t.Fatalf("expected %v, got %v", want, err)
+			}
+			if test.err != nil {
+				return
+			}
+
+			test.want.Version = 1
+			if !got.Timestamp.Equal(test.want.Timestamp) {
+				t.Fatalf("want timestamp %v, got %v", test.want.Timestamp, got.Timestamp)
+			}
+			got.Timestamp = test.want.Timestamp
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("want %#v, got %#v", test.want, got)
+			}
+		})
+	}
+}
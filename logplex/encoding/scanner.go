@@ -25,16 +25,22 @@ var (
 	// ErrBadFrame is returned when the scanner cannot parse syslog message boundaries
 	ErrBadFrame = errors.New("bad frame")
 
-	// ErrInvalidStructuredData is returned when structure data has any value other than '-' (blank)
+	// ErrInvalidStructuredData is returned when structured data is not a valid RFC5424 STRUCTURED-DATA field
 	ErrInvalidStructuredData = errors.New("invalid structured data")
 
 	// ErrInvalidPriVal is returned when pri-val is not properly formatted
 	ErrInvalidPriVal = errors.New("invalid pri-val")
 
+	// ErrUnsupportedFormat is returned by a RawScanner configured to decode
+	// another format than RFC5424
+	ErrUnsupportedFormat = errors.New("unsupported format")
+
 	privalVersionRe = regexp.MustCompile(`<(\d+)>(\d)`)
 )
 
-// Decode converts a rfc5424 message to our model
+// Decode converts a rfc5424 message to our model. When hasStructuredData is
+// set, the STRUCTURED-DATA field is parsed into Message.StructuredData, which
+// is nil for the NILVALUE ('-'), so that it encodes back to the same form.
 func Decode(raw []byte, hasStructuredData bool) (Message, error) {
 	msg := Message{}
 
@@ -91,8 +97,13 @@ func Decode(raw []byte, hasStructuredData bool) (Message, error) {
 	msg.ID = string(id)
 
 	if hasStructuredData {
-		// trash structured data, as we don't use it ever
-		if err = trashStructuredData(b); err != nil {
+		sd, n, err := scanStructuredData(b.Bytes())
+		if err != nil {
+			return msg, err
+		}
+		b.Next(n)
+
+		if msg.StructuredData, err = decodeStructuredData(sd); err != nil {
 			return msg, err
 		}
 	}
@@ -108,6 +119,7 @@ type syslogScanner struct {
 	item         Message
 	err          error
 	rfcCompliant bool
+	format       Format
 }
 
 // Scanner is the general purpose primitive for parsing message bodies coming
@@ -138,8 +150,44 @@ func RFCCompliant(compliant bool) ScannerOption {
 	}
 }
 
+// Format is the syslog message format a Scanner decodes.
+type Format int
+
+const (
+	// FormatRFC5424 decodes RFC5424 messages. It's the default.
+	FormatRFC5424 Format = iota
+	// FormatRFC3164 decodes BSD syslog messages using DecodeRFC3164.
+	FormatRFC3164
+	// FormatAuto detects the format of each message.
+	FormatAuto
+)
+
+// WithFormat selects the message format to decode. The RawScanner only
+// decodes RFC5424 messages, and fails with ErrUnsupportedFormat otherwise.
+func WithFormat(format Format) ScannerOption {
+	return func(s *syslogScanner) {
+		s.format = format
+	}
+}
+
+// WithAutoFraming accepts both octet-counted and LF-delimited frames, using
+// AutoSplitFunc, and detects the format of each message. This is the most
+// lenient configuration, suitable for accepting syslog from arbitrary
+// senders. As it decodes other formats than RFC5424, the RawScanner fails
+// with ErrUnsupportedFormat; use WithSplit(AutoSplitFunc) with it instead.
+func WithAutoFraming() ScannerOption {
+	return func(s *syslogScanner) {
+		s.parser.Split(AutoSplitFunc)
+		s.format = FormatAuto
+	}
+}
+
 // NewScanner is a syslog octet frame stream parser
 func NewScanner(r io.Reader, opts ...ScannerOption) Scanner {
+	return newSyslogScanner(r, opts...)
+}
+
+func newSyslogScanner(r io.Reader, opts ...ScannerOption) *syslogScanner {
 	s := &syslogScanner{
 		parser: bufio.NewScanner(r),
 	}
@@ -178,10 +226,23 @@ func (s *syslogScanner) Scan() bool {
 		return false
 	}
 
-	s.item, s.err = Decode(s.parser.Bytes(), s.rfcCompliant)
+	s.item, s.err = s.decode(s.parser.Bytes())
 	return s.err == nil
 }
 
+func (s *syslogScanner) decode(raw []byte) (Message, error) {
+	switch s.format {
+	case FormatRFC3164:
+		return DecodeRFC3164(raw)
+	case FormatAuto:
+		if !isRFC5424(raw) {
+			return DecodeRFC3164(raw)
+		}
+	}
+
+	return Decode(raw, s.rfcCompliant)
+}
+
 // NewDrainScanner returns a scanner for use with drain endpoints. The primary
 // difference is that it's loose and doesn't check for structured data.
 func NewDrainScanner(r io.Reader, opts ...ScannerOption) Scanner {
@@ -199,63 +260,3 @@ func syslogField(b *bytes.Buffer) ([]byte, error) {
 	}
 	return g, nil
 }
-
-func trashStructuredData(b *bytes.Buffer) error {
-	// notice the quoting
-	// [meta sequenceId=\"518\"][meta somethingElse=\"bl\]ah\"]
-	firstChar, err := b.ReadByte()
-	if err != nil {
-		return err
-	}
-
-	if firstChar == '-' {
-		// trash the following space too
-		_, err = b.ReadByte()
-		return err
-	}
-
-	if firstChar != '[' {
-		return ErrInvalidStructuredData
-	}
-
-	quoting := false
-	bracketing := true
-
-	for {
-		c, err := b.ReadByte()
-		if err != nil {
-			return err
-		}
-
-		if !bracketing {
-			if c == ' ' {
-				// we done!
-				// consumed the last ']' and hit a space
-				break
-			}
-
-			if c != '[' {
-				return ErrInvalidStructuredData
-			}
-
-			bracketing = true
-			continue
-		}
-
-		// makes sure we dont catch '\]' as per RFC
-		// PARAM-VALUE     = UTF-8-STRING ; characters '"', '\' and ']' MUST be escaped.
-		if quoting {
-			quoting = false
-			continue
-		}
-
-		switch c {
-		case '\\':
-			quoting = true
-		case ']':
-			bracketing = false
-		}
-	}
-
-	return nil
-}
@@ -3,6 +3,7 @@ package encoding
 import (
 	"bufio"
 	"bytes"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -234,6 +235,63 @@ func TestScannerCustomOpts(t *testing.T) {
 	}
 }
 
+func TestScannerFraming(t *testing.T) {
+	tests := map[string]struct {
+		log      string
+		opts     []ScannerOption
+		err      error
+		wantMsgs []string
+	}{
+		"newline": {
+			log: "<190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 99\r\n\n" +
+				"<190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 100",
+			opts:     []ScannerOption{WithSplit(NewlineSplitFunc)},
+			wantMsgs: []string{"99", "100"},
+		},
+		"newline rfc3164": {
+			log:      "<34>Oct 11 22:14:15 mymachine su: one\n<34>Oct 11 22:14:16 mymachine su: two\n",
+			opts:     []ScannerOption{WithSplit(NewlineSplitFunc), WithFormat(FormatRFC3164)},
+			wantMsgs: []string{"one", "two"},
+		},
+		"auto": {
+			log: "64 <190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 99\n" +
+				"<190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 100\n" +
+				"<34>Oct 11 22:14:15 mymachine su: legacy\n" +
+				"42 <34>Oct 11 22:14:15 mymachine su: counted\n",
+			opts:     []ScannerOption{WithAutoFraming()},
+			wantMsgs: []string{"99\n", "100", "legacy", "counted\n"},
+		},
+		"auto bad frame": {
+			log:      "<190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 100\n99 <34>Oct",
+			opts:     []ScannerOption{WithAutoFraming()},
+			wantMsgs: []string{"100"},
+			err:      ErrBadFrame,
+		},
+		"octet counted rejects newline framing": {
+			log: "<190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - - 100\n",
+			err: ErrBadFrame,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			scanner := NewScanner(strings.NewReader(test.log), test.opts...)
+			var got []string
+			for scanner.Scan() {
+				got = append(got, scanner.Message().Message)
+			}
+
+			if !reflect.DeepEqual(got, test.wantMsgs) {
+				t.Errorf("want messages %q, got %q", test.wantMsgs, got)
+			}
+
+			if got, want := errors.Cause(scanner.Err()), test.err; got != want {
+				t.Errorf("scanner: expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
 func isCause(cause error, err error) bool {
 	return errors.Cause(err) != cause
 }
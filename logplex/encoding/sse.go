@@ -0,0 +1,212 @@
+package encoding
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidEventID is returned when parsing a malformed SSE event id.
+var ErrInvalidEventID = errors.New("invalid event id")
+
+// EventID is the position of a message within an SSE stream. IDs are
+// assigned from message timestamps, with Seq distinguishing messages sharing
+// the same timestamp, so that they're unique and increasing within a stream.
+type EventID struct {
+	Timestamp time.Time
+	Seq       uint64
+}
+
+// String returns the id in its wire format: <unix nanoseconds>-<seq>.
+func (id EventID) String() string {
+	return strconv.FormatInt(id.Timestamp.UnixNano(), 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// After reports whether id comes after other.
+func (id EventID) After(other EventID) bool {
+	if id.Timestamp.Equal(other.Timestamp) {
+		return id.Seq > other.Seq
+	}
+	return id.Timestamp.After(other.Timestamp)
+}
+
+// ParseEventID parses an id previously written by an SSE encoder, typically
+// received from a reconnecting client in the Last-Event-ID header. Ids
+// written by older versions of the encoder, holding just unix seconds, are
+// accepted too.
+func ParseEventID(s string) (EventID, error) {
+	ts, seq, hasSeq := strings.Cut(s, "-")
+
+	n, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || n < 0 {
+		return EventID{}, errors.Wrapf(ErrInvalidEventID, "%q", s)
+	}
+
+	if !hasSeq {
+		return EventID{Timestamp: time.Unix(n, 0)}, nil
+	}
+
+	id := EventID{Timestamp: time.Unix(0, n)}
+	if id.Seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+		return EventID{}, errors.Wrapf(ErrInvalidEventID, "%q", s)
+	}
+	return id, nil
+}
+
+// SSEOption configures an SSE encoder.
+type SSEOption func(*sseEncoder)
+
+// WithRetry sends the retry field with the first event, setting the delay
+// clients wait before reconnecting.
+func WithRetry(d time.Duration) SSEOption {
+	return func(s *sseEncoder) {
+		s.retry = d
+	}
+}
+
+// WithEventType sets the event field of every message event. Line breaks,
+// which would end the field, are removed from event.
+func WithEventType(event string) SSEOption {
+	return func(s *sseEncoder) {
+		s.event = strings.NewReplacer("\r", "", "\n", "").Replace(event)
+	}
+}
+
+// WithLastEventID resumes a stream after id, typically parsed from the
+// Last-Event-ID header of a reconnecting client. Messages must be replayed
+// in their original order starting at, or before, id.Timestamp; those the
+// client already received are skipped.
+func WithLastEventID(id EventID) SSEOption {
+	return func(s *sseEncoder) {
+		s.resumeAfter = &id
+	}
+}
+
+// WithJSONData encodes the data field of each event as JSON, in the same
+// format as NewJSONLines.
+func WithJSONData(opts ...JSONOption) SSEOption {
+	return func(s *sseEncoder) {
+		s.payload = newJSONFormatter(opts).marshal
+	}
+}
+
+// sseEncoder wraps an io.Writer and provides convenience methods for SSE
+type sseEncoder struct {
+	w io.Writer
+
+	// payload formats the data field; messageToString is used when nil.
+	payload func(Message) ([]byte, error)
+
+	event       string
+	retry       time.Duration
+	sentRetry   bool
+	resumeAfter *EventID
+	last        EventID
+	started     bool
+}
+
+// NewSSE instantiates a new SSE encoder
+func NewSSE(w io.Writer, opts ...SSEOption) Encoder {
+	s := &sseEncoder{w: w}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewJSONSSE instantiates a new SSE encoder whose data fields hold messages
+// encoded as JSON, in the same format as NewJSONLines.
+func NewJSONSSE(w io.Writer, opts ...JSONOption) Encoder {
+	return NewSSE(w, WithJSONData(opts...))
+}
+
+// KeepAlive sends a blank comment.
+func (s *sseEncoder) KeepAlive() error {
+	var buf bytes.Buffer
+	s.retryField(&buf)
+	buf.WriteString(": \n")
+	return s.write(&buf)
+}
+
+// Encode assembles the message according to the SSE spec and writes it out
+func (s *sseEncoder) Encode(msg Message) error {
+	id := s.nextID(msg.Timestamp)
+	if s.resumeAfter != nil && !id.After(*s.resumeAfter) {
+		// already received by the client
+		return nil
+	}
+
+	data, err := s.format(msg)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	s.retryField(&buf)
+	buf.WriteString("id: " + id.String() + "\n")
+	if s.event != "" {
+		buf.WriteString("event: " + s.event + "\n")
+	}
+	writeData(&buf, data)
+	buf.WriteString("\n\n")
+	return s.write(&buf)
+}
+
+// write writes an event assembled in buf at once, so that write errors, e.g.
+// from a disconnected client, are all reported.
+func (s *sseEncoder) write(buf *bytes.Buffer) error {
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if s.retry > 0 {
+		s.sentRetry = true
+	}
+	return nil
+}
+
+// nextID assigns the id of the next message. Messages older than the
+// previous one keep its timestamp so that ids never go backwards.
+func (s *sseEncoder) nextID(ts time.Time) EventID {
+	if !s.started || ts.After(s.last.Timestamp) {
+		s.started = true
+		s.last = EventID{Timestamp: ts}
+	} else {
+		s.last.Seq++
+	}
+	return s.last
+}
+
+func (s *sseEncoder) format(msg Message) ([]byte, error) {
+	if s.payload == nil {
+		return []byte(messageToString(msg)), nil
+	}
+	return s.payload(msg)
+}
+
+// retryField adds the retry field to buf, unless it was already sent.
+func (s *sseEncoder) retryField(buf *bytes.Buffer) {
+	if s.retry <= 0 || s.sentRetry {
+		return
+	}
+	buf.WriteString("retry: " + strconv.FormatInt(s.retry.Milliseconds(), 10) + "\n")
+}
+
+// writeData writes each line of data as its own data field, as required by
+// the SSE spec. A single trailing newline is dropped.
+func writeData(buf *bytes.Buffer, data []byte) {
+	data = bytes.TrimSuffix(data, []byte{'\n'})
+	for {
+		line, rest, more := bytes.Cut(data, []byte{'\n'})
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+		if !more {
+			return
+		}
+		data = rest
+	}
+}
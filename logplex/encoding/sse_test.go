@@ -0,0 +1,153 @@
+package encoding
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestSSEEventIDs(t *testing.T) {
+	base := time.Unix(1547293526, 371000000)
+	msgs := []Message{
+		{Timestamp: base, Message: "a"},
+		{Timestamp: base, Message: "b"},
+		{Timestamp: base.Add(time.Nanosecond), Message: "c"},
+		{Timestamp: base, Message: "late"},
+		{Timestamp: base.Add(time.Second), Message: "d"},
+	}
+
+	w := &bytes.Buffer{}
+	e := NewSSE(w, WithRetry(1500*time.Millisecond), WithEventType("log"))
+	for _, msg := range msgs {
+		if err := e.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := strings.Split(strings.TrimSuffix(w.String(), "\n\n\n"), "\n\n\n")
+	wantIDs := []string{
+		"1547293526371000000-0",
+		"1547293526371000000-1",
+		"1547293526371000001-0",
+		"1547293526371000001-1",
+		"1547293527371000000-0",
+	}
+	if len(events) != len(wantIDs) {
+		t.Fatalf("want %d events, got %d: %q", len(wantIDs), len(events), w.String())
+	}
+
+	if !strings.HasPrefix(events[0], "retry: 1500\n") {
+		t.Errorf("want retry field in first event, got %q", events[0])
+	}
+
+	for i, event := range events {
+		if i > 0 && strings.Contains(event, "retry:") {
+			t.Errorf("want retry field only once, got %q", event)
+		}
+		if !strings.Contains(event, "id: "+wantIDs[i]+"\nevent: log\n") {
+			t.Errorf("want id %s in event %q", wantIDs[i], event)
+		}
+	}
+}
+
+func TestSSEResume(t *testing.T) {
+	base := time.Unix(1547293526, 0)
+	msgs := []Message{
+		{Timestamp: base.Add(-time.Second), Message: "old"},
+		{Timestamp: base, Message: "a"},
+		{Timestamp: base, Message: "b"},
+		{Timestamp: base, Message: "c"},
+		{Timestamp: base.Add(time.Second), Message: "d"},
+	}
+
+	lastEventID, err := ParseEventID(EventID{Timestamp: base, Seq: 1}.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &bytes.Buffer{}
+	e := NewSSE(w, WithLastEventID(lastEventID))
+	for _, msg := range msgs {
+		if err := e.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := "id: 1547293526000000000-2\ndata: " + messageToString(msgs[3]) + "\n\n\n" +
+		"id: 1547293527000000000-0\ndata: " + messageToString(msgs[4]) + "\n\n\n"
+	if got := w.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestSSEMultilineData(t *testing.T) {
+	w := &bytes.Buffer{}
+	msg := Message{Timestamp: time.Unix(0, 0).UTC(), Application: "app", Process: "web.1", Message: "one\ntwo\n"}
+	if err := NewSSE(w).Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id: 0-0\ndata: 1970-01-01T00:00:00.000000+00:00 app[web.1]: one\ndata: two\n\n\n"
+	if got := w.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestSSEEventTypeLineBreaks(t *testing.T) {
+	w := &bytes.Buffer{}
+	e := NewSSE(w, WithEventType("log\r\ndata: injected"))
+	if err := e.Encode(Message{Timestamp: time.Unix(0, 0).UTC(), Message: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := w.String(); !strings.Contains(got, "\nevent: logdata: injected\n") || strings.Count(got, "data: ") != 2 {
+		t.Fatalf("want line breaks removed from the event field, got %q", got)
+	}
+}
+
+type errWriter struct{ err error }
+
+func (w errWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestSSEWriteErrors(t *testing.T) {
+	wantErr := errors.New("connection closed")
+	e := NewSSE(errWriter{err: wantErr}, WithRetry(time.Second))
+
+	if err := e.Encode(Message{Timestamp: time.Unix(0, 0), Message: "hi"}); err != wantErr {
+		t.Fatalf("want %v from Encode, got %v", wantErr, err)
+	}
+	if err := e.KeepAlive(); err != wantErr {
+		t.Fatalf("want %v from KeepAlive, got %v", wantErr, err)
+	}
+}
+
+func TestParseEventID(t *testing.T) {
+	tests := map[string]struct {
+		id   string
+		want EventID
+		err  error
+	}{
+		"current":  {id: "1547293526371000000-3", want: EventID{Timestamp: time.Unix(1547293526, 371000000), Seq: 3}},
+		"legacy":   {id: "1547293526", want: EventID{Timestamp: time.Unix(1547293526, 0)}},
+		"empty":    {id: "", err: ErrInvalidEventID},
+		"bad seq":  {id: "1547293526371000000-x", err: ErrInvalidEventID},
+		"negative": {id: "-1", err: ErrInvalidEventID},
+		"garbage":  {id: "abc", err: ErrInvalidEventID},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseEventID(test.id)
+			if errors.Cause(err) != test.err {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+
+			if !got.Timestamp.Equal(test.want.Timestamp) || got.Seq != test.want.Seq {
+				t.Fatalf("want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
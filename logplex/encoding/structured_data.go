@@ -0,0 +1,271 @@
+package encoding
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SDParam is a single RFC5424 SD-PARAM, e.g. ip="1.2.3.4".
+type SDParam struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SDElement is a single RFC5424 SD-ELEMENT, e.g. [origin@123 ip="1.2.3.4"].
+type SDElement struct {
+	ID     string    `json:"id"`
+	Params []SDParam `json:"params,omitempty"`
+}
+
+// StructuredData holds the RFC5424 STRUCTURED-DATA of a message. A nil or
+// empty StructuredData is encoded as the NILVALUE ('-').
+type StructuredData []SDElement
+
+// Element returns the first element with the given SD-ID.
+func (sd StructuredData) Element(id string) (SDElement, bool) {
+	for _, e := range sd {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return SDElement{}, false
+}
+
+// Param returns the value of the first param named name within the first
+// element with the given SD-ID.
+func (sd StructuredData) Param(id, name string) (string, bool) {
+	e, ok := sd.Element(id)
+	if !ok {
+		return "", false
+	}
+	return e.Param(name)
+}
+
+// Param returns the value of the first param named name.
+func (e SDElement) Param(name string) (string, bool) {
+	for _, p := range e.Params {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// String returns the wire representation of the structured data. Invalid
+// SD-IDs or PARAM-NAMEs are written as-is; use Encode to validate them.
+func (sd StructuredData) String() string {
+	var b strings.Builder
+	writeStructuredData(&b, sd)
+	return b.String()
+}
+
+func (sd StructuredData) validate() error {
+	for _, e := range sd {
+		if !validSDName(e.ID) {
+			return errors.Wrapf(ErrInvalidMessage, "structured data id %q", e.ID)
+		}
+		for _, p := range e.Params {
+			if !validSDName(p.Name) {
+				return errors.Wrapf(ErrInvalidMessage, "structured data param name %q", p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// validSDName reports whether s is a valid SD-NAME: 1-32 printable US-ASCII
+// characters except '=', ' ', ']' and '"'. SD-NAMEs are validated the same
+// way when decoding and encoding, so that decoded messages encode back.
+func validSDName(s string) bool {
+	if s == "" || len(s) > 32 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			return false
+		}
+	}
+	return true
+}
+
+func writeStructuredData(b *strings.Builder, sd StructuredData) {
+	if len(sd) == 0 {
+		b.WriteByte('-')
+		return
+	}
+
+	for _, e := range sd {
+		b.WriteByte('[')
+		b.WriteString(e.ID)
+		for _, p := range e.Params {
+			b.WriteByte(' ')
+			b.WriteString(p.Name)
+			b.WriteString(`="`)
+			for i := 0; i < len(p.Value); i++ {
+				// PARAM-VALUE = UTF-8-STRING ; characters '"', '\' and ']' MUST be escaped.
+				switch c := p.Value[i]; c {
+				case '"', '\\', ']':
+					b.WriteByte('\\')
+					b.WriteByte(c)
+				default:
+					b.WriteByte(c)
+				}
+			}
+			b.WriteByte('"')
+		}
+		b.WriteByte(']')
+	}
+}
+
+// scanStructuredData returns the STRUCTURED-DATA field at the start of data
+// along with the number of bytes it spans, including the space following it.
+// The returned field is still escaped and aliases data.
+func scanStructuredData(data []byte) ([]byte, int, error) {
+	// notice the quoting
+	// [meta sequenceId=\"518\"][meta somethingElse=\"bl\]ah\"]
+	if len(data) == 0 {
+		return nil, 0, io.EOF
+	}
+
+	if data[0] == '-' {
+		// trash the following space too
+		if len(data) < 2 {
+			return nil, 0, io.EOF
+		}
+		return data[:1], 2, nil
+	}
+
+	if data[0] != '[' {
+		return nil, 0, ErrInvalidStructuredData
+	}
+
+	i := 0
+	for {
+		end := sdElementEnd(data[i:])
+		if end == -1 {
+			return nil, 0, io.EOF
+		}
+		i += end + 1
+
+		if i >= len(data) {
+			return nil, 0, io.EOF
+		}
+
+		switch data[i] {
+		case ' ':
+			// we done!
+			// consumed the last ']' and hit a space
+			return data[:i], i + 1, nil
+		case '[':
+			continue
+		default:
+			return nil, 0, ErrInvalidStructuredData
+		}
+	}
+}
+
+// sdElementEnd returns the index of the first unescaped ']' in data, or -1.
+func sdElementEnd(data []byte) int {
+	quoting := false
+	for i, c := range data {
+		// makes sure we dont catch '\]' as per RFC
+		switch {
+		case quoting:
+			quoting = false
+		case c == '\\':
+			quoting = true
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// decodeStructuredData parses a STRUCTURED-DATA field previously delimited
+// by scanStructuredData.
+func decodeStructuredData(sd []byte) (StructuredData, error) {
+	if len(sd) == 1 && sd[0] == '-' {
+		return nil, nil
+	}
+
+	var out StructuredData
+	for len(sd) > 0 {
+		end := sdElementEnd(sd)
+		if sd[0] != '[' || end == -1 {
+			return nil, ErrInvalidStructuredData
+		}
+
+		elem, err := parseSDElement(sd[1:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, elem)
+		sd = sd[end+1:]
+	}
+
+	return out, nil
+}
+
+// parseSDElement parses the contents of an SD-ELEMENT, without its
+// surrounding brackets. A PARAM-VALUE missing its closing quote is accepted
+// and runs to the end of the element, matching how such elements were
+// previously tolerated.
+func parseSDElement(raw []byte) (SDElement, error) {
+	var elem SDElement
+
+	sp := bytes.IndexByte(raw, ' ')
+	if sp == -1 {
+		sp = len(raw)
+	}
+	elem.ID = string(raw[:sp])
+	if !validSDName(elem.ID) {
+		return elem, ErrInvalidStructuredData
+	}
+	raw = raw[sp:]
+
+	for len(raw) > 0 {
+		if raw[0] != ' ' {
+			return elem, ErrInvalidStructuredData
+		}
+		raw = raw[1:]
+
+		eq := bytes.IndexByte(raw, '=')
+		if eq <= 0 || eq+1 >= len(raw) || raw[eq+1] != '"' {
+			return elem, ErrInvalidStructuredData
+		}
+		name := string(raw[:eq])
+		if !validSDName(name) {
+			return elem, ErrInvalidStructuredData
+		}
+		raw = raw[eq+2:]
+
+		var value []byte
+		value, raw = unescapeSDValue(raw)
+		elem.Params = append(elem.Params, SDParam{Name: name, Value: string(value)})
+	}
+
+	return elem, nil
+}
+
+// unescapeSDValue reads a PARAM-VALUE up to its closing quote, returning the
+// unescaped value and the remainder following the quote.
+func unescapeSDValue(raw []byte) (value, rest []byte) {
+	value = make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			return value, raw[i+1:]
+		case c == '\\' && i+1 < len(raw) && (raw[i+1] == '"' || raw[i+1] == '\\' || raw[i+1] == ']'):
+			i++
+			value = append(value, raw[i])
+		default:
+			value = append(value, c)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,211 @@
+package encoding
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecodeStructuredData(t *testing.T) {
+	tests := map[string]struct {
+		sd   string
+		want StructuredData
+		err  error
+	}{
+		"nil": {
+			sd: "-",
+		},
+		"single": {
+			sd: `[origin@123 ip="1.2.3.4"]`,
+			want: StructuredData{
+				{ID: "origin@123", Params: []SDParam{{Name: "ip", Value: "1.2.3.4"}}},
+			},
+		},
+		"multiple": {
+			sd: `[meta sequenceId="518" x="y"][timeQuality tzKnown="1"][exampleSDID@32473]`,
+			want: StructuredData{
+				{ID: "meta", Params: []SDParam{{Name: "sequenceId", Value: "518"}, {Name: "x", Value: "y"}}},
+				{ID: "timeQuality", Params: []SDParam{{Name: "tzKnown", Value: "1"}}},
+				{ID: "exampleSDID@32473"},
+			},
+		},
+		"escaped": {
+			sd: `[meta a="q\"uote" b="back\\slash" c="brack\]et" d="not\n escaped"]`,
+			want: StructuredData{
+				{ID: "meta", Params: []SDParam{
+					{Name: "a", Value: `q"uote`},
+					{Name: "b", Value: `back\slash`},
+					{Name: "c", Value: `brack]et`},
+					{Name: "d", Value: `not\n escaped`},
+				}},
+			},
+		},
+		"unterminated value": {
+			sd: `[meta somethingElse="blah\]]`,
+			want: StructuredData{
+				{ID: "meta", Params: []SDParam{{Name: "somethingElse", Value: "blah]"}}},
+			},
+		},
+		"missing id": {
+			sd:  `[ a="b"]`,
+			err: ErrInvalidStructuredData,
+		},
+		"missing value quote": {
+			sd:  `[meta a=b]`,
+			err: ErrInvalidStructuredData,
+		},
+		"garbage after value": {
+			sd:  `[meta a="b"c]`,
+			err: ErrInvalidStructuredData,
+		},
+		"bad separator": {
+			sd:  `[meta a="b"]|meta c="d"]`,
+			err: ErrInvalidStructuredData,
+		},
+		"too long id": {
+			sd:  `[abcdefghijklmnopqrstuvwxyz0123456789 a="b"]`,
+			err: ErrInvalidStructuredData,
+		},
+		"non ascii param name": {
+			sd:  `[meta nàme="b"]`,
+			err: ErrInvalidStructuredData,
+		},
+		"quote in param name": {
+			sd:  `[meta a"b="c"]`,
+			err: ErrInvalidStructuredData,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			raw := "<134>1 2019-01-12T11:45:26.371Z host app web.1 - " + test.sd + " hello"
+			msg, err := Decode([]byte(raw), true)
+			if got, want := errors.Cause(err), test.err; got != want {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+			if test.err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(msg.StructuredData, test.want) {
+				t.Errorf("want structured data %#v, got %#v", test.want, msg.StructuredData)
+			}
+
+			if msg.Message != "hello" {
+				t.Errorf("want message %q, got %q", "hello", msg.Message)
+			}
+		})
+	}
+}
+
+func TestStructuredDataRoundTrip(t *testing.T) {
+	lockedDate, _ := time.Parse("2006-01-02T15:04:05.000Z", "2019-01-12T11:45:26.371Z")
+
+	msg := Message{
+		Version:     1,
+		Priority:    134,
+		Hostname:    "hostname",
+		Application: "application",
+		Process:     "process",
+		ID:          "msgid",
+		Timestamp:   lockedDate,
+		Message:     "hi",
+		StructuredData: StructuredData{
+			{ID: "origin@123", Params: []SDParam{{Name: "ip", Value: "1.2.3.4"}}},
+			{ID: "meta", Params: []SDParam{{Name: "note", Value: `a "quoted" \ [value]`}}},
+		},
+	}
+
+	b, err := Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLine := `<134>1 2019-01-12T11:45:26.371+00:00 hostname application process msgid ` +
+		`[origin@123 ip="1.2.3.4"][meta note="a \"quoted\" \\ [value\]"] hi`
+	if want, got := strconv.Itoa(len(wantLine))+" "+wantLine, string(b); want != got {
+		t.Fatalf("want encoded %q, got %q", want, got)
+	}
+
+	s := NewScanner(bytes.NewReader(b))
+	if !s.Scan() {
+		t.Fatalf("scan failed: %v", s.Err())
+	}
+
+	got := s.Message()
+	if !got.Timestamp.Equal(msg.Timestamp) {
+		t.Fatalf("want timestamp %v, got %v", msg.Timestamp, got.Timestamp)
+	}
+	got.Timestamp = msg.Timestamp
+	if !reflect.DeepEqual(got, msg) {
+		t.Fatalf("want %#v, got %#v", msg, got)
+	}
+
+	if v, ok := got.StructuredData.Param("origin@123", "ip"); !ok || v != "1.2.3.4" {
+		t.Errorf("want ip param 1.2.3.4, got %q (found: %v)", v, ok)
+	}
+}
+
+func TestDecodeEncodeKeepsCompliance(t *testing.T) {
+	tests := map[string]string{
+		"nil structured data": "<134>1 2019-01-12T11:45:26.371+00:00 host app web.1 - - hello",
+		"structured data":     `<134>1 2019-01-12T11:45:26.371+00:00 host app web.1 - [meta a="b"] hello`,
+	}
+
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg, err := Decode([]byte(raw), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.RFCCompliant {
+				t.Fatal("want RFCCompliant left unset")
+			}
+
+			b, err := Encode(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the NILVALUE is dropped unless RFCCompliant is set
+			want := strings.Replace(raw, "- - ", "- ", 1)
+			if want, got := strconv.Itoa(len(want))+" "+want, string(b); want != got {
+				t.Fatalf("want encoded %q, got %q", want, got)
+			}
+
+			msg.RFCCompliant = true
+			if b, err = Encode(msg); err != nil {
+				t.Fatal(err)
+			}
+			if want, got := strconv.Itoa(len(raw))+" "+raw, string(b); want != got {
+				t.Fatalf("want RFC compliant encoded %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestEncodeInvalidStructuredData(t *testing.T) {
+	tests := map[string]StructuredData{
+		"empty id":         {{ID: ""}},
+		"space in id":      {{ID: "a b"}},
+		"equals in name":   {{ID: "meta", Params: []SDParam{{Name: "a=b"}}}},
+		"too long id":      {{ID: "abcdefghijklmnopqrstuvwxyz0123456789"}},
+		"non ascii in id":  {{ID: "métà"}},
+		"quote in name":    {{ID: "meta", Params: []SDParam{{Name: `a"`}}}},
+		"bracket in name":  {{ID: "meta", Params: []SDParam{{Name: "a]"}}}},
+		"empty param name": {{ID: "meta", Params: []SDParam{{Name: ""}}}},
+	}
+
+	for name, sd := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg := Message{Version: 1, RFCCompliant: true, StructuredData: sd}
+			if _, err := Encode(msg); errors.Cause(err) != ErrInvalidMessage {
+				t.Fatalf("expected %v, got %v", ErrInvalidMessage, err)
+			}
+		})
+	}
+}
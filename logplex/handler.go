@@ -0,0 +1,155 @@
+package logplex
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/go-kit/metrics/provider/discard"
+	"github.com/heroku/x/hmiddleware/basicauth"
+	"github.com/heroku/x/logplex/encoding"
+)
+
+// MessagesFunc receives the messages of a drain request. Returning an error
+// responds with a 503, asking the sender to retry the request later.
+type MessagesFunc func(ctx context.Context, msgs []encoding.Message) error
+
+// ChannelFunc returns a MessagesFunc sending each message to ch. It blocks
+// until the messages are sent or the request is canceled.
+func ChannelFunc(ch chan<- encoding.Message) MessagesFunc {
+	return func(ctx context.Context, msgs []encoding.Message) error {
+		for _, msg := range msgs {
+			select {
+			case ch <- msg:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+}
+
+// HandlerConfig configures a Handler. The zero value accepts all requests.
+type HandlerConfig struct {
+	// Checker, if set, requires requests to use valid basic auth credentials.
+	Checker *basicauth.Checker
+
+	// MaxBodyBytes, if set, limits the size of request bodies.
+	MaxBodyBytes int64
+
+	// ScannerOptions are passed to encoding.NewDrainScanner.
+	ScannerOptions []encoding.ScannerOption
+
+	// MetricsProvider receives request metrics, prefixed with
+	// "logplex.handler.". Defaults to discarding them.
+	MetricsProvider xmetrics.Provider
+}
+
+// Handler accepts logplex drain requests. Requests are decoded and validated
+// in full before their messages are handed over, so that invalid requests
+// never result in partial deliveries:
+//
+//   - 405 for methods other than POST
+//   - 401 for missing or invalid credentials
+//   - 400 for a missing or mismatching Logplex-Msg-Count, or malformed frames
+//   - 413 for bodies larger than MaxBodyBytes
+//   - 503 when the MessagesFunc fails
+//   - 204 otherwise
+type Handler struct {
+	cfg HandlerConfig
+	fn  MessagesFunc
+
+	requests     metrics.Counter
+	messages     metrics.Counter
+	authFailures metrics.Counter
+	invalid      metrics.Counter
+	failures     metrics.Counter
+}
+
+// NewHandler returns a Handler passing the messages of each request to fn.
+func NewHandler(fn MessagesFunc, cfg HandlerConfig) *Handler {
+	if cfg.MetricsProvider == nil {
+		cfg.MetricsProvider = discard.New()
+	}
+
+	p := cfg.MetricsProvider
+	return &Handler{
+		cfg:          cfg,
+		fn:           fn,
+		requests:     p.NewCounter("logplex.handler.requests"),
+		messages:     p.NewCounter("logplex.handler.messages"),
+		authFailures: p.NewCounter("logplex.handler.auth-failures"),
+		invalid:      p.NewCounter("logplex.handler.invalid-requests"),
+		failures:     p.NewCounter("logplex.handler.delivery-failures"),
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests.Add(1)
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.cfg.Checker != nil {
+		username, password, ok := r.BasicAuth()
+		if !ok || !h.cfg.Checker.Valid(username, password) {
+			h.authFailures.Add(1)
+			w.Header().Set("WWW-Authenticate", `Basic realm="logplex"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	count, err := strconv.Atoi(r.Header.Get(MsgCountHeader))
+	if err != nil || count < 0 {
+		h.invalid.Add(1)
+		http.Error(w, "invalid "+MsgCountHeader+" header", http.StatusBadRequest)
+		return
+	}
+
+	body := r.Body
+	if h.cfg.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(w, body, h.cfg.MaxBodyBytes)
+	}
+
+	// the header is only trusted as far as the default batch size
+	msgs := make([]encoding.Message, 0, min(count, DefaultBatchSize))
+	s := encoding.NewDrainScanner(body, h.cfg.ScannerOptions...)
+	for s.Scan() {
+		msgs = append(msgs, s.Message())
+	}
+
+	if err := s.Err(); err != nil {
+		h.invalid.Add(1)
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if len(msgs) != count {
+		h.invalid.Add(1)
+		http.Error(w, MsgCountHeader+" header doesn't match the number of messages", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.fn(r.Context(), msgs); err != nil {
+		h.failures.Add(1)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.messages.Add(float64(len(msgs)))
+	w.WriteHeader(http.StatusNoContent)
+}
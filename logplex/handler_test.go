@@ -0,0 +1,182 @@
+package logplex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+	"github.com/heroku/x/hmiddleware/basicauth"
+	"github.com/heroku/x/logplex/encoding"
+)
+
+const (
+	testFrame1 = "62 <190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - 99\n"
+	testFrame2 = "63 <190>1 2019-07-20T17:50:10.879238Z shuttle token shuttle - 100\n"
+)
+
+func TestHandler(t *testing.T) {
+	tests := map[string]struct {
+		method     string
+		auth       []string
+		count      string
+		body       string
+		fnErr      error
+		wantStatus int
+		wantMsgs   []string
+		wantMetric string
+	}{
+		"valid": {
+			count:      "2",
+			body:       testFrame1 + testFrame2,
+			wantStatus: http.StatusNoContent,
+			wantMsgs:   []string{"99\n", "100\n"},
+			wantMetric: "logplex.handler.messages",
+		},
+		"wrong method": {
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		"missing auth": {
+			auth:       []string{},
+			count:      "1",
+			body:       testFrame1,
+			wantStatus: http.StatusUnauthorized,
+			wantMetric: "logplex.handler.auth-failures",
+		},
+		"invalid auth": {
+			auth:       []string{"user", "wrong"},
+			count:      "1",
+			body:       testFrame1,
+			wantStatus: http.StatusUnauthorized,
+			wantMetric: "logplex.handler.auth-failures",
+		},
+		"missing count": {
+			body:       testFrame1,
+			wantStatus: http.StatusBadRequest,
+			wantMetric: "logplex.handler.invalid-requests",
+		},
+		"count mismatch": {
+			count:      "3",
+			body:       testFrame1 + testFrame2,
+			wantStatus: http.StatusBadRequest,
+			wantMetric: "logplex.handler.invalid-requests",
+		},
+		"bad frame": {
+			count:      "2",
+			body:       testFrame1 + "10 <190>1",
+			wantStatus: http.StatusBadRequest,
+			wantMetric: "logplex.handler.invalid-requests",
+		},
+		"too large": {
+			count:      "2",
+			body:       testFrame1 + testFrame2 + testFrame1,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantMetric: "logplex.handler.invalid-requests",
+		},
+		"delivery failure": {
+			count:      "1",
+			body:       testFrame1,
+			fnErr:      errors.New("boom"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantMetric: "logplex.handler.delivery-failures",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := testmetrics.NewProvider(t)
+			var got []string
+			h := NewHandler(func(_ context.Context, msgs []encoding.Message) error {
+				for _, msg := range msgs {
+					got = append(got, msg.Message)
+				}
+				return test.fnErr
+			}, HandlerConfig{
+				Checker:         basicauth.NewChecker([]basicauth.Credential{{Username: "user", Password: "pass"}}),
+				MaxBodyBytes:    int64(len(testFrame1) * 3),
+				MetricsProvider: p,
+			})
+
+			method := test.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/logs", strings.NewReader(test.body))
+			if test.count != "" {
+				req.Header.Set(MsgCountHeader, test.count)
+			}
+			switch {
+			case test.auth == nil:
+				req.SetBasicAuth("user", "pass")
+			case len(test.auth) == 2:
+				req.SetBasicAuth(test.auth[0], test.auth[1])
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != test.wantStatus {
+				t.Fatalf("want status %d, got %d: %s", test.wantStatus, rec.Code, rec.Body)
+			}
+
+			if test.wantStatus == http.StatusNoContent {
+				if strings.Join(got, "|") != strings.Join(test.wantMsgs, "|") {
+					t.Fatalf("want messages %q, got %q", test.wantMsgs, got)
+				}
+			}
+
+			p.CheckCounter("logplex.handler.requests", 1)
+			if test.wantMetric != "" {
+				p.CheckCounterExists(test.wantMetric)
+			}
+		})
+	}
+}
+
+func TestHandlerWithClient(t *testing.T) {
+	ch := make(chan encoding.Message, 10)
+	h := NewHandler(ChannelFunc(ch), HandlerConfig{
+		Checker: basicauth.NewChecker([]basicauth.Credential{{Username: "user", Password: "pass"}}),
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.User = url.UserPassword("user", "pass")
+
+	c, err := NewClient(ClientConfig{URL: u, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := c.Send(testMessage(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Stop(nil)
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	close(ch)
+	var got []string
+	for msg := range ch {
+		got = append(got, msg.Message)
+	}
+
+	if want := "message 0|message 1|message 2"; strings.Join(got, "|") != want {
+		t.Fatalf("want messages %q, got %q", want, got)
+	}
+}
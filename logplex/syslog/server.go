@@ -0,0 +1,240 @@
+// Package syslog transports octet-framed syslog messages (RFC 6587 and RFC
+// 5425) over TCP and TLS.
+package syslog
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/go-kit/metrics/provider/discard"
+	"github.com/heroku/x/logplex/encoding"
+)
+
+// MessageFunc is called with each message received by a Server. Calls for
+// messages of the same connection are sequential and in order.
+type MessageFunc func(remote net.Addr, msg encoding.Message)
+
+// ServerConfig configures a Server.
+type ServerConfig struct {
+	// Addr to listen on when using Run, e.g. ":6514".
+	Addr string
+
+	// TLS, if set, makes the server accept TLS connections only.
+	TLS *tls.Config
+
+	// TLSPresets are applied to TLS, e.g. tlsconfig.Modern.
+	TLSPresets []func(*tls.Config)
+
+	// MaxFrameLength is the maximum length of a frame, excluding its length
+	// prefix. Connections sending longer frames are closed. Defaults to
+	// encoding.MaxFrameLength.
+	MaxFrameLength int
+
+	// TruncateLength, if set, truncates messages longer than it.
+	TruncateLength int
+
+	// IdleTimeout, if set, closes connections that have been idle for longer.
+	IdleTimeout time.Duration
+
+	// ScannerOptions are passed to encoding.NewScanner after the options
+	// derived from the fields above.
+	ScannerOptions []encoding.ScannerOption
+
+	// Logger receives connection errors. Defaults to discarding them.
+	Logger logrus.FieldLogger
+
+	// MetricsProvider receives connection metrics, prefixed with
+	// "syslog.server.". Defaults to discarding them.
+	MetricsProvider xmetrics.Provider
+}
+
+// Server accepts connections sending octet-framed syslog, decoding them with
+// encoding.NewScanner. It is a cmdutil.Server.
+type Server struct {
+	cfg ServerConfig
+	fn  MessageFunc
+
+	mu       sync.Mutex
+	ln       net.Listener
+	conns    map[net.Conn]struct{}
+	stopping bool
+	wg       sync.WaitGroup
+
+	connections metrics.Counter
+	messages    metrics.Counter
+	errors      metrics.Counter
+}
+
+// NewServer returns a Server calling fn with each message received.
+func NewServer(cfg ServerConfig, fn MessageFunc) *Server {
+	if cfg.MaxFrameLength <= 0 {
+		cfg.MaxFrameLength = encoding.MaxFrameLength
+	}
+	if cfg.Logger == nil {
+		l := logrus.New()
+		l.Out = io.Discard
+		cfg.Logger = l
+	}
+	if cfg.MetricsProvider == nil {
+		cfg.MetricsProvider = discard.New()
+	}
+	if cfg.TLS != nil {
+		cfg.TLS = cfg.TLS.Clone()
+		for _, preset := range cfg.TLSPresets {
+			preset(cfg.TLS)
+		}
+	}
+
+	p := cfg.MetricsProvider
+	return &Server{
+		cfg:         cfg,
+		fn:          fn,
+		conns:       make(map[net.Conn]struct{}),
+		connections: p.NewCounter("syslog.server.connections"),
+		messages:    p.NewCounter("syslog.server.messages"),
+		errors:      p.NewCounter("syslog.server.errors"),
+	}
+}
+
+// Run listens on cfg.Addr and serves connections until Stop is called.
+func (s *Server) Run() error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return errors.Wrap(err, "listening to tcp addr")
+	}
+	return s.Serve(ln)
+}
+
+// Serve connections accepted on ln until Stop is called. ln is wrapped with
+// TLS if configured.
+func (s *Server) Serve(ln net.Listener) error {
+	if s.cfg.TLS != nil {
+		ln = tls.NewListener(ln, s.cfg.TLS)
+	}
+
+	s.mu.Lock()
+	if s.stopping {
+		s.mu.Unlock()
+		ln.Close()
+		return nil
+	}
+	s.ln = ln
+	s.mu.Unlock()
+
+	defer s.wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			stopping := s.stopping
+			s.mu.Unlock()
+			if stopping {
+				return nil
+			}
+			return errors.Wrap(err, "accept")
+		}
+
+		if !s.track(conn) {
+			conn.Close()
+			return nil
+		}
+
+		s.connections.Add(1)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(conn)
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Stop closes the listener and all open connections.
+func (s *Server) Stop(error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopping = true
+	if s.ln != nil {
+		s.ln.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+	conn.Close()
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	// room for the frame length prefix and its separator
+	bufLen := s.cfg.MaxFrameLength + len(strconv.Itoa(s.cfg.MaxFrameLength)) + 1
+	opts := []encoding.ScannerOption{
+		encoding.WithBuffer(min(encoding.OptimalFrameLength, bufLen), bufLen),
+	}
+	if s.cfg.TruncateLength > 0 {
+		opts = append(opts, encoding.WithSplit(encoding.TruncatingSyslogSplitFunc(s.cfg.TruncateLength)))
+	}
+	opts = append(opts, s.cfg.ScannerOptions...)
+
+	var r net.Conn = conn
+	if s.cfg.IdleTimeout > 0 {
+		r = &idleTimeoutConn{Conn: conn, timeout: s.cfg.IdleTimeout}
+	}
+
+	scanner := encoding.NewScanner(r, opts...)
+	for scanner.Scan() {
+		s.messages.Add(1)
+		s.fn(conn.RemoteAddr(), scanner.Message())
+	}
+
+	if err := scanner.Err(); err != nil && !s.isStopping() {
+		s.errors.Add(1)
+		s.cfg.Logger.WithError(err).WithField("remote", conn.RemoteAddr().String()).Info("closing syslog connection")
+	}
+}
+
+func (s *Server) isStopping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopping
+}
+
+// idleTimeoutConn extends the read deadline before each read.
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(p []byte) (int, error) {
+	if err := c.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,191 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+	"github.com/heroku/x/logplex/encoding"
+	"github.com/heroku/x/testing/mustcert"
+	"github.com/heroku/x/tlsconfig"
+)
+
+func testMessage(msg string) encoding.Message {
+	return encoding.Message{
+		Timestamp:    time.Date(2019, 7, 20, 17, 50, 10, 879238000, time.UTC),
+		Hostname:     "host",
+		Application:  "app",
+		Process:      "web.1",
+		ID:           "-",
+		Message:      msg,
+		Version:      1,
+		Priority:     190,
+		RFCCompliant: true,
+	}
+}
+
+type received struct {
+	msgs chan encoding.Message
+}
+
+func (r *received) fn(_ net.Addr, msg encoding.Message) {
+	r.msgs <- msg
+}
+
+func (r *received) next(t *testing.T) encoding.Message {
+	t.Helper()
+	select {
+	case msg := <-r.msgs:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+		return encoding.Message{}
+	}
+}
+
+func startServer(t *testing.T, cfg ServerConfig) (*Server, *received, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &received{msgs: make(chan encoding.Message, 10)}
+	s := NewServer(cfg, r.fn)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ln) }()
+	t.Cleanup(func() {
+		s.Stop(nil)
+		if err := <-done; err != nil {
+			t.Errorf("unexpected serve error: %v", err)
+		}
+	})
+
+	return s, r, ln.Addr().String()
+}
+
+func TestServerWriter(t *testing.T) {
+	ca := mustcert.CA("root", nil)
+	serverCert := ca.Sign(mustcert.Leaf("127.0.0.1", nil))
+
+	tests := map[string]struct {
+		serverTLS *tls.Config
+		writerTLS *tls.Config
+	}{
+		"tcp": {},
+		"tls": {
+			serverTLS: &tls.Config{Certificates: []tls.Certificate{*serverCert.TLS()}},
+			writerTLS: &tls.Config{RootCAs: mustcert.Pool(ca.TLS())},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := testmetrics.NewProvider(t)
+			_, r, addr := startServer(t, ServerConfig{
+				TLS:             test.serverTLS,
+				TLSPresets:      []func(*tls.Config){tlsconfig.Modern},
+				MetricsProvider: p,
+			})
+
+			w := NewWriter(WriterConfig{
+				Addr:       addr,
+				TLS:        test.writerTLS,
+				TLSPresets: []func(*tls.Config){tlsconfig.Modern},
+			})
+			defer w.Close()
+
+			for _, want := range []string{"first", "second"} {
+				if err := w.Write(testMessage(want)); err != nil {
+					t.Fatal(err)
+				}
+				if got := r.next(t); got.Message != want || got.Application != "app" {
+					t.Fatalf("want message %q from app, got %+v", want, got)
+				}
+			}
+
+			p.CheckCounter("syslog.server.connections", 1)
+			p.CheckCounter("syslog.server.messages", 2)
+		})
+	}
+}
+
+func TestServerFrameLength(t *testing.T) {
+	long := strings.Repeat("x", 100)
+
+	tests := map[string]struct {
+		cfg        ServerConfig
+		want       string
+		wantClosed bool
+	}{
+		"max frame length": {
+			cfg:        ServerConfig{MaxFrameLength: 90},
+			wantClosed: true,
+		},
+		"truncated": {
+			cfg:  ServerConfig{MaxFrameLength: 200, TruncateLength: 80},
+			want: long[:80-len("<190>1 2019-07-20T17:50:10.879238+00:00 host app web.1 - - ")],
+		},
+		"within limit": {
+			cfg:  ServerConfig{MaxFrameLength: 200},
+			want: long,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := testmetrics.NewProvider(t)
+			test.cfg.MetricsProvider = p
+			_, r, addr := startServer(t, test.cfg)
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			if _, err := testMessage(long).WriteTo(conn); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.wantClosed {
+				if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := conn.Read(make([]byte, 1)); err == nil {
+					t.Fatal("want connection to be closed")
+				}
+				p.CheckCounter("syslog.server.errors", 1)
+				return
+			}
+
+			if got := r.next(t); got.Message != test.want {
+				t.Fatalf("want message %q, got %q", test.want, got.Message)
+			}
+		})
+	}
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	_, _, addr := startServer(t, ServerConfig{IdleTimeout: 10 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("want idle connection to be closed")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("timed out waiting for the server to close the connection")
+	}
+}
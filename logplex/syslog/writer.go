@@ -0,0 +1,224 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/heroku/x/logplex/encoding"
+)
+
+const (
+	// DefaultDialTimeout is the default timeout for establishing connections.
+	DefaultDialTimeout = 10 * time.Second
+
+	// DefaultWriteTimeout is the default timeout for writing a message.
+	DefaultWriteTimeout = 10 * time.Second
+
+	// DefaultMinReconnectDelay is the default delay before reconnecting after
+	// a failed connection attempt.
+	DefaultMinReconnectDelay = 100 * time.Millisecond
+
+	// DefaultMaxReconnectDelay is the default maximum delay between
+	// connection attempts.
+	DefaultMaxReconnectDelay = 30 * time.Second
+)
+
+// ErrReconnectDelay is returned by Write while waiting to reconnect after a
+// failed connection attempt.
+var ErrReconnectDelay = errors.New("syslog: waiting to reconnect")
+
+// WriterConfig configures a Writer. Only Addr is required.
+type WriterConfig struct {
+	// Addr to connect to, e.g. "logs.example.com:6514".
+	Addr string
+
+	// TLS, if set, makes the writer connect using TLS.
+	TLS *tls.Config
+
+	// TLSPresets are applied to TLS, e.g. tlsconfig.Modern.
+	TLSPresets []func(*tls.Config)
+
+	// Timeouts and reconnection delays. Zero values are replaced by the
+	// corresponding Default* constant. MaxReconnectDelay is raised to
+	// MinReconnectDelay if below it.
+	DialTimeout       time.Duration
+	WriteTimeout      time.Duration
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
+}
+
+// Writer writes octet-framed messages to a syslog server, reconnecting as
+// needed. It is safe for concurrent use.
+type Writer struct {
+	cfg WriterConfig
+
+	mu        sync.Mutex
+	conn      net.Conn
+	failures  int
+	nextDial  time.Time
+	closed    bool
+	dialer    *net.Dialer
+	timeNowFn func() time.Time
+}
+
+// NewWriter returns a Writer for cfg.Addr. It connects lazily, on the first
+// Write.
+func NewWriter(cfg WriterConfig) *Writer {
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = DefaultDialTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.MinReconnectDelay <= 0 {
+		cfg.MinReconnectDelay = DefaultMinReconnectDelay
+	}
+	if cfg.MaxReconnectDelay <= 0 {
+		cfg.MaxReconnectDelay = DefaultMaxReconnectDelay
+	}
+	cfg.MaxReconnectDelay = max(cfg.MaxReconnectDelay, cfg.MinReconnectDelay)
+	if cfg.TLS != nil {
+		cfg.TLS = cfg.TLS.Clone()
+		for _, preset := range cfg.TLSPresets {
+			preset(cfg.TLS)
+		}
+	}
+
+	return &Writer{
+		cfg:       cfg,
+		dialer:    &net.Dialer{Timeout: cfg.DialTimeout},
+		timeNowFn: time.Now,
+	}
+}
+
+// Write msg. Should writing to an established connection fail, the message
+// is retried once over a new connection. After failing to connect, Write
+// returns ErrReconnectDelay until the, exponentially increasing, reconnect
+// delay has passed.
+func (w *Writer) Write(msg encoding.Message) error {
+	bp := linePool.Get().(*[]byte)
+	defer func() {
+		// don't hold on to the buffers of unusually large messages
+		if cap(*bp) <= maxPooledLine {
+			linePool.Put(bp)
+		}
+	}()
+
+	line, err := encoding.AppendLine((*bp)[:0], msg)
+	*bp = line
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errors.New("syslog: writer closed")
+	}
+
+	reused := w.conn != nil
+	if err := w.write(line); err == nil || !reused {
+		return err
+	}
+
+	// the connection may have been closed by the server since last used
+	return w.write(line)
+}
+
+// maxPooledLine is the capacity of the largest buffers kept in linePool.
+const maxPooledLine = 64 * 1024
+
+// linePool holds the buffers messages are encoded into.
+var linePool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// write writes line, framed with its octet count.
+func (w *Writer) write(line []byte) error {
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.conn.SetWriteDeadline(w.timeNowFn().Add(w.cfg.WriteTimeout)); err != nil {
+		w.disconnect()
+		return err
+	}
+
+	var prefix [24]byte
+	frame := net.Buffers{append(strconv.AppendInt(prefix[:0], int64(len(line)), 10), ' '), line}
+	if _, err := frame.WriteTo(w.conn); err != nil {
+		// a partial write leaves the stream unframed; start over
+		w.disconnect()
+		return errors.Wrap(err, "writing message")
+	}
+
+	return nil
+}
+
+func (w *Writer) connect() error {
+	if now := w.timeNowFn(); now.Before(w.nextDial) {
+		return ErrReconnectDelay
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if w.cfg.TLS != nil {
+		conn, err = tls.DialWithDialer(w.dialer, "tcp", w.cfg.Addr, w.cfg.TLS)
+	} else {
+		conn, err = w.dialer.Dial("tcp", w.cfg.Addr)
+	}
+
+	if err != nil {
+		w.failures++
+		w.nextDial = w.timeNowFn().Add(w.reconnectDelay())
+		return errors.Wrap(err, "connecting")
+	}
+
+	w.failures = 0
+	w.conn = conn
+	return nil
+}
+
+func (w *Writer) reconnectDelay() time.Duration {
+	d := w.cfg.MaxReconnectDelay
+	if w.failures <= 32 {
+		if exp := w.cfg.MinReconnectDelay << uint(w.failures-1); exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	return d
+}
+
+func (w *Writer) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// Close the connection. Further writes fail.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
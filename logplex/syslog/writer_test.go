@@ -0,0 +1,129 @@
+package syslog
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWriterReconnects(t *testing.T) {
+	s, r, addr := startServer(t, ServerConfig{})
+
+	w := NewWriter(WriterConfig{Addr: addr})
+	defer w.Close()
+
+	if err := w.Write(testMessage("first")); err != nil {
+		t.Fatal(err)
+	}
+	r.next(t)
+
+	// drop the connection server side
+	w.mu.Lock()
+	local := w.conn.LocalAddr().String()
+	w.mu.Unlock()
+	closeServerConn(t, s, local)
+
+	// the first write after the server closed the connection may appear to
+	// succeed; keep writing until a message makes it through a new one
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := w.Write(testMessage("second")); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case msg := <-r.msgs:
+			if msg.Message != "second" {
+				t.Fatalf("want message %q, got %q", "second", msg.Message)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for message")
+		}
+	}
+}
+
+// closeServerConn closes the server side of the connection from local.
+func closeServerConn(t *testing.T, s *Server, local string) {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		if conn.RemoteAddr().String() == local {
+			conn.Close()
+			return
+		}
+	}
+	t.Fatalf("no server connection from %s", local)
+}
+
+func TestWriterReconnectDelay(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	now := time.Now()
+	w := NewWriter(WriterConfig{
+		Addr:              addr,
+		MinReconnectDelay: time.Second,
+		MaxReconnectDelay: 3 * time.Second,
+	})
+	w.timeNowFn = func() time.Time { return now }
+	defer w.Close()
+
+	wantDelays := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	for i, want := range wantDelays {
+		if err := w.Write(testMessage("hi")); err == nil || err == ErrReconnectDelay {
+			t.Fatalf("attempt %d: want connection error, got %v", i, err)
+		}
+		if got := w.nextDial.Sub(now); got != want {
+			t.Fatalf("attempt %d: want delay %v, got %v", i, want, got)
+		}
+
+		now = now.Add(want - time.Millisecond)
+		if err := w.Write(testMessage("hi")); err != ErrReconnectDelay {
+			t.Fatalf("attempt %d: want %v, got %v", i, ErrReconnectDelay, err)
+		}
+		now = now.Add(time.Millisecond)
+	}
+}
+
+func TestWriterReconnectDelayDefaults(t *testing.T) {
+	tests := map[string]struct {
+		min, max         time.Duration
+		wantMin, wantMax time.Duration
+	}{
+		"defaults":          {wantMin: DefaultMinReconnectDelay, wantMax: DefaultMaxReconnectDelay},
+		"max below min":     {min: time.Second, max: time.Millisecond, wantMin: time.Second, wantMax: time.Second},
+		"min above default": {min: 2 * DefaultMaxReconnectDelay, wantMin: 2 * DefaultMaxReconnectDelay, wantMax: 2 * DefaultMaxReconnectDelay},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := NewWriter(WriterConfig{Addr: "127.0.0.1:0", MinReconnectDelay: test.min, MaxReconnectDelay: test.max})
+			defer w.Close()
+
+			if w.cfg.MinReconnectDelay != test.wantMin || w.cfg.MaxReconnectDelay != test.wantMax {
+				t.Fatalf("want reconnect delays %v-%v, got %v-%v",
+					test.wantMin, test.wantMax, w.cfg.MinReconnectDelay, w.cfg.MaxReconnectDelay)
+			}
+		})
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	w := NewWriter(WriterConfig{Addr: "127.0.0.1:0"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(testMessage("hi")); err == nil {
+		t.Fatal("want error writing to a closed writer")
+	}
+}
@@ -0,0 +1,278 @@
+// Package ownership assigns the shards of a sharder.Sharder to the members
+// of a group, e.g. the dynos of a process type, so that each shard is
+// processed by one member at a time.
+//
+// Members register through a Membership backend with leases they renew on
+// every heartbeat. Shards are assigned to live members with rendezvous
+// hashing, so members joining or leaving only move the shards they gain or
+// lose.
+//
+// Ownership is eventually consistent: while members observe a change in
+// membership at different heartbeats, a shard may briefly have two owners
+// or none. Work requiring strict mutual exclusion needs its own locking.
+package ownership
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+
+	"github.com/heroku/x/cmdutil"
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/go-kit/metrics/provider/discard"
+	"github.com/heroku/x/sharder"
+)
+
+// DefaultLeaseTTL is the default duration of membership leases.
+const DefaultLeaseTTL = 15 * time.Second
+
+var _ cmdutil.Server = (*Coordinator)(nil)
+
+// Rebalance describes a change in the shards owned by a member.
+type Rebalance struct {
+	// Members are the live members the shards were assigned to. It is empty
+	// when the member lost its lease or stopped.
+	Members []string
+
+	// Owned are all the shards now owned by the member.
+	Owned []string
+
+	// Acquired and Released are the shards the member gained and lost.
+	Acquired []string
+	Released []string
+}
+
+// Config configures a Coordinator. Member, Membership and Sharder are
+// required.
+type Config struct {
+	// Member is the unique name of this member, e.g. its dyno name.
+	Member string
+
+	// Membership tracks the live members.
+	Membership Membership
+
+	// Sharder holds the shards to assign, by name.
+	Sharder *sharder.Sharder
+
+	// LeaseTTL is the duration of membership leases. A member which fails
+	// to renew its lease releases all its shards a heartbeat interval before
+	// the lease expires, as other members take them over once it has.
+	// Defaults to DefaultLeaseTTL.
+	LeaseTTL time.Duration
+
+	// HeartbeatInterval is the interval at which leases are renewed and
+	// shards reassigned. It must be below LeaseTTL; defaults to a third of
+	// it.
+	HeartbeatInterval time.Duration
+
+	// OnRebalance, if set, is called whenever the shards owned by the
+	// member change. Calls are sequential.
+	OnRebalance func(Rebalance)
+
+	// MetricsProvider receives coordination metrics, prefixed with
+	// "sharder.ownership.". Defaults to discarding them.
+	MetricsProvider xmetrics.Provider
+}
+
+// Coordinator assigns shards to members. A Coordinator is a cmdutil.Server:
+// Run heartbeats until Stop is called.
+type Coordinator struct {
+	cfg Config
+
+	mu    sync.RWMutex
+	owned map[string]bool
+
+	done chan struct{}
+	once sync.Once
+
+	heartbeatFailures metrics.Counter
+	rebalances        metrics.Counter
+	ownedShards       metrics.Gauge
+	members           metrics.Gauge
+}
+
+// New returns a Coordinator configured by cfg.
+func New(cfg Config) (*Coordinator, error) {
+	switch {
+	case cfg.Member == "":
+		return nil, errors.New("ownership: missing Member")
+	case cfg.Membership == nil:
+		return nil, errors.New("ownership: missing Membership")
+	case cfg.Sharder == nil:
+		return nil, errors.New("ownership: missing Sharder")
+	}
+
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = DefaultLeaseTTL
+	}
+	if cfg.HeartbeatInterval <= 0 || cfg.HeartbeatInterval >= cfg.LeaseTTL {
+		cfg.HeartbeatInterval = cfg.LeaseTTL / 3
+	}
+	if cfg.MetricsProvider == nil {
+		cfg.MetricsProvider = discard.New()
+	}
+
+	p := cfg.MetricsProvider
+	return &Coordinator{
+		cfg:               cfg,
+		owned:             make(map[string]bool),
+		done:              make(chan struct{}),
+		heartbeatFailures: p.NewCounter("sharder.ownership.heartbeat.failures"),
+		rebalances:        p.NewCounter("sharder.ownership.rebalances"),
+		ownedShards:       p.NewGauge("sharder.ownership.shards.owned"),
+		members:           p.NewGauge("sharder.ownership.members"),
+	}, nil
+}
+
+// Owns reports whether the member owns the named shard.
+func (c *Coordinator) Owns(shard string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.owned[shard]
+}
+
+// OwnsKey reports whether the member owns the shard of key.
+func (c *Coordinator) OwnsKey(key string) bool {
+	return c.Owns(c.cfg.Sharder.Shard(key))
+}
+
+// Owned returns the shards owned by the member, sorted.
+func (c *Coordinator) Owned() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return sortedKeys(c.owned)
+}
+
+// Run heartbeats and reassigns shards every heartbeat interval until Stop is
+// called. On stopping, the member releases its shards and leaves the group.
+func (c *Coordinator) Run() error {
+	tick := time.NewTicker(c.cfg.HeartbeatInterval)
+	defer tick.Stop()
+
+	// shards are released a heartbeat before the lease may expire, as other
+	// members take them over once it has
+	releaseAfter := c.cfg.LeaseTTL - c.cfg.HeartbeatInterval
+
+	lastHeartbeat := time.Now()
+	for {
+		start := time.Now()
+		if err := c.heartbeat(); err != nil {
+			c.heartbeatFailures.Add(1)
+			if time.Since(lastHeartbeat) >= releaseAfter {
+				c.assign(nil)
+			}
+		} else {
+			lastHeartbeat = start
+		}
+
+		select {
+		case <-tick.C:
+		case <-c.done:
+			c.assign(nil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.cfg.HeartbeatInterval)
+			defer cancel()
+			return errors.Wrap(c.cfg.Membership.Leave(ctx, c.cfg.Member), "leaving group")
+		}
+	}
+}
+
+// Stop Run, releasing the member's shards.
+func (c *Coordinator) Stop(error) {
+	c.once.Do(func() { close(c.done) })
+}
+
+// heartbeat renews the member's lease and reassigns shards to the live
+// members.
+func (c *Coordinator) heartbeat() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.HeartbeatInterval)
+	defer cancel()
+
+	if err := c.cfg.Membership.Heartbeat(ctx, c.cfg.Member, c.cfg.LeaseTTL); err != nil {
+		return errors.Wrap(err, "renewing lease")
+	}
+
+	members, err := c.cfg.Membership.Members(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing members")
+	}
+
+	c.assign(members)
+	return nil
+}
+
+// assign updates the shards owned by the member, given the live members.
+func (c *Coordinator) assign(members []string) {
+	c.members.Set(float64(len(members)))
+
+	owned := make(map[string]bool)
+	if contains(members, c.cfg.Member) {
+		shards := make([]sharder.Shard, len(members))
+		for i, m := range members {
+			shards[i] = sharder.Shard{Name: m}
+		}
+		s := sharder.NewWithShards(shards, sharder.WithLockFreeHasher(), sharder.WithRendezvousHashing())
+
+		for _, sh := range c.cfg.Sharder.Shards() {
+			if s.Shard(sh.Name) == c.cfg.Member {
+				owned[sh.Name] = true
+			}
+		}
+	}
+
+	c.mu.Lock()
+	var acquired, released []string
+	for sh := range owned {
+		if !c.owned[sh] {
+			acquired = append(acquired, sh)
+		}
+	}
+	for sh := range c.owned {
+		if !owned[sh] {
+			released = append(released, sh)
+		}
+	}
+	c.owned = owned
+	c.mu.Unlock()
+
+	c.ownedShards.Set(float64(len(owned)))
+	if len(acquired) == 0 && len(released) == 0 {
+		return
+	}
+
+	c.rebalances.Add(1)
+	if c.cfg.OnRebalance != nil {
+		sort.Strings(acquired)
+		sort.Strings(released)
+		c.cfg.OnRebalance(Rebalance{
+			Members:  members,
+			Owned:    sortedKeys(owned),
+			Acquired: acquired,
+			Released: released,
+		})
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
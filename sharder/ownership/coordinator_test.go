@@ -0,0 +1,238 @@
+package ownership
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+	"github.com/heroku/x/sharder"
+)
+
+func newCoordinator(t *testing.T, member string, m Membership, s *sharder.Sharder, onRebalance func(Rebalance)) *Coordinator {
+	t.Helper()
+
+	c, err := New(Config{
+		Member:            member,
+		Membership:        m,
+		Sharder:           s,
+		LeaseTTL:          500 * time.Millisecond,
+		HeartbeatInterval: 10 * time.Millisecond,
+		OnRebalance:       onRebalance,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// run runs c until the test ends or the returned function is called.
+func run(t *testing.T, c *Coordinator) func() {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- c.Run() }()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			c.Stop(nil)
+			if err := <-done; err != nil {
+				t.Errorf("run: %v", err)
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// exclusive reports whether every shard of s is owned by exactly one of cs.
+func exclusive(s *sharder.Sharder, cs ...*Coordinator) bool {
+	for _, sh := range s.Shards() {
+		var owners int
+		for _, c := range cs {
+			if c.Owns(sh.Name) {
+				owners++
+			}
+		}
+		if owners != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCoordinator(t *testing.T) {
+	m := NewMemoryMembership()
+	s := sharder.New(12, sharder.WithConsistentHashing(0))
+
+	a := newCoordinator(t, "web.1", m, s, nil)
+	run(t, a)
+	eventually(t, func() bool { return len(a.Owned()) == 12 })
+
+	b := newCoordinator(t, "web.2", m, s, nil)
+	c := newCoordinator(t, "web.3", m, s, nil)
+	run(t, b)
+	stopC := run(t, c)
+	eventually(t, func() bool {
+		return exclusive(s, a, b, c) && len(a.Owned()) > 0 && len(b.Owned()) > 0 && len(c.Owned()) > 0
+	})
+
+	key := "app-123"
+	var owners int
+	for _, co := range []*Coordinator{a, b, c} {
+		if co.OwnsKey(key) {
+			owners++
+		}
+	}
+	if owners != 1 {
+		t.Fatalf("want key owned by 1 member, got %d", owners)
+	}
+
+	// shards of a stopped member are taken over, and only those move
+	ownedA, ownedB := a.Owned(), b.Owned()
+	stopC()
+	if got := c.Owned(); len(got) != 0 {
+		t.Fatalf("want stopped member to release its shards, got %v", got)
+	}
+	eventually(t, func() bool { return exclusive(s, a, b) })
+
+	for _, sh := range append(ownedA, ownedB...) {
+		if !a.Owns(sh) && !b.Owns(sh) {
+			t.Fatalf("want shard %s to stay with its owner", sh)
+		}
+	}
+	for _, sh := range ownedA {
+		if !a.Owns(sh) {
+			t.Fatalf("want web.1 to keep shard %s", sh)
+		}
+	}
+}
+
+func TestCoordinatorRebalanceCallbacks(t *testing.T) {
+	m := NewMemoryMembership()
+	s := sharder.New(6)
+
+	var (
+		mu     sync.Mutex
+		events []Rebalance
+	)
+	a := newCoordinator(t, "web.1", m, s, func(r Rebalance) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, r)
+	})
+	stop := run(t, a)
+
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 1
+	})
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(events) != 2 {
+		t.Fatalf("want 2 rebalances, got %+v", events)
+	}
+	if got := events[0]; len(got.Acquired) != 6 || len(got.Owned) != 6 || len(got.Released) != 0 || len(got.Members) != 1 {
+		t.Errorf("want all shards acquired, got %+v", got)
+	}
+	if got := events[1]; len(got.Released) != 6 || len(got.Owned) != 0 || len(got.Members) != 0 {
+		t.Errorf("want all shards released, got %+v", got)
+	}
+}
+
+type failingMembership struct {
+	Membership
+
+	mu   sync.Mutex
+	fail bool
+}
+
+func (f *failingMembership) setFail(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fail
+}
+
+func (f *failingMembership) Heartbeat(ctx context.Context, member string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return errors.New("unavailable")
+	}
+	return f.Membership.Heartbeat(ctx, member, ttl)
+}
+
+func TestCoordinatorLeaseLost(t *testing.T) {
+	m := &failingMembership{Membership: NewMemoryMembership()}
+	s := sharder.New(4)
+	p := testmetrics.NewProvider(t)
+
+	c, err := New(Config{
+		Member:            "web.1",
+		Membership:        m,
+		Sharder:           s,
+		LeaseTTL:          300 * time.Millisecond,
+		HeartbeatInterval: 50 * time.Millisecond,
+		MetricsProvider:   p,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, c)
+	eventually(t, func() bool { return len(c.Owned()) == 4 })
+
+	// shards are kept through transient failures...
+	m.setFail(true)
+	time.Sleep(100 * time.Millisecond)
+	if got := c.Owned(); len(got) != 4 {
+		t.Fatalf("want shards kept before the lease expires, got %v", got)
+	}
+
+	// ...but released before the lease expires
+	eventually(t, func() bool { return len(c.Owned()) == 0 })
+	if members, _ := m.Members(context.Background()); !contains(members, "web.1") {
+		t.Fatal("want shards released before the lease expired")
+	}
+	p.CheckMetric(testmetrics.KindCounter, "sharder.ownership.heartbeat.failures", testmetrics.NonZero())
+
+	m.setFail(false)
+	eventually(t, func() bool { return len(c.Owned()) == 4 })
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	m := NewMemoryMembership()
+	s := sharder.New(1)
+
+	tests := map[string]Config{
+		"missing member":     {Membership: m, Sharder: s},
+		"missing membership": {Member: "web.1", Sharder: s},
+		"missing sharder":    {Member: "web.1", Membership: m},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(cfg); err == nil {
+				t.Fatal("want error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package ownership
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// Membership tracks the live members of a group through leases: a member is
+// live until its lease expires, unless it renews it with another heartbeat.
+type Membership interface {
+	// Heartbeat registers member, or renews its lease, for ttl.
+	Heartbeat(ctx context.Context, member string, ttl time.Duration) error
+
+	// Leave releases member's lease.
+	Leave(ctx context.Context, member string) error
+
+	// Members returns the members with unexpired leases, sorted.
+	Members(ctx context.Context) ([]string, error)
+}
+
+var (
+	_ Membership = (*MemoryMembership)(nil)
+	_ Membership = (*RedisMembership)(nil)
+)
+
+// NewMemoryMembership returns a Membership for members within a single
+// process, e.g. for tests.
+func NewMemoryMembership() *MemoryMembership {
+	return &MemoryMembership{
+		leases: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// MemoryMembership is an in-memory Membership. It is safe for concurrent
+// use.
+type MemoryMembership struct {
+	mu     sync.Mutex
+	leases map[string]time.Time
+	now    func() time.Time
+}
+
+// Heartbeat implements Membership.
+func (m *MemoryMembership) Heartbeat(_ context.Context, member string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.leases[member] = m.now().Add(ttl)
+	return nil
+}
+
+// Leave implements Membership.
+func (m *MemoryMembership) Leave(_ context.Context, member string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.leases, member)
+	return nil
+}
+
+// Members implements Membership.
+func (m *MemoryMembership) Members(_ context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	members := make([]string, 0, len(m.leases))
+	for member, expiry := range m.leases {
+		if expiry.After(now) {
+			members = append(members, member)
+		} else {
+			delete(m.leases, member)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// NewRedisMembership returns a Membership storing leases in a sorted set at
+// key, scored by their expiry time. Lease expiry is based on the members'
+// clocks, so lease TTLs should be well above the clock skew between them.
+func NewRedisMembership(pool *redis.Pool, key string) *RedisMembership {
+	return &RedisMembership{pool: pool, key: key, now: time.Now}
+}
+
+// heartbeatScript registers a member and expires the set atomically, so that
+// the set can't be left without an expiry.
+var heartbeatScript = redis.NewScript(1, `
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+return redis.call("PEXPIRE", KEYS[1], ARGV[3])`)
+
+// RedisMembership is a Membership backed by Redis.
+type RedisMembership struct {
+	pool *redis.Pool
+	key  string
+	now  func() time.Time
+}
+
+// Heartbeat implements Membership. The whole set expires if no member
+// heartbeats within ttl.
+func (m *RedisMembership) Heartbeat(ctx context.Context, member string, ttl time.Duration) error {
+	conn, err := m.pool.GetContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting redis connection")
+	}
+	defer conn.Close()
+
+	_, err = heartbeatScript.DoContext(ctx, conn, m.key, m.now().Add(ttl).UnixMilli(), member, ttl.Milliseconds())
+	return errors.Wrap(err, "registering member")
+}
+
+// Leave implements Membership.
+func (m *RedisMembership) Leave(ctx context.Context, member string) error {
+	conn, err := m.pool.GetContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting redis connection")
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "ZREM", m.key, member)
+	return errors.Wrap(err, "removing member")
+}
+
+// Members implements Membership. Expired leases are pruned.
+func (m *RedisMembership) Members(ctx context.Context) ([]string, error) {
+	conn, err := m.pool.GetContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting redis connection")
+	}
+	defer conn.Close()
+
+	now := m.now().UnixMilli()
+	if _, err := redis.DoContext(conn, ctx, "ZREMRANGEBYSCORE", m.key, "-inf", now); err != nil {
+		return nil, errors.Wrap(err, "pruning members")
+	}
+
+	members, err := redis.Strings(redis.DoContext(conn, ctx, "ZRANGEBYSCORE", m.key, "("+strconv.FormatInt(now, 10), "+inf"))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing members")
+	}
+	sort.Strings(members)
+	return members, nil
+}
@@ -0,0 +1,141 @@
+package ownership
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/heroku/x/testing/testenv"
+)
+
+func TestMemoryMembership(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+
+	m := NewMemoryMembership()
+	m.now = func() time.Time { return now }
+
+	for member, ttl := range map[string]time.Duration{"web.1": 10 * time.Second, "web.2": 5 * time.Second, "web.3": 10 * time.Second} {
+		if err := m.Heartbeat(ctx, member, ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Leave(ctx, "web.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		after time.Duration
+		want  []string
+	}{
+		{after: 0, want: []string{"web.1", "web.2"}},
+		{after: 5 * time.Second, want: []string{"web.1"}},
+		{after: 10 * time.Second, want: []string{}},
+	}
+
+	start := now
+	for _, test := range tests {
+		now = start.Add(test.after)
+		got, err := m.Members(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("after %v: want members %v, got %v", test.after, test.want, got)
+		}
+	}
+}
+
+func TestRedisMembership(t *testing.T) {
+	ctx := context.Background()
+	conn, pool := testenv.NewMockRedisPool(t)
+
+	m := NewRedisMembership(pool, "workers")
+	m.now = func() time.Time { return time.UnixMilli(1000) }
+
+	conn.Command("EVALSHA", heartbeatScript.Hash(), 1, "workers", int64(16000), "web.1", int64(15000)).Expect(int64(1))
+	conn.Command("ZREMRANGEBYSCORE", "workers", "-inf", int64(1000)).Expect(int64(1))
+	conn.Command("ZRANGEBYSCORE", "workers", "(1000", "+inf").ExpectStringSlice("web.2", "web.1")
+	conn.Command("ZREM", "workers", "web.1").Expect(int64(1))
+
+	if err := m.Heartbeat(ctx, "web.1", 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := m.Members(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"web.1", "web.2"}; !reflect.DeepEqual(members, want) {
+		t.Fatalf("want members %v, got %v", want, members)
+	}
+
+	if err := m.Leave(ctx, "web.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisMembershipError(t *testing.T) {
+	conn, pool := testenv.NewMockRedisPool(t)
+	m := NewRedisMembership(pool, "workers")
+
+	conn.GenericCommand("EVALSHA").ExpectError(errors.New("READONLY"))
+
+	if err := m.Heartbeat(context.Background(), "web.1", time.Second); err == nil {
+		t.Fatal("want error, got nil")
+	}
+}
+
+func TestRedisMembershipScripts(t *testing.T) {
+	ctx := context.Background()
+	pool := testenv.NewReachableRedisPool(t)
+
+	key := "ownership-test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() {
+		conn := pool.Get()
+		defer conn.Close()
+		if _, err := conn.Do("DEL", key); err != nil {
+			t.Error(err)
+		}
+	})
+
+	now := time.Now()
+	m := NewRedisMembership(pool, key)
+	m.now = func() time.Time { return now }
+
+	if err := m.Heartbeat(ctx, "web.1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Heartbeat(ctx, "web.2", 2*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// the set expires along with the latest lease
+	conn := pool.Get()
+	defer conn.Close()
+	pttl, err := redis.Int64(conn.Do("PTTL", key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pttl <= time.Minute.Milliseconds() || pttl > (2*time.Minute).Milliseconds() {
+		t.Fatalf("want the set to expire in about 2m, got %dms", pttl)
+	}
+
+	now = now.Add(90 * time.Second)
+	members, err := m.Members(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"web.2"}; !reflect.DeepEqual(members, want) {
+		t.Fatalf("want members %v, got %v", want, members)
+	}
+}
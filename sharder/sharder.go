@@ -3,7 +3,9 @@ package sharder
 import (
 	"hash"
 	"hash/fnv"
+	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 // Hasher is the common interface to hash a given key.
@@ -11,7 +13,9 @@ type Hasher interface {
 	Hash(key string) uint32
 }
 
-// Opt is used to customer the Sharder's Hasher.
+// Opt is used to customize the Sharder's Hasher and hashing strategy. The
+// strategies are fixed: WithModuloHashing, WithConsistentHashing or
+// WithRendezvousHashing.
 type Opt func(*Sharder)
 
 // WithHasher allows for custom Hasher implementations.
@@ -64,38 +68,140 @@ func (f *lockingHasher) Hash(key string) uint32 {
 	return f.hasher.Sum32()
 }
 
-// New returns a new Sharder with the specified number of shards.
+// New returns a new Sharder with the specified number of shards, named "0"
+// to "total-1".
 func New(total int, opts ...Opt) *Sharder {
 	if total < 1 {
 		panic("trying to create Sharder where total < 1")
 	}
 
-	if len(opts) == 0 {
-		opts = append(opts, WithLockingHasher())
+	shards := make([]Shard, total)
+	for i := range shards {
+		shards[i] = Shard{Name: strconv.Itoa(i), Weight: 1}
 	}
 
-	s := &Sharder{
-		total: total,
+	return newSharder(shards, opts)
+}
+
+// NewWithShards returns a new Sharder with the specified named shards.
+func NewWithShards(shards []Shard, opts ...Opt) *Sharder {
+	if len(shards) < 1 {
+		panic("trying to create Sharder without shards")
 	}
 
+	return newSharder(dedupe(nil, shards), opts)
+}
+
+func newSharder(shards []Shard, opts []Opt) *Sharder {
+	s := &Sharder{}
+
 	for _, opt := range opts {
 		opt(s)
 	}
 
+	// default to a locking hasher and modulo hashing
+	if s.factory == nil {
+		WithLockingHasher()(s)
+	}
+	if s.newStrategy == nil {
+		WithModuloHashing()(s)
+	}
+	s.build(shards)
+
 	return s
 }
 
-// Sharder determines the shard number for a key.
+// Sharder determines the shard for a key.
 type Sharder struct {
-	total   int
-	factory Hasher
+	factory     Hasher
+	newStrategy func() strategy
+
+	mu    sync.Mutex // serializes changes to the shards
+	state atomic.Pointer[sharderState]
+}
+
+// sharderState is an immutable set of shards, along with the strategy built
+// for them. Changing the shards swaps it, so that locating keys doesn't lock.
+type sharderState struct {
+	shards   []Shard
+	strategy strategy
 }
 
-// Index returns a shard index for the given key. The index is in the range 0..total exclusive.
+// build swaps the state for shards.
+func (s *Sharder) build(shards []Shard) {
+	st := s.newStrategy()
+	st.build(shards, s.factory)
+	s.state.Store(&sharderState{shards: shards, strategy: st})
+}
+
+// Index returns a shard index for the given key: the position of its shard
+// in Shards. The index is in the range 0..len(Shards()) exclusive.
+//
+// As removing shards changes the position of those after them, use Shard to
+// track keys across changes to the shards.
 func (s *Sharder) Index(key string) int {
-	i := int(s.factory.Hash(key)) % s.total
-	if i < 0 {
-		i = -i
+	return s.state.Load().strategy.locate(key)
+}
+
+// Shard returns the name of the shard for the given key.
+func (s *Sharder) Shard(key string) string {
+	st := s.state.Load()
+	return st.shards[st.strategy.locate(key)].Name
+}
+
+// Shards returns the shards, in the order they were added.
+func (s *Sharder) Shards() []Shard {
+	return append([]Shard(nil), s.state.Load().shards...)
+}
+
+// Add adds shards, or updates the weight of existing ones.
+func (s *Sharder) Add(shards ...Shard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.build(dedupe(s.state.Load().shards, shards))
+}
+
+// Remove removes the named shards. Unknown names are ignored. It panics if no
+// shards would be left.
+func (s *Sharder) Remove(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make(map[string]bool, len(names))
+	for _, name := range names {
+		removed[name] = true
+	}
+
+	shards := s.state.Load().shards
+	kept := make([]Shard, 0, len(shards))
+	for _, sh := range shards {
+		if !removed[sh.Name] {
+			kept = append(kept, sh)
+		}
+	}
+	if len(kept) == 0 {
+		panic("trying to remove every shard from Sharder")
+	}
+
+	s.build(kept)
+}
+
+// dedupe returns shards with more appended, replacing the weight of shards
+// with the same name.
+func dedupe(shards, more []Shard) []Shard {
+	out := append(make([]Shard, 0, len(shards)+len(more)), shards...)
+	for _, sh := range more {
+		i := 0
+		for ; i < len(out); i++ {
+			if out[i].Name == sh.Name {
+				out[i].Weight = sh.Weight
+				break
+			}
+		}
+		if i == len(out) {
+			out = append(out, sh)
+		}
 	}
-	return i
+	return out
 }
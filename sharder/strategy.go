@@ -0,0 +1,174 @@
+package sharder
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the default number of virtual nodes per unit of weight
+// placed on the ring by WithConsistentHashing.
+const DefaultReplicas = 100
+
+// Shard is a named shard. Keys are distributed across shards in proportion
+// to their weight; a weight below 1 is treated as 1.
+type Shard struct {
+	Name   string
+	Weight int
+}
+
+func (sh Shard) weight() int {
+	if sh.Weight < 1 {
+		return 1
+	}
+	return sh.Weight
+}
+
+// strategy maps keys onto shards. It is internal to the package; callers
+// pick one of the strategies with its Opt. A new strategy is built with the
+// Sharder's shards whenever they change, and locate returns the index of the
+// key's shard.
+type strategy interface {
+	build(shards []Shard, h Hasher)
+	locate(key string) int
+}
+
+// WithModuloHashing is the default strategy, mapping keys onto shards with
+// hash % total weight. Changing the shards remaps most keys.
+func WithModuloHashing() Opt {
+	return func(s *Sharder) {
+		s.newStrategy = func() strategy { return &modulo{} }
+	}
+}
+
+// WithConsistentHashing maps keys onto shards using a consistent hash ring,
+// with replicas virtual nodes per unit of weight of each shard. If replicas
+// is below 1, DefaultReplicas is used.
+//
+// Adding or removing a shard only moves the keys it gains or loses,
+// roughly 1/n of all keys with n shards. More replicas spread keys more
+// evenly, at the cost of memory and slower changes to the shards.
+func WithConsistentHashing(replicas int) Opt {
+	if replicas < 1 {
+		replicas = DefaultReplicas
+	}
+	return func(s *Sharder) {
+		s.newStrategy = func() strategy { return &ring{replicas: replicas} }
+	}
+}
+
+// WithRendezvousHashing maps keys onto shards using weighted rendezvous, or
+// highest random weight, hashing: every shard is scored for the key, and the
+// highest score wins.
+//
+// Like consistent hashing, adding or removing a shard only moves the keys it
+// gains or loses, and keys are spread evenly without virtual nodes. Locating
+// a key is O(n) with n shards, so it suits a modest number of shards.
+func WithRendezvousHashing() Opt {
+	return func(s *Sharder) {
+		s.newStrategy = func() strategy { return &rendezvous{} }
+	}
+}
+
+type modulo struct {
+	hasher Hasher
+	slots  []int // shard index of each unit of weight
+}
+
+func (m *modulo) build(shards []Shard, h Hasher) {
+	m.hasher = h
+	m.slots = m.slots[:0]
+	for i, sh := range shards {
+		for w := 0; w < sh.weight(); w++ {
+			m.slots = append(m.slots, i)
+		}
+	}
+}
+
+func (m *modulo) locate(key string) int {
+	i := int(m.hasher.Hash(key)) % len(m.slots)
+	if i < 0 {
+		i = -i
+	}
+	return m.slots[i]
+}
+
+type ring struct {
+	replicas int
+	hasher   Hasher
+	points   []uint32
+	owners   map[uint32]int // shard index by point
+}
+
+func (r *ring) build(shards []Shard, h Hasher) {
+	r.hasher = h
+	r.points = r.points[:0]
+	r.owners = make(map[uint32]int)
+
+	for i, sh := range shards {
+		for v := 0; v < sh.weight()*r.replicas; v++ {
+			p := mix(h.Hash(sh.Name + "#" + strconv.Itoa(v)))
+			if owner, ok := r.owners[p]; !ok {
+				r.points = append(r.points, p)
+			} else if shards[owner].Name < sh.Name {
+				// resolve collisions the same way whatever the order
+				// shards were added in
+				continue
+			}
+			r.owners[p] = i
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+func (r *ring) locate(key string) int {
+	h := mix(r.hasher.Hash(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+type rendezvous struct {
+	hasher  Hasher
+	seeds   []uint32
+	weights []float64
+}
+
+func (r *rendezvous) build(shards []Shard, h Hasher) {
+	r.hasher = h
+	r.seeds = r.seeds[:0]
+	r.weights = r.weights[:0]
+	for _, sh := range shards {
+		r.seeds = append(r.seeds, h.Hash(sh.Name))
+		r.weights = append(r.weights, float64(sh.weight()))
+	}
+}
+
+func (r *rendezvous) locate(key string) int {
+	h := r.hasher.Hash(key)
+
+	best, bestScore := 0, math.Inf(-1)
+	for i, seed := range r.seeds {
+		// u is uniform in (0, 1), so -w/ln(u) picks shard i with
+		// probability proportional to its weight w
+		u := (float64(mix(h^seed)) + 0.5) / (1 << 32)
+		if score := -r.weights[i] / math.Log(u); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// mix is the finalizer of MurmurHash3, spreading hashes which differ in few
+// bits, such as the FNV hashes of similar keys, over the whole range.
+func mix(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,230 @@
+package sharder_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/heroku/x/sharder"
+)
+
+const numKeys = 20000
+
+func keys() []string {
+	ks := make([]string, numKeys)
+	for i := range ks {
+		ks[i] = "key-" + strconv.Itoa(i)
+	}
+	return ks
+}
+
+func assign(s *sharder.Sharder, ks []string) map[string]string {
+	m := make(map[string]string, len(ks))
+	for _, k := range ks {
+		m[k] = s.Shard(k)
+	}
+	return m
+}
+
+// moved returns the fraction of keys assigned to a different shard.
+func moved(before, after map[string]string) float64 {
+	var n int
+	for k, sh := range before {
+		if after[k] != sh {
+			n++
+		}
+	}
+	return float64(n) / float64(len(before))
+}
+
+func namedShards(n int) []sharder.Shard {
+	shards := make([]sharder.Shard, n)
+	for i := range shards {
+		shards[i] = sharder.Shard{Name: "worker-" + strconv.Itoa(i)}
+	}
+	return shards
+}
+
+var strategies = map[string]sharder.Opt{
+	"modulo":     sharder.WithModuloHashing(),
+	"consistent": sharder.WithConsistentHashing(0),
+	"rendezvous": sharder.WithRendezvousHashing(),
+}
+
+func TestKeyMovementOnAdd(t *testing.T) {
+	// with 10 shards, adding an 11th should ideally move 1/11 of the keys
+	tests := map[string]struct {
+		maxMoved float64
+		minMoved float64
+	}{
+		"modulo":     {minMoved: 0.8, maxMoved: 1},
+		"consistent": {minMoved: 0.05, maxMoved: 0.13},
+		"rendezvous": {minMoved: 0.07, maxMoved: 0.11},
+	}
+
+	ks := keys()
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := sharder.NewWithShards(namedShards(10), strategies[name])
+			before := assign(s, ks)
+
+			s.Add(sharder.Shard{Name: "worker-10"})
+			after := assign(s, ks)
+
+			got := moved(before, after)
+			t.Logf("%s moved %.1f%% of keys", name, got*100)
+			if got < test.minMoved || got > test.maxMoved {
+				t.Fatalf("want %v to %v of keys moved, got %v", test.minMoved, test.maxMoved, got)
+			}
+
+			if name == "modulo" {
+				return
+			}
+			for k, sh := range after {
+				if sh != before[k] && sh != "worker-10" {
+					t.Fatalf("want keys to only move to the new shard, %s moved from %s to %s", k, before[k], sh)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyMovementOnRemove(t *testing.T) {
+	ks := keys()
+	for _, name := range []string{"consistent", "rendezvous"} {
+		t.Run(name, func(t *testing.T) {
+			s := sharder.NewWithShards(namedShards(10), strategies[name])
+			before := assign(s, ks)
+
+			s.Remove("worker-3")
+			after := assign(s, ks)
+
+			for k, sh := range before {
+				if sh != "worker-3" && after[k] != sh {
+					t.Fatalf("want only keys of the removed shard to move, %s moved from %s to %s", k, sh, after[k])
+				}
+				if after[k] == "worker-3" {
+					t.Fatalf("want no keys on the removed shard, got %s", k)
+				}
+			}
+
+			// adding the shard back restores the original assignment
+			s.Add(sharder.Shard{Name: "worker-3"})
+			if got := moved(before, assign(s, ks)); got != 0 {
+				t.Fatalf("want original assignment restored, %v of keys moved", got)
+			}
+		})
+	}
+}
+
+func TestBalance(t *testing.T) {
+	ks := keys()
+	for name, opt := range strategies {
+		t.Run(name, func(t *testing.T) {
+			s := sharder.NewWithShards(namedShards(10), opt)
+
+			counts := make(map[string]int)
+			for _, sh := range assign(s, ks) {
+				counts[sh]++
+			}
+
+			mean := float64(numKeys) / 10
+			for sh, n := range counts {
+				if dev := (float64(n) - mean) / mean; dev < -0.25 || dev > 0.25 {
+					t.Errorf("want %s within 25%% of %v keys, got %d", sh, mean, n)
+				}
+			}
+		})
+	}
+}
+
+func TestWeights(t *testing.T) {
+	ks := keys()
+	for name, opt := range strategies {
+		t.Run(name, func(t *testing.T) {
+			s := sharder.NewWithShards([]sharder.Shard{
+				{Name: "small", Weight: 1},
+				{Name: "large", Weight: 3},
+			}, opt)
+
+			counts := make(map[string]int)
+			for _, sh := range assign(s, ks) {
+				counts[sh]++
+			}
+
+			if got := float64(counts["large"]) / float64(numKeys); got < 0.7 || got > 0.8 {
+				t.Fatalf("want about 75%% of keys on the large shard, got %v", got)
+			}
+		})
+	}
+}
+
+func TestIndexMatchesShards(t *testing.T) {
+	for name, opt := range strategies {
+		t.Run(name, func(t *testing.T) {
+			s := sharder.New(5, opt)
+			s.Add(sharder.Shard{Name: "extra", Weight: 2})
+			s.Remove("1")
+
+			shards := s.Shards()
+			if len(shards) != 5 {
+				t.Fatalf("want 5 shards, got %v", shards)
+			}
+			for _, k := range keys()[:1000] {
+				i := s.Index(k)
+				if i < 0 || i >= len(shards) {
+					t.Fatalf("want index in range 0..%d, got %d", len(shards), i)
+				}
+				if shards[i].Name != s.Shard(k) {
+					t.Fatalf("want index of shard %s, got %s", s.Shard(k), shards[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentChanges(t *testing.T) {
+	for name, opt := range strategies {
+		t.Run(name, func(t *testing.T) {
+			s := sharder.New(3, opt)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 100; i++ {
+					s.Add(sharder.Shard{Name: "extra"})
+					s.Remove("extra")
+				}
+			}()
+
+			for _, k := range keys()[:5000] {
+				if i := s.Index(k); i < 0 || i > 3 {
+					t.Fatalf("want index in range 0..4, got %d", i)
+				}
+				s.Shard(k)
+			}
+			<-done
+		})
+	}
+}
+
+func TestRemoveEveryShardPanics(t *testing.T) {
+	defer func() {
+		if x := recover(); x == nil {
+			t.Fatal("wanted Remove to panic when removing every shard")
+		}
+	}()
+
+	s := sharder.New(2)
+	s.Remove("0", "1")
+}
+
+func TestDefaultIsModulo(t *testing.T) {
+	s := sharder.New(10, sharder.WithLockFreeHasher())
+	m := sharder.New(10, sharder.WithLockFreeHasher(), sharder.WithModuloHashing())
+
+	for _, k := range keys()[:1000] {
+		if s.Index(k) != m.Index(k) {
+			t.Fatalf("want default strategy to be modulo hashing for %s", k)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package testenv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+)
+
+// NewReachableRedisPool returns a redis pool like NewRedisPool, skipping the
+// test if Redis can't be reached. The pool is closed when the test ends.
+func NewReachableRedisPool(t testing.TB) *redis.Pool {
+	t.Helper()
+
+	pool := NewRedisPool(t)
+	t.Cleanup(func() { pool.Close() })
+
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		t.Skipf("redis unavailable: %v", err)
+	}
+
+	return pool
+}
+
+// NewMockRedisPool returns a redis pool whose connections are backed by a
+// single mock connection, which also supports the context aware methods.
+func NewMockRedisPool(t testing.TB) (*redigomock.Conn, *redis.Pool) {
+	t.Helper()
+
+	conn := redigomock.NewConn()
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return contextConn{conn}, nil }}
+	t.Cleanup(func() { pool.Close() })
+
+	return conn, pool
+}
+
+// contextConn adds context support to redigomock connections.
+type contextConn struct {
+	*redigomock.Conn
+}
+
+func (c contextConn) DoContext(_ context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	return c.Do(cmd, args...)
+}
+
+func (c contextConn) ReceiveContext(context.Context) (interface{}, error) {
+	return c.Receive()
+}
@@ -0,0 +1,252 @@
+package tickgroup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule returns the activation times of a task.
+type Schedule interface {
+	// Next returns the first activation time strictly after t, or the zero
+	// time if there is none.
+	Next(t time.Time) time.Time
+}
+
+var _ Schedule = (*CronSchedule)(nil)
+
+// CronSchedule is a Schedule parsed from a cron spec.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// domDowOr is set when both the day of month and day of week are
+	// restricted, in which case matching either one is enough, as in cron.
+	domDowOr bool
+
+	loc *time.Location
+}
+
+type cronField struct {
+	name      string
+	low, high int
+	names     map[string]int
+}
+
+var (
+	minuteField = cronField{name: "minute", low: 0, high: 59}
+	hourField   = cronField{name: "hour", low: 0, high: 23}
+	domField    = cronField{name: "day of month", low: 1, high: 31}
+	monthField  = cronField{name: "month", low: 1, high: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is also Sunday
+	dowField = cronField{name: "day of week", low: 0, high: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard 5-field cron spec:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Fields accept *, values, ranges (1-5), steps (*/15, 0-30/10) and
+// comma-separated lists of those. Months and days of week may be given by
+// their 3-letter English names. Like in cron, a day matches when either the
+// day of month or day of week matches if both are restricted.
+//
+// The descriptors @yearly (or @annually), @monthly, @weekly, @daily (or
+// @midnight) and @hourly may be used instead of the fields.
+//
+// Schedules are in UTC, unless the spec starts with a CRON_TZ= or TZ= prefix
+// naming an IANA time zone, e.g. "CRON_TZ=Europe/Paris 0 3 * * *".
+func ParseCron(spec string) (*CronSchedule, error) {
+	s := &CronSchedule{loc: time.UTC}
+
+	fields := strings.Fields(spec)
+	if len(fields) > 0 {
+		if tz, ok := cutAnyPrefix(fields[0], "CRON_TZ=", "TZ="); ok {
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return nil, fmt.Errorf("cron spec %q: %w", spec, err)
+			}
+			s.loc = loc
+			fields = fields[1:]
+		}
+	}
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		d, ok := cronDescriptors[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("cron spec %q: unknown descriptor %s", spec, fields[0])
+		}
+		fields = strings.Fields(d)
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron spec %q: want 5 fields, got %d", spec, len(fields))
+	}
+
+	var err error
+	for i, f := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&s.minute, minuteField},
+		{&s.hour, hourField},
+		{&s.dom, domField},
+		{&s.month, monthField},
+		{&s.dow, dowField},
+	} {
+		if *f.bits, err = f.field.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("cron spec %q: %w", spec, err)
+		}
+	}
+
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domDowOr = !strings.HasPrefix(fields[2], "*") && !strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// MustParseCron is like ParseCron but panics if spec is invalid.
+func MustParseCron(spec string) *CronSchedule {
+	s, err := ParseCron(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Location returns the time zone of the schedule.
+func (s *CronSchedule) Location() *time.Location {
+	return s.loc
+}
+
+// Next implements Schedule. Activation times skipped by a daylight saving
+// time transition, e.g. 02:30 when clocks jump from 02:00 to 03:00, are
+// skipped, and repeated ones only activate once.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	next := s.next(t)
+	// the wall clock time after t may be earlier than t within a repeated
+	// hour, so keep going until past it
+	for !next.IsZero() && !next.After(t) {
+		next = s.next(next)
+	}
+	return next
+}
+
+// next returns the first activation time after the wall clock time of t.
+func (s *CronSchedule) next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, s.loc)
+
+	// every schedule activates at least once in 8 years, e.g. on Feb 29 and
+	// a given day of week
+	limit := t.Year() + 8
+	for t.Year() <= limit {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		case !has(s.minute, t.Minute()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, s.loc)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domDowOr {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// parse returns the values of the field in spec as a bit set.
+func (f cronField) parse(spec string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(spec, ",") {
+		rng, stepSpec, hasStep := strings.Cut(part, "/")
+
+		var lo, hi int
+		switch {
+		case rng == "*":
+			lo, hi = f.low, f.high
+		case strings.Contains(rng, "-"):
+			los, his, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(los); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(his); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				hi = f.high
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid %s range %s", f.name, part)
+		}
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepSpec); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid %s step %s", f.name, part)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.low || v > f.high {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	return v, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func cutAnyPrefix(s string, prefixes ...string) (string, bool) {
+	for _, p := range prefixes {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			return rest, true
+		}
+	}
+	return "", false
+}
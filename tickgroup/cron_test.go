@@ -0,0 +1,327 @@
+package tickgroup
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+)
+
+func TestParseCronNext(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// 2026-03-02 is a Monday
+	from := time.Date(2026, 3, 2, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			spec: "*/15 * * * *",
+			want: []time.Time{
+				time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC),
+				time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC),
+				time.Date(2026, 3, 2, 10, 45, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 3 * * *",
+			want: []time.Time{
+				time.Date(2026, 3, 3, 3, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 4, 3, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "@hourly",
+			want: []time.Time{
+				time.Date(2026, 3, 2, 11, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "@monthly",
+			want: []time.Time{
+				time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "30 9-17/4 * * mon-fri",
+			want: []time.Time{
+				time.Date(2026, 3, 2, 13, 30, 0, 0, time.UTC),
+				time.Date(2026, 3, 2, 17, 30, 0, 0, time.UTC),
+				time.Date(2026, 3, 3, 9, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			// either the 13th or a Friday
+			spec: "0 0 13 * 5",
+			want: []time.Time{
+				time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 0 29 FEB 7",
+			want: []time.Time{
+				time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC),
+			},
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "CRON_TZ=Europe/Paris 0 3 * * *",
+			want: []time.Time{
+				time.Date(2026, 3, 3, 3, 0, 0, 0, paris),
+				time.Date(2026, 3, 4, 3, 0, 0, 0, paris),
+			},
+		},
+		{
+			// clocks jump from 02:00 to 03:00 on 2026-03-29 in Paris
+			spec: "TZ=Europe/Paris 30 2 * * *",
+			from: time.Date(2026, 3, 28, 12, 0, 0, 0, paris),
+			want: []time.Time{
+				time.Date(2026, 3, 28, 2, 30, 0, 0, paris).AddDate(0, 0, 2),
+			},
+		},
+		{
+			// clocks go back from 03:00 to 02:00 on 2026-10-25 in Paris
+			spec: "TZ=Europe/Paris 30 2 * * *",
+			from: time.Date(2026, 10, 24, 12, 0, 0, 0, paris),
+			want: []time.Time{
+				time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC),
+				time.Date(2026, 10, 26, 1, 30, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			s, err := ParseCron(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := test.from
+			if got.IsZero() {
+				got = from
+			}
+			for _, want := range test.want {
+				if got = s.Next(got); !got.Equal(want) {
+					t.Fatalf("want next activation at %v, got %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCronNextRepeatedHour(t *testing.T) {
+	s := MustParseCron("TZ=Europe/Paris */20 * * * *")
+
+	// 02:10 CEST, before the hour is repeated by the switch to CET
+	from := time.Date(2026, 10, 25, 0, 10, 0, 0, time.UTC)
+	want := []time.Time{
+		time.Date(2026, 10, 25, 1, 20, 0, 0, time.UTC),
+		time.Date(2026, 10, 25, 1, 40, 0, 0, time.UTC),
+		time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC),
+	}
+
+	got := from
+	for _, w := range want {
+		if got = s.Next(got); !got.Equal(w) {
+			t.Fatalf("want next activation at %v, got %v", w, got)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@fortnightly",
+		"TZ=Nowhere/Special * * * * *",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("want error parsing %q, got nil", spec)
+		}
+	}
+}
+
+// fakeClock is a Clock whose time only moves when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []fakeTimer
+	waiting chan struct{} // receives when a timer is started
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, waiting: make(chan struct{}, 10)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), c: ch})
+	c.waiting <- struct{}{}
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			timers = append(timers, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = timers
+}
+
+// advanceToLastTimer advances to the last started timer.
+func (c *fakeClock) advanceToLastTimer() {
+	c.mu.Lock()
+	d := c.timers[len(c.timers)-1].at.Sub(c.now)
+	c.mu.Unlock()
+
+	c.Advance(d)
+}
+
+func TestGoCron(t *testing.T) {
+	start := time.Date(2026, 3, 2, 10, 5, 0, 0, time.UTC)
+	s := MustParseCron("*/15 * * * *")
+
+	tests := []struct {
+		name string
+		opts []TaskOption
+		want []time.Time
+	}{
+		{
+			name: "runs late activations",
+			want: []time.Time{
+				time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC),
+				time.Date(2026, 3, 2, 10, 55, 0, 0, time.UTC),
+				time.Date(2026, 3, 2, 11, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "skips late activations",
+			opts: []TaskOption{WithSkipIfRunning()},
+			want: []time.Time{
+				time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC),
+				time.Date(2026, 3, 2, 11, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 2, 11, 15, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := newFakeClock(start)
+			p := testmetrics.NewProvider(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			group := New(ctx)
+
+			var runs []time.Time
+			opts := append([]TaskOption{WithClock(clock), WithMetrics(p, "cleanup")}, test.opts...)
+			group.GoCron(s, func(context.Context) error {
+				runs = append(runs, clock.Now())
+				switch len(runs) {
+				case 1:
+					// overrun the next 2 activations
+					clock.Advance(40 * time.Minute)
+				case len(test.want):
+					cancel()
+				}
+				return nil
+			}, opts...)
+
+			done := make(chan error, 1)
+			go func() { done <- group.Wait() }()
+
+			for {
+				select {
+				case err := <-done:
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(runs) != len(test.want) {
+						t.Fatalf("want runs at %v, got %v", test.want, runs)
+					}
+					for i := range runs {
+						if !runs[i].Equal(test.want[i]) {
+							t.Fatalf("want runs at %v, got %v", test.want, runs)
+						}
+					}
+					p.CheckCounter("tickgroup.cleanup.runs", float64(len(test.want)))
+					return
+				case <-clock.waiting:
+					clock.advanceToLastTimer()
+				}
+			}
+		})
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	running := make(chan struct{})
+	var stopped bool
+
+	srv := NewServer(func(g *Group) {
+		g.GoContext(time.Hour, func(ctx context.Context) error {
+			close(running)
+			<-ctx.Done()
+			stopped = true
+			return nil
+		})
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Run() }()
+
+	<-running
+	srv.Stop(nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !stopped {
+		t.Fatal("want run to have been canceled")
+	}
+}
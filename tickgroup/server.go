@@ -0,0 +1,21 @@
+package tickgroup
+
+import (
+	"context"
+
+	"github.com/heroku/x/cmdutil"
+)
+
+// NewServer returns a cmdutil.Server which runs the tasks started by setup in
+// a Group, e.g. for use with service.Standard's Add.
+//
+// Run returns the first error of the group's tasks, which stops the others.
+// Stop cancels the group's context, and Run returns once the tasks' running
+// subtasks have returned.
+func NewServer(setup func(g *Group)) cmdutil.Server {
+	return cmdutil.NewContextServer(func(ctx context.Context) error {
+		g, _ := WithContext(ctx)
+		setup(g)
+		return g.Wait()
+	})
+}
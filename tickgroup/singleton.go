@@ -0,0 +1,267 @@
+package tickgroup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/heroku/x/hredis/redigo"
+)
+
+// DefaultLockTTL is the default duration of the leases of WithLock.
+const DefaultLockTTL = 30 * time.Second
+
+// A Locker manages named locks which are leased for a TTL. Every acquisition
+// of a lock is identified by a fencing token, greater than the tokens of the
+// previous acquisitions of the lock.
+type Locker interface {
+	// Acquire acquires the named lock for ttl, returning its fencing token,
+	// or 0 if the lock is already held.
+	Acquire(ctx context.Context, name string, ttl time.Duration) (int64, error)
+
+	// Extend renews the lease of the named lock held with token for ttl,
+	// reporting whether it was still held.
+	Extend(ctx context.Context, name string, token int64, ttl time.Duration) (bool, error)
+
+	// Release releases the named lock if held with token.
+	Release(ctx context.Context, name string, token int64) error
+}
+
+// WithLock makes the task a singleton among the processes sharing the lock
+// named name: the task only runs while holding the lock, which it tries to
+// acquire before every run, and holds between runs by renewing its lease
+// every third of ttl. Processes not holding the lock skip their runs.
+//
+// If the lease can't be renewed before it expires, the lock is lost and the
+// context of the running subtask, if any, is canceled. As the subtask may
+// still be running when another process acquires the lock, writes it makes
+// should be guarded with the fencing token returned by LockToken.
+//
+// Runs are skipped when acquiring the lock fails. The errors are passed to
+// the handler of WithErrorHandler, and only count as failed runs towards
+// WithMaxFailures if it is used explicitly.
+//
+// The lock is released when the task ends. If ttl <= 0, DefaultLockTTL is
+// used.
+func WithLock(l Locker, name string, ttl time.Duration) TaskOption {
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	return func(c *taskConfig) {
+		c.lock = &singleton{locker: l, name: name, ttl: ttl}
+	}
+}
+
+type lockTokenKey struct{}
+
+// LockToken returns the fencing token of the lock held by the subtask
+// running with ctx, if it was started with WithLock.
+func LockToken(ctx context.Context) (int64, bool) {
+	token, ok := ctx.Value(lockTokenKey{}).(int64)
+	return token, ok
+}
+
+// singleton holds the lock of a task. It is only used by the task's
+// goroutine, except for the leases' renewals.
+type singleton struct {
+	locker Locker
+	name   string
+	ttl    time.Duration
+	lease  *lease
+
+	leader metrics.Gauge
+	lost   metrics.Counter
+	errors metrics.Counter
+}
+
+type lease struct {
+	token  int64
+	ctx    context.Context // canceled when the lock is lost or released
+	cancel context.CancelFunc
+	expiry *time.Timer   // loses the lock when the lease expires
+	done   chan struct{} // closed once the lease isn't renewed anymore
+	once   sync.Once
+}
+
+// hold returns a context for a run with the lock, which is canceled if the
+// lock is lost, acquiring the lock if needed. It returns a nil context if the
+// lock is held by another process.
+func (s *singleton) hold(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if s.lease != nil && s.lease.ctx.Err() != nil {
+		<-s.lease.done
+		s.lease = nil
+	}
+
+	if s.lease == nil {
+		start := time.Now()
+		actx, cancel := context.WithTimeout(ctx, s.ttl)
+		token, err := s.locker.Acquire(actx, s.name, s.ttl)
+		cancel()
+		if err != nil {
+			return nil, nil, fmt.Errorf("acquiring lock %s: %w", s.name, err)
+		}
+		if token == 0 {
+			return nil, nil, nil
+		}
+
+		lctx, lcancel := context.WithCancel(context.Background())
+		l := &lease{token: token, ctx: lctx, cancel: lcancel, done: make(chan struct{})}
+		l.expiry = time.AfterFunc(s.ttl-time.Since(start), func() { s.lose(l) })
+		s.lease = l
+		s.leader.Set(1)
+		go s.renew(l)
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, lockTokenKey{}, s.lease.token))
+	stop := context.AfterFunc(s.lease.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}, nil
+}
+
+// renew renews l every third of the TTL until it is released, or lost when
+// it can't be renewed before expiring. The lease's expiry timer loses the
+// lock on time even if renewing it blocks.
+func (s *singleton) renew(l *lease) {
+	defer close(l.done)
+
+	interval := s.ttl / 3
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(l.ctx, interval)
+		held, err := s.locker.Extend(ctx, s.name, l.token, s.ttl)
+		cancel()
+
+		switch {
+		case err == nil && held:
+			renewed = start
+			l.expiry.Reset(s.ttl - time.Since(start))
+		case err == nil || time.Since(renewed)+interval >= s.ttl:
+			s.lose(l)
+			return
+		}
+	}
+}
+
+// lose cancels l, unless it already ended.
+func (s *singleton) lose(l *lease) {
+	l.once.Do(func() {
+		l.cancel()
+		s.leader.Set(0)
+		s.lost.Add(1)
+	})
+}
+
+// release releases the lock if held, passing errors to onError.
+func (s *singleton) release(onError func(error)) {
+	l := s.lease
+	if l == nil {
+		return
+	}
+	s.lease = nil
+
+	l.expiry.Stop()
+	l.once.Do(l.cancel)
+	<-l.done
+	s.leader.Set(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.ttl/3)
+	defer cancel()
+	if err := s.locker.Release(ctx, s.name, l.token); err != nil && onError != nil {
+		onError(fmt.Errorf("releasing lock %s: %w", s.name, err))
+	}
+}
+
+var _ Locker = (*RedisLocker)(nil)
+
+var (
+	acquireScript = redis.NewScript(2, `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+local token = redis.call("INCR", KEYS[2])
+redis.call("SET", KEYS[1], token, "PX", ARGV[1])
+return token`)
+
+	extendScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// NewRedisLocker returns a Locker storing the lock named name at the key
+// name, and its last fencing token at the key name + ":fence".
+func NewRedisLocker(pool *redis.Pool) *RedisLocker {
+	return &RedisLocker{pool: pool}
+}
+
+// NewRedisLockerFromURL returns a RedisLocker for the Redis server at
+// rawURL, connected with a pool from redigo.NewRedisPoolFromURL.
+func NewRedisLockerFromURL(rawURL string, opts ...redigo.OptionFunc) (*RedisLocker, error) {
+	pool, err := redigo.NewRedisPoolFromURL(rawURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisLocker(pool), nil
+}
+
+// RedisLocker is a Locker backed by Redis.
+type RedisLocker struct {
+	pool *redis.Pool
+}
+
+// Acquire implements Locker.
+func (l *RedisLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (int64, error) {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	return redis.Int64(acquireScript.DoContext(ctx, conn, name, name+":fence", ttl.Milliseconds()))
+}
+
+// Extend implements Locker.
+func (l *RedisLocker) Extend(ctx context.Context, name string, token int64, ttl time.Duration) (bool, error) {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	return redis.Bool(extendScript.DoContext(ctx, conn, name, token, ttl.Milliseconds()))
+}
+
+// Release implements Locker.
+func (l *RedisLocker) Release(ctx context.Context, name string, token int64) error {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = releaseScript.DoContext(ctx, conn, name, token)
+	return err
+}
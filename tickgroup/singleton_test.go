@@ -0,0 +1,337 @@
+package tickgroup
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+	"github.com/heroku/x/testing/testenv"
+)
+
+// memoryLocker is a Locker for processes sharing memory.
+type memoryLocker struct {
+	mu     sync.Mutex
+	locks  map[string]memoryLock
+	fences map[string]int64
+
+	acquireErr error         // returned by Acquire if set
+	extending  chan struct{} // blocks Extend until closed if set
+}
+
+type memoryLock struct {
+	token   int64
+	expires time.Time
+}
+
+func newMemoryLocker() *memoryLocker {
+	return &memoryLocker{locks: make(map[string]memoryLock), fences: make(map[string]int64)}
+}
+
+func (l *memoryLocker) Acquire(_ context.Context, name string, ttl time.Duration) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.acquireErr != nil {
+		return 0, l.acquireErr
+	}
+	if lock, ok := l.locks[name]; ok && lock.expires.After(time.Now()) {
+		return 0, nil
+	}
+	l.fences[name]++
+	l.locks[name] = memoryLock{token: l.fences[name], expires: time.Now().Add(ttl)}
+	return l.fences[name], nil
+}
+
+func (l *memoryLocker) Extend(_ context.Context, name string, token int64, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	extending := l.extending
+	l.mu.Unlock()
+	if extending != nil {
+		<-extending
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lock, ok := l.locks[name]; !ok || lock.token != token {
+		return false, nil
+	}
+	l.locks[name] = memoryLock{token: token, expires: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (l *memoryLocker) Release(_ context.Context, name string, token int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lock, ok := l.locks[name]; ok && lock.token == token {
+		delete(l.locks, name)
+	}
+	return nil
+}
+
+// steal acquires the named lock for another process, as if its lease had
+// expired.
+func (l *memoryLocker) steal(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.fences[name]++
+	l.locks[name] = memoryLock{token: l.fences[name], expires: time.Now().Add(time.Hour)}
+}
+
+func TestGoContextWithLock(t *testing.T) {
+	locker := newMemoryLocker()
+
+	type process struct {
+		cancel context.CancelFunc
+		group  *Group
+		runs   uint32
+		token  atomic.Int64
+	}
+
+	start := func() *process {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &process{cancel: cancel, group: New(ctx)}
+		p.group.GoContext(time.Millisecond, func(ctx context.Context) error {
+			token, ok := LockToken(ctx)
+			if !ok {
+				t.Error("want lock token, got none")
+			}
+			p.token.Store(token)
+			atomic.AddUint32(&p.runs, 1)
+			return nil
+		}, WithLock(locker, "cleanup", time.Minute))
+		return p
+	}
+
+	a := start()
+	eventually(t, func() bool { return atomic.LoadUint32(&a.runs) > 0 })
+	b := start()
+	time.Sleep(20 * time.Millisecond)
+
+	if got := atomic.LoadUint32(&b.runs); got != 0 {
+		t.Fatalf("want no runs without the lock, got %d", got)
+	}
+
+	// the lock is released when stopping, and taken over
+	a.cancel()
+	if err := a.group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return atomic.LoadUint32(&b.runs) > 0 })
+
+	b.cancel()
+	if err := b.group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := a.token.Load(), b.token.Load(); b <= a {
+		t.Fatalf("want increasing fencing tokens, got %d then %d", a, b)
+	}
+}
+
+func TestGoContextLockLost(t *testing.T) {
+	locker := newMemoryLocker()
+	p := testmetrics.NewProvider(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	group := New(ctx)
+
+	var runs uint32
+	lost := make(chan error, 1)
+	group.GoContext(time.Millisecond, func(ctx context.Context) error {
+		atomic.AddUint32(&runs, 1)
+		locker.steal("cleanup")
+		<-ctx.Done()
+		lost <- ctx.Err()
+		return nil
+	}, WithLock(locker, "cleanup", 30*time.Millisecond), WithMetrics(p, "cleanup"))
+
+	select {
+	case err := <-lost:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("want run canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want run canceled when losing the lock")
+	}
+
+	// the lock is now held elsewhere, so the task doesn't run anymore
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadUint32(&runs); got != 1 {
+		t.Fatalf("want 1 run, got %d", got)
+	}
+	p.CheckCounter("tickgroup.cleanup.lock.lost", 1)
+	p.CheckGauge("tickgroup.cleanup.leader", 0)
+}
+
+func TestGoContextLockLostWhileExtendBlocks(t *testing.T) {
+	locker := newMemoryLocker()
+	extending := make(chan struct{})
+	locker.extending = extending
+	defer close(extending)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	group := New(ctx)
+
+	lost := make(chan time.Duration, 1)
+	group.GoContext(time.Hour, func(ctx context.Context) error {
+		start := time.Now()
+		<-ctx.Done()
+		lost <- time.Since(start)
+		return nil
+	}, WithLock(locker, "cleanup", 30*time.Millisecond))
+
+	select {
+	case d := <-lost:
+		if d > 100*time.Millisecond {
+			t.Fatalf("want run canceled when the lease expires, got canceled after %v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want run canceled while extending the lease blocks")
+	}
+}
+
+func TestGoContextLockErrors(t *testing.T) {
+	locker := newMemoryLocker()
+	locker.acquireErr = errors.New("unavailable")
+	p := testmetrics.NewProvider(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	group := New(ctx)
+
+	var errs uint32
+	group.GoContext(time.Millisecond, func(context.Context) error {
+		t.Error("want no run without the lock")
+		return nil
+	}, WithLock(locker, "cleanup", time.Minute), WithMetrics(p, "cleanup"), WithErrorHandler(func(err error) {
+		if atomic.AddUint32(&errs, 1) == 3 {
+			cancel()
+		}
+	}))
+
+	// lock errors don't stop the task by default
+	if err := group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	p.CheckCounter("tickgroup.cleanup.lock.errors", 3)
+	p.CheckCounter("tickgroup.cleanup.skipped", 3)
+	p.CheckCounter("tickgroup.cleanup.runs", 0)
+
+	group, _ = WithContext(context.Background())
+	group.GoContext(time.Millisecond, func(context.Context) error {
+		return nil
+	}, WithLock(locker, "cleanup", time.Minute), WithMaxFailures(2))
+
+	if err := group.Wait(); !errors.Is(err, locker.acquireErr) {
+		t.Fatalf("want err %v, got %v", locker.acquireErr, err)
+	}
+}
+
+func TestRedisLocker(t *testing.T) {
+	ctx := context.Background()
+	conn, pool := testenv.NewMockRedisPool(t)
+	l := NewRedisLocker(pool)
+
+	conn.Command("EVALSHA", acquireScript.Hash(), 2, "cleanup", "cleanup:fence", int64(30000)).Expect(int64(7))
+	conn.Command("EVALSHA", extendScript.Hash(), 1, "cleanup", int64(7), int64(30000)).Expect(int64(1))
+	conn.Command("EVALSHA", releaseScript.Hash(), 1, "cleanup", int64(7)).Expect(int64(1))
+
+	token, err := l.Acquire(ctx, "cleanup", 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != 7 {
+		t.Fatalf("want token 7, got %d", token)
+	}
+
+	held, err := l.Extend(ctx, "cleanup", token, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !held {
+		t.Fatal("want lock held")
+	}
+
+	if err := l.Release(ctx, "cleanup", token); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisLockerScripts(t *testing.T) {
+	ctx := context.Background()
+	pool := testenv.NewReachableRedisPool(t)
+	l := NewRedisLocker(pool)
+
+	name := "tickgroup-test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() {
+		conn := pool.Get()
+		defer conn.Close()
+		if _, err := conn.Do("DEL", name, name+":fence"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	first, err := l.Acquire(ctx, name, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first <= 0 {
+		t.Fatalf("want lock acquired, got token %d", first)
+	}
+	if token, err := l.Acquire(ctx, name, time.Minute); err != nil || token != 0 {
+		t.Fatalf("want lock held, got token %d, err %v", token, err)
+	}
+
+	// a stale token neither extends nor releases the lock
+	if held, err := l.Extend(ctx, name, first+1, time.Minute); err != nil || held {
+		t.Fatalf("want extending with another token to fail, got held %v, err %v", held, err)
+	}
+	if err := l.Release(ctx, name, first+1); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := l.Acquire(ctx, name, time.Minute); err != nil || token != 0 {
+		t.Fatalf("want lock held after release with another token, got token %d, err %v", token, err)
+	}
+
+	if held, err := l.Extend(ctx, name, first, time.Minute); err != nil || !held {
+		t.Fatalf("want lock extended, got held %v, err %v", held, err)
+	}
+	if err := l.Release(ctx, name, first); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := l.Acquire(ctx, name, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second <= first {
+		t.Fatalf("want increasing fencing tokens, got %d then %d", first, second)
+	}
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
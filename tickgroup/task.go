@@ -0,0 +1,358 @@
+package tickgroup
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+
+	xmetrics "github.com/heroku/x/go-kit/metrics"
+	"github.com/heroku/x/go-kit/metrics/provider/discard"
+)
+
+// A Clock tells the time and waits for durations to elapse.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// TaskOption configures a task started with GoContext.
+type TaskOption func(*taskConfig)
+
+type taskConfig struct {
+	splay        time.Duration
+	jitter       time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	maxFailures  int  // < 0 until set
+	lockFailures bool // whether lock errors count as failures
+	onError      func(error)
+	skip         bool
+	timeout      time.Duration
+	clock        Clock
+
+	lock *singleton
+
+	provider xmetrics.Provider
+	prefix   string
+	runs     metrics.Counter
+	failures metrics.Counter
+	skipped  metrics.Counter
+	duration metrics.Histogram
+}
+
+// WithSplay delays the first run of the task by a random duration up to maxDelay,
+// so that processes started together don't run it in lockstep.
+func WithSplay(maxDelay time.Duration) TaskOption {
+	return func(c *taskConfig) {
+		c.splay = maxDelay
+	}
+}
+
+// WithJitter adds a random duration up to maxDelay to every interval
+// between runs.
+func WithJitter(maxDelay time.Duration) TaskOption {
+	return func(c *taskConfig) {
+		c.jitter = maxDelay
+	}
+}
+
+// WithBackoff retries failed runs after an exponential backoff, starting at
+// minDelay and doubling with every consecutive failure up to maxDelay,
+// instead of stopping the task. The task's interval applies again after a
+// successful run.
+//
+// Unless WithMaxFailures is used, failures never stop a task with backoff.
+func WithBackoff(minDelay, maxDelay time.Duration) TaskOption {
+	return func(c *taskConfig) {
+		c.minBackoff = minDelay
+		c.maxBackoff = maxDelay
+	}
+}
+
+// WithMaxFailures stops the task after n consecutive failed runs, returning
+// the last error, which cancels the group. If n < 1, failures never stop the
+// task. It defaults to 1 without WithBackoff, so that the first failure stops
+// the task like with Go.
+func WithMaxFailures(n int) TaskOption {
+	return func(c *taskConfig) {
+		c.maxFailures = max(n, 0)
+	}
+}
+
+// WithErrorHandler calls fn with the errors of failed runs which don't stop
+// the task, e.g. to log them.
+func WithErrorHandler(fn func(error)) TaskOption {
+	return func(c *taskConfig) {
+		c.onError = fn
+	}
+}
+
+// WithSkipIfRunning skips the runs which should have started while the
+// previous run was still running, rather than starting the next run as soon
+// as the previous one returns. Runs of a task never overlap either way.
+func WithSkipIfRunning() TaskOption {
+	return func(c *taskConfig) {
+		c.skip = true
+	}
+}
+
+// WithTimeout cancels the context passed to each run after d.
+func WithTimeout(d time.Duration) TaskOption {
+	return func(c *taskConfig) {
+		c.timeout = d
+	}
+}
+
+// WithClock schedules the task with c rather than the system clock, e.g. to
+// control time in tests. Run timeouts still use the system clock.
+func WithClock(c Clock) TaskOption {
+	return func(cfg *taskConfig) {
+		cfg.clock = c
+	}
+}
+
+// WithMetrics reports the task's metrics to p, named after the task:
+//
+//	tickgroup.<task>.runs - number of runs
+//	tickgroup.<task>.failures - number of failed runs
+//	tickgroup.<task>.skipped - number of runs skipped by WithSkipIfRunning, or
+//	  for lack of the lock of WithLock
+//	tickgroup.<task>.duration.ms - histogram of run durations
+//
+// Tasks with WithLock also report:
+//
+//	tickgroup.<task>.leader - 1 while holding the lock, 0 otherwise
+//	tickgroup.<task>.lock.lost - number of times the lock was lost
+//	tickgroup.<task>.lock.errors - number of failures to acquire the lock
+func WithMetrics(p xmetrics.Provider, task string) TaskOption {
+	return func(c *taskConfig) {
+		c.provider = p
+		c.prefix = "tickgroup." + task + "."
+	}
+}
+
+func newTaskConfig(opts []TaskOption) *taskConfig {
+	c := &taskConfig{maxFailures: -1, clock: systemClock{}, provider: discard.New()}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	p := c.provider
+	c.runs = p.NewCounter(c.prefix + "runs")
+	c.failures = p.NewCounter(c.prefix + "failures")
+	c.skipped = p.NewCounter(c.prefix + "skipped")
+	c.duration = p.NewExplicitHistogram(c.prefix+"duration.ms", xmetrics.ThirtySecondDistribution)
+	if c.lock != nil {
+		c.lock.leader = p.NewGauge(c.prefix + "leader")
+		c.lock.lost = p.NewCounter(c.prefix + "lock.lost")
+		c.lock.errors = p.NewCounter(c.prefix + "lock.errors")
+	}
+
+	c.lockFailures = c.maxFailures >= 0
+	if c.maxFailures < 0 {
+		c.maxFailures = 1
+		if c.minBackoff > 0 {
+			c.maxFailures = 0
+		}
+	}
+	if c.maxBackoff < c.minBackoff {
+		c.maxBackoff = c.minBackoff
+	}
+	return c
+}
+
+// GoContext spawns a subtask f every d, configured by opts. f is passed a
+// context which is canceled when the group's context is done, or when the
+// run times out with WithTimeout.
+//
+// Without options, GoContext behaves like Go: f runs immediately, even if the
+// group's context is already done, and the first error stops the task and
+// cancels the group. Options allow the task to tolerate failures, back off,
+// spread its runs and report metrics. GoContext panics if d <= 0.
+func (g *Group) GoContext(d time.Duration, f func(context.Context) error, opts ...TaskOption) {
+	if d <= 0 {
+		panic("non-positive interval for GoContext")
+	}
+	cfg := newTaskConfig(opts)
+
+	g.goTask(cfg, f, 0, func(start, end time.Time) (time.Duration, int) {
+		elapsed := end.Sub(start)
+		switch {
+		case elapsed < d:
+			return d - elapsed, 0
+		case !cfg.skip:
+			return 0, 0
+		}
+		// wait for the next tick after the ones missed
+		missed := elapsed / d
+		return d*(missed+1) - elapsed, int(missed)
+	})
+}
+
+// GoCron spawns a subtask f at every activation time of s, configured by
+// opts like with GoContext. Unlike GoContext, f first runs at the first
+// activation time. The task ends when s has no more activation times.
+func (g *Group) GoCron(s Schedule, f func(context.Context) error, opts ...TaskOption) {
+	cfg := newTaskConfig(opts)
+
+	first := s.Next(cfg.clock.Now())
+	if first.IsZero() {
+		return
+	}
+
+	g.goTask(cfg, f, first.Sub(cfg.clock.Now()), func(start, end time.Time) (time.Duration, int) {
+		next := s.Next(start)
+		if !next.After(end) && !cfg.skip {
+			return 0, 0
+		}
+		var missed int
+		for !next.IsZero() && !next.After(end) {
+			next = s.Next(next)
+			missed++
+		}
+		if next.IsZero() {
+			return -1, missed
+		}
+		return next.Sub(end), missed
+	})
+}
+
+// goTask runs f in the group after the initial delay, then after the delays
+// returned by next until the group's context is done. next is given the
+// start and end of the previous successful run, and returns the delay before
+// the next run, or < 0 to end the task, and the number of runs skipped.
+func (g *Group) goTask(cfg *taskConfig, f func(context.Context) error, initial time.Duration,
+	next func(start, end time.Time) (time.Duration, int)) {
+	g.g.Go(func() error {
+		if cfg.lock != nil {
+			defer cfg.lock.release(cfg.onError)
+		}
+
+		// like with Go, f runs once without delay even if the group's
+		// context is already done
+		if d := initial + random(cfg.splay); d > 0 && !g.sleep(cfg.clock, d) {
+			return nil
+		}
+
+		var failures int
+		for {
+			start := cfg.clock.Now()
+			err := cfg.run(g.ctx, f)
+			end := cfg.clock.Now()
+
+			var wait time.Duration
+			if err != nil {
+				failures++
+				if cfg.maxFailures > 0 && failures >= cfg.maxFailures {
+					return err
+				}
+				if cfg.onError != nil {
+					cfg.onError(err)
+				}
+			} else {
+				failures = 0
+			}
+
+			if err != nil && cfg.minBackoff > 0 {
+				wait = cfg.backoff(failures)
+			} else {
+				var skipped int
+				wait, skipped = next(start, end)
+				cfg.skipped.Add(float64(skipped))
+				if wait < 0 {
+					return nil
+				}
+			}
+
+			if !g.sleep(cfg.clock, wait+random(cfg.jitter)) {
+				return nil
+			}
+		}
+	})
+}
+
+// run runs f once, with a timeout if configured, and records its metrics.
+// With WithLock, f only runs while holding the lock.
+func (c *taskConfig) run(ctx context.Context, f func(context.Context) error) error {
+	if c.lock != nil {
+		lctx, cancel, err := c.lock.hold(ctx)
+		if err != nil {
+			c.skipped.Add(1)
+			c.lock.errors.Add(1)
+			if c.lockFailures {
+				return err
+			}
+			if c.onError != nil {
+				c.onError(err)
+			}
+			return nil
+		}
+		if lctx == nil {
+			c.skipped.Add(1)
+			return nil
+		}
+		defer cancel()
+		ctx = lctx
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := f(ctx)
+	c.duration.Observe(float64(time.Since(start)) / float64(time.Millisecond))
+	c.runs.Add(1)
+	if err != nil {
+		c.failures.Add(1)
+	}
+	return err
+}
+
+// backoff returns the delay before retrying after the given number of
+// consecutive failures.
+func (c *taskConfig) backoff(failures int) time.Duration {
+	d := c.minBackoff
+	for i := 1; i < failures && d < c.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, c.maxBackoff)
+}
+
+// sleep waits for d on c, and reports whether the group's context is still
+// not done.
+func (g *Group) sleep(c Clock, d time.Duration) bool {
+	select {
+	case <-g.ctx.Done():
+		return false
+	default:
+	}
+	if d <= 0 {
+		return true
+	}
+
+	select {
+	case <-g.ctx.Done():
+		return false
+	case <-c.After(d):
+		return true
+	}
+}
+
+// random returns a random duration in [0, d).
+func random(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) //nolint:gosec // jitter doesn't need a secure source
+}
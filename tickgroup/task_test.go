@@ -0,0 +1,198 @@
+package tickgroup
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heroku/x/go-kit/metrics/testmetrics"
+)
+
+func TestGoContextToleratesFailures(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	group := New(ctx)
+
+	var (
+		ticks  uint32
+		errs   uint32
+		errOdd = errors.New("odd")
+	)
+	group.GoContext(time.Millisecond, func(context.Context) error {
+		n := atomic.AddUint32(&ticks, 1)
+		if n == 5 {
+			cancel()
+		}
+		if n%2 == 1 {
+			return errOdd
+		}
+		return nil
+	}, WithMaxFailures(0), WithErrorHandler(func(err error) {
+		if err != errOdd {
+			t.Errorf("want err %v, got %v", errOdd, err)
+		}
+		atomic.AddUint32(&errs, 1)
+	}))
+
+	if err := group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 5, int(atomic.LoadUint32(&ticks)); want != got {
+		t.Fatalf("want tick count %d, got %d", want, got)
+	}
+	if want, got := 3, int(atomic.LoadUint32(&errs)); want != got {
+		t.Fatalf("want %d handled errors, got %d", want, got)
+	}
+}
+
+func TestGoContextMaxFailures(t *testing.T) {
+	group, ctx := WithContext(context.Background())
+
+	wantErr := errors.New("failed")
+
+	var ticks uint32
+	group.GoContext(time.Millisecond, func(context.Context) error {
+		// the failure streak is reset by the 3rd tick
+		if atomic.AddUint32(&ticks, 1) == 3 {
+			return nil
+		}
+		return wantErr
+	}, WithMaxFailures(3))
+
+	if gotErr := group.Wait(); gotErr != wantErr {
+		t.Fatalf("want err %v, got err %v", wantErr, gotErr)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("wanted ctx to have been canceled")
+	}
+	if want, got := 6, int(atomic.LoadUint32(&ticks)); want != got {
+		t.Fatalf("want tick count %d, got %d", want, got)
+	}
+}
+
+func TestGoContextBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	group := New(ctx)
+
+	var (
+		ticks uint32
+		last  time.Time
+		gaps  []time.Duration
+	)
+	group.GoContext(time.Hour, func(context.Context) error {
+		now := time.Now()
+		if !last.IsZero() {
+			gaps = append(gaps, now.Sub(last))
+		}
+		last = now
+
+		if atomic.AddUint32(&ticks, 1) == 5 {
+			cancel()
+		}
+		return errors.New("unavailable")
+	}, WithBackoff(10*time.Millisecond, 40*time.Millisecond))
+
+	if err := group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}
+	if len(gaps) != len(want) {
+		t.Fatalf("want %d retries, got %v", len(want), gaps)
+	}
+	for i, gap := range gaps {
+		if gap < want[i] || gap >= time.Hour {
+			t.Fatalf("want retry %d after %v, got %v", i, want[i], gap)
+		}
+	}
+}
+
+func TestGoContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	group := New(ctx)
+
+	var gotErr error
+	group.GoContext(time.Hour, func(ctx context.Context) error {
+		defer cancel()
+		<-ctx.Done()
+		gotErr = ctx.Err()
+		return nil
+	}, WithTimeout(10*time.Millisecond))
+
+	if err := group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if gotErr != context.DeadlineExceeded {
+		t.Fatalf("want run to time out, got %v", gotErr)
+	}
+}
+
+func TestGoContextSkipIfRunning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	group := New(ctx)
+	p := testmetrics.NewProvider(t)
+
+	var (
+		ticks uint32
+		start = time.Now()
+		at    []time.Duration
+	)
+	group.GoContext(20*time.Millisecond, func(context.Context) error {
+		at = append(at, time.Since(start))
+		if atomic.AddUint32(&ticks, 1) == 2 {
+			cancel()
+			return nil
+		}
+		// overrun 2 ticks
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}, WithSkipIfRunning(), WithMetrics(p, "sync"))
+
+	if err := group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the 2nd run waits for the 3rd tick, rather than running right away
+	if len(at) != 2 || at[1] < 60*time.Millisecond {
+		t.Fatalf("want 2nd run at the 3rd tick, got runs at %v", at)
+	}
+	p.CheckCounter("tickgroup.sync.skipped", 2)
+	p.CheckCounter("tickgroup.sync.runs", 2)
+	p.CheckCounter("tickgroup.sync.failures", 0)
+	p.CheckObservationCount("tickgroup.sync.duration.ms", 2)
+}
+
+func TestGoContextNonPositiveIntervalPanics(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		t.Run(d.String(), func(t *testing.T) {
+			defer func() {
+				if x := recover(); x == nil {
+					t.Fatal("wanted GoContext to panic")
+				}
+			}()
+
+			New(context.Background()).GoContext(d, func(context.Context) error { return nil }, WithSkipIfRunning())
+		})
+	}
+}
+
+func TestGoContextSplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	group := New(ctx)
+
+	var ticks uint32
+	group.GoContext(time.Millisecond, func(context.Context) error {
+		atomic.AddUint32(&ticks, 1)
+		return nil
+	}, WithSplay(time.Nanosecond), WithJitter(time.Hour))
+
+	if err := group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	// jitter delays the 2nd run past the group's end
+	if want, got := 1, int(atomic.LoadUint32(&ticks)); want != got {
+		t.Fatalf("want tick count %d, got %d", want, got)
+	}
+}
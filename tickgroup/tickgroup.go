@@ -1,4 +1,15 @@
 // Package tickgroup allows a collection of goroutines to call a subtask every set time interval.
+//
+// Tasks started with GoContext can be configured with TaskOptions to spread
+// their runs with jitter, tolerate and back off from failures, skip runs
+// while a previous one is still running, time out runs and report metrics.
+//
+// GoCron runs tasks at wall clock times following a Schedule, such as one
+// parsed from a cron spec by ParseCron. NewServer adapts a Group to a
+// cmdutil.Server.
+//
+// With WithLock, a task only runs in one of the processes sharing a Locker,
+// such as a RedisLocker, at a time.
 package tickgroup
 
 import (
@@ -11,46 +22,27 @@ import (
 // A Group is a collection of goroutines working on subtask that are spawned on
 // a set interval per task.
 type Group struct {
-	g     *errgroup.Group
-	donec <-chan struct{}
+	g   *errgroup.Group
+	ctx context.Context
 }
 
 // New returns a new Group that stops spawning subtasks when ctx is done.
 func New(ctx context.Context) *Group {
-	return &Group{g: new(errgroup.Group), donec: ctx.Done()}
+	return &Group{g: new(errgroup.Group), ctx: ctx}
 }
 
 // WithContext creates a child context from the given context, and uses that to
 // control context cancelation.
 func WithContext(ctx context.Context) (*Group, context.Context) {
 	g, ctx := errgroup.WithContext(ctx)
-	return &Group{g: g, donec: ctx.Done()}, ctx
+	return &Group{g: g, ctx: ctx}, ctx
 }
 
 // Go spawns a subtask f every d. The goroutine terminates when an error is
 // encountered. The first call to return a non-nil error cancels the group; its
 // error will be returned by Wait.
 func (g *Group) Go(d time.Duration, f func() error) {
-	g.g.Go(func() error {
-		if err := f(); err != nil {
-			return err
-		}
-
-		t := time.NewTicker(d)
-		defer t.Stop()
-
-		for {
-			select {
-			case <-g.donec:
-				return nil
-
-			case <-t.C:
-				if err := f(); err != nil {
-					return err
-				}
-			}
-		}
-	})
+	g.GoContext(d, func(context.Context) error { return f() })
 }
 
 // Wait blocks until all function calls from the Go method have returned,
@@ -78,3 +78,23 @@ func TestGroupRunsImmediatelyBeforeWaiting(t *testing.T) {
 		t.Fatalf("want tick count %d, got %d", want, got)
 	}
 }
+
+func TestGroupRunsOnceWithDoneContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	group := New(ctx)
+
+	var ticks uint32
+	group.Go(time.Millisecond, func() error {
+		atomic.AddUint32(&ticks, 1)
+		return nil
+	})
+
+	if err := group.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := 1, int(atomic.LoadUint32(&ticks)); want != got {
+		t.Fatalf("want tick count %d, got %d", want, got)
+	}
+}